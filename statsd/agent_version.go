@@ -0,0 +1,96 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// agentFeatures is a set of protocol features supported by the agent receiving the payloads.
+type agentFeatures uint8
+
+const (
+	// agentFeatureValuePacking is the support of several values per message, used by extended aggregation.
+	agentFeatureValuePacking agentFeatures = 1 << iota
+	// agentFeatureContainerID is the support of the container ID field ("|c:").
+	agentFeatureContainerID
+	// agentFeatureTimestamp is the support of the timestamp field ("|T").
+	agentFeatureTimestamp
+	// agentFeatureExternalEnv is the support of the external environment field ("|e:").
+	agentFeatureExternalEnv
+	// agentFeatureCardinality is the support of the tag cardinality field ("|card:").
+	agentFeatureCardinality
+
+	// allAgentFeatures is used when the agent version is unknown.
+	allAgentFeatures = agentFeatureValuePacking | agentFeatureContainerID | agentFeatureTimestamp |
+		agentFeatureExternalEnv | agentFeatureCardinality
+)
+
+func (f agentFeatures) has(feature agentFeatures) bool {
+	return f&feature != 0
+}
+
+type agentVersion struct {
+	major, minor, patch int
+}
+
+// parseAgentVersion parses versions like "7.38.0", "7.38" or "v7.38.0-rc.1". Pre-release and build suffixes are
+// ignored.
+func parseAgentVersion(version string) (agentVersion, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return agentVersion{}, fmt.Errorf("invalid agent version %q", version)
+	}
+	numbers := []int{0, 0, 0}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return agentVersion{}, fmt.Errorf("invalid agent version %q", version)
+		}
+		numbers[i] = n
+	}
+	return agentVersion{major: numbers[0], minor: numbers[1], patch: numbers[2]}, nil
+}
+
+func (v agentVersion) atLeast(other agentVersion) bool {
+	if v.major != other.major {
+		return v.major > other.major
+	}
+	if v.minor != other.minor {
+		return v.minor > other.minor
+	}
+	return v.patch >= other.patch
+}
+
+// agentFeatureVersions lists the first Agent 7 version supporting each feature and, for features also released in
+// Agent 6, the first Agent 6 version supporting it.
+var agentFeatureVersions = []struct {
+	feature agentFeatures
+	v7      agentVersion
+	v6      *agentVersion
+}{
+	{agentFeatureValuePacking, agentVersion{7, 25, 0}, &agentVersion{6, 25, 0}},
+	{agentFeatureContainerID, agentVersion{7, 35, 0}, &agentVersion{6, 35, 0}},
+	{agentFeatureTimestamp, agentVersion{7, 40, 0}, &agentVersion{6, 40, 0}},
+	{agentFeatureExternalEnv, agentVersion{7, 57, 0}, nil},
+	{agentFeatureCardinality, agentVersion{7, 64, 0}, nil},
+}
+
+// features returns the protocol features supported by the agent version.
+func (v agentVersion) features() agentFeatures {
+	var features agentFeatures
+	for _, f := range agentFeatureVersions {
+		supported := v.atLeast(f.v7)
+		if v.major == 6 && f.v6 != nil {
+			supported = v.atLeast(*f.v6)
+		}
+		if supported {
+			features |= f.feature
+		}
+	}
+	return features
+}
@@ -0,0 +1,116 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAgentVersion(t *testing.T) {
+	for version, expected := range map[string]agentVersion{
+		"7.38.0":       {7, 38, 0},
+		"7.38":         {7, 38, 0},
+		"v6.40.1":      {6, 40, 1},
+		"7.50.0-rc.2":  {7, 50, 0},
+		" 7.51.0+git ": {7, 51, 0},
+	} {
+		v, err := parseAgentVersion(version)
+		require.NoError(t, err, version)
+		assert.Equal(t, expected, v, version)
+	}
+
+	for _, version := range []string{"", "7", "7.x.0", "7.38.0.1", "7.-1.0"} {
+		_, err := parseAgentVersion(version)
+		assert.EqualError(t, err, "invalid agent version \""+version+"\"")
+	}
+}
+
+func TestAgentVersionFeatures(t *testing.T) {
+	features := func(version string) agentFeatures {
+		v, err := parseAgentVersion(version)
+		require.NoError(t, err)
+		return v.features()
+	}
+
+	assert.Equal(t, agentFeatures(0), features("5.32.0"))
+	assert.Equal(t, agentFeatures(0), features("7.24.9"))
+	assert.Equal(t, agentFeatureValuePacking|agentFeatureContainerID, features("7.38.0"))
+	assert.Equal(t, agentFeatureValuePacking|agentFeatureContainerID|agentFeatureTimestamp, features("6.53.0"))
+	assert.Equal(t, agentFeatureValuePacking|agentFeatureContainerID|agentFeatureTimestamp, features("7.56.2"))
+	assert.Equal(t, allAgentFeatures&^agentFeatureCardinality, features("7.57.0"))
+	assert.Equal(t, allAgentFeatures, features("7.64.0"))
+	assert.Equal(t, allAgentFeatures, features("8.0.0"))
+}
+
+func TestBufferAgentFeatures(t *testing.T) {
+	withoutOriginGlobals(t)
+	patchContainerID("container-id")
+	defer resetContainerID()
+	patchExternalEnv("external-env")
+	defer resetExternalEnv()
+
+	for _, tc := range []struct {
+		features agentFeatures
+		expected string
+	}{
+		{allAgentFeatures, "metric:1|g|#tag|c:container-id|e:external-env|T1658934092|card:high\n"},
+		{agentFeatureContainerID | agentFeatureTimestamp, "metric:1|g|#tag|c:container-id|T1658934092\n"},
+		{0, "metric:1|g|#tag\n"},
+	} {
+		buffer := newStatsdBuffer(1024, 1)
+		buffer.features = tc.features
+		err := buffer.writeGauge("", nil, "metric", 1, []string{"tag"}, 1, 1658934092, true, CardinalityHigh)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, string(buffer.bytes()))
+	}
+
+	buffer := newStatsdBuffer(1024, 1)
+	buffer.features = 0
+	_, err := buffer.writeAggregated([]byte("d"), "", nil, "metric", []float64{1, 2}, "", 0, -1, 1, true, CardinalityHigh)
+	require.NoError(t, err)
+	assert.Equal(t, "metric:1:2|d\n", string(buffer.bytes()))
+
+	// buffers borrowed from a pool get the pool's features
+	pool := newBufferPool(1, 1024, 1)
+	pool.features = agentFeatureTimestamp
+	assert.Equal(t, agentFeatureTimestamp, pool.borrowBuffer().features)
+	assert.Equal(t, agentFeatureTimestamp, pool.borrowBuffer().features)
+}
+
+func TestWithAgentVersion(t *testing.T) {
+	_, err := resolveOptions([]Option{WithAgentVersion("latest")})
+	assert.EqualError(t, err, "invalid agent version \"latest\"")
+
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithAgentVersion("7.38.0"),
+		WithoutTelemetry(),
+		WithoutOriginDetection(),
+		WithCardinality(CardinalityHigh),
+	)
+
+	require.NoError(t, client.GaugeWithTimestamp("gauge", 1, nil, 1, time.Unix(1658934092, 0)))
+	ts.assert(t, client, []string{"gauge:1|g" + ts.getContainerID()})
+}
+
+func TestWithAgentVersionExtendedAggregation(t *testing.T) {
+	client, err := NewEx("localhost:8765", WithAgentVersion("7.24.0"), WithExtendedClientSideAggregation(), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.NotNil(t, client.agg)
+	assert.Nil(t, client.aggExtended)
+
+	client, err = NewEx("localhost:8765", WithAgentVersion("7.25.0"), WithExtendedClientSideAggregation(), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.NotNil(t, client.aggExtended)
+
+	for _, option := range []Option{WithHistogramSummary(), WithDistributionSketch(0.01)} {
+		_, err = NewEx("localhost:8765", WithAgentVersion("7.24.0"), option, WithoutTelemetry())
+		assert.EqualError(t, err, "WithHistogramSummary and WithDistributionSketch require Agent 7.25.0 or 6.25.0")
+	}
+}
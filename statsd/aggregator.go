@@ -33,6 +33,8 @@ const (
 type countShardInner struct {
 	sync.RWMutex
 	counts countsMap
+	// epoch is incremented every time counts is reset by a flush so handles know they must re-attach.
+	epoch uint64
 }
 
 type countShard struct {
@@ -43,6 +45,8 @@ type countShard struct {
 type gaugeShardInner struct {
 	sync.RWMutex
 	gauges gaugesMap
+	// epoch is incremented every time gauges is reset by a flush so handles know they must re-attach.
+	epoch uint64
 }
 
 type gaugeShard struct {
@@ -53,6 +57,8 @@ type gaugeShard struct {
 type setShardInner struct {
 	sync.RWMutex
 	sets setsMap
+	// epoch is incremented every time sets is reset by a flush so handles know they must re-attach.
+	epoch uint64
 }
 
 type setShard struct {
@@ -74,6 +80,30 @@ type aggregator struct {
 	distributions bufferedMetricContexts
 	timings       bufferedMetricContexts
 
+	// limiter is set when the number of contexts is limited, see WithMaxContexts.
+	limiter *contextLimiter
+	// timestamped is set when timestamped counts and gauges are aggregated, see WithTimestampAggregation.
+	timestamped *timestampedContexts
+	// setEstimationThreshold is the number of unique values above which sets are estimated, 0 when they are
+	// always exact. See WithSetCardinalityEstimation.
+	setEstimationThreshold int
+	// flushObserver is called with the metrics of every flush, see WithFlushObserver.
+	flushObserver func([]FlushedMetric)
+	// flushInterval is the length of the aggregation windows, alignFlushes is set when they are aligned on the
+	// multiples of flushInterval and windowTimestamps when the start of the window is sent as the timestamp of counts
+	// and gauges. See WithAlignedAggregationFlush and WithAggregationWindowTimestamp.
+	flushInterval    time.Duration
+	alignFlushes     bool
+	windowTimestamps bool
+	// lastFlush and lastStamp are the time of the last flush and the last timestamp set by stampWindow, so that a
+	// window flushed several times, with Flush or Close, doesn't send two points of a context with the same timestamp.
+	stampLock sync.Mutex
+	lastFlush time.Time
+	lastStamp int64
+	// gaugeAggregation is the aggregation of the gauges sampled without a GaugeAggregation parameter, see
+	// WithGaugeAggregation.
+	gaugeAggregation GaugeAggregation
+
 	closed chan struct{}
 
 	client *ClientEx
@@ -103,7 +133,19 @@ func newAggregator(c *ClientEx, maxSamplesPerContext int64, shardsCount int) *ag
 	return agg
 }
 
+// limitContexts limits the contexts of every metric type with limiter.
+func (a *aggregator) limitContexts(limiter *contextLimiter) {
+	a.limiter = limiter
+	a.histograms.limiter = limiter
+	a.distributions.limiter = limiter
+	a.timings.limiter = limiter
+}
+
 func (a *aggregator) start(flushInterval time.Duration) {
+	if a.alignFlushes {
+		a.startAligned(flushInterval)
+		return
+	}
 	ticker := time.NewTicker(flushInterval)
 
 	go func() {
@@ -119,6 +161,36 @@ func (a *aggregator) start(flushInterval time.Duration) {
 	}()
 }
 
+// startAligned flushes on the multiples of flushInterval since the Unix epoch instead of at a random offset, so that
+// the clients of a fleet aggregate the same windows, see WithAlignedAggregationFlush.
+func (a *aggregator) startAligned(flushInterval time.Duration) {
+	next := windowStart(time.Now(), flushInterval).Add(flushInterval)
+	timer := time.NewTimer(time.Until(next))
+
+	go func() {
+		for {
+			select {
+			case <-timer.C:
+				a.flushWindow(next.Add(-flushInterval))
+				next = windowStart(time.Now(), flushInterval).Add(flushInterval)
+				timer.Reset(time.Until(next))
+			case <-a.closed:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// windowStart returns the start of the aggregation window containing t.
+func windowStart(t time.Time, flushInterval time.Duration) time.Time {
+	if flushInterval <= 0 {
+		return t
+	}
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(flushInterval))
+}
+
 func (a *aggregator) startReceivingMetric(bufferSize int, nbWorkers int) {
 	a.inputMetrics = make(chan metric, bufferSize)
 	for i := 0; i < nbWorkers; i++ {
@@ -156,9 +228,51 @@ func (a *aggregator) pullMetric() {
 }
 
 func (a *aggregator) flush() {
-	for _, m := range a.flushMetrics() {
+	a.flushWindow(windowStart(time.Now(), a.flushInterval))
+}
+
+// flushWindow flushes the metrics aggregated during the window starting at start.
+func (a *aggregator) flushWindow(start time.Time) {
+	metrics := a.flushMetrics()
+	a.stampWindow(metrics, start, time.Now())
+	for _, m := range metrics {
 		a.client.sendBlocking(m)
 	}
+	a.observe(metrics, a.client.namespace, a.client.tags)
+}
+
+// stampWindow sets the start of the aggregation window as the timestamp of the counts and gauges that don't have
+// one when WithAggregationWindowTimestamp is used. The other types of metrics don't support timestamps.
+//
+// When the window was already flushed at some point, by a call to Flush for example, the metrics flushed at now were
+// aggregated since that flush so its time is used instead of the start of the window. The timestamp is also later than
+// the previous one when possible: the intake keeps the last point of a gauge and doesn't add up the points of a count
+// sharing the same timestamp. It is never later than now, which would make it drift into the future when flushing
+// more than once per second.
+func (a *aggregator) stampWindow(metrics []metric, start time.Time, now time.Time) {
+	if !a.windowTimestamps {
+		return
+	}
+	a.stampLock.Lock()
+	if a.lastFlush.After(start) {
+		start = a.lastFlush
+	}
+	stamp := start.Unix()
+	if stamp <= a.lastStamp {
+		stamp = a.lastStamp + 1
+	}
+	if stamp > now.Unix() {
+		stamp = now.Unix()
+	}
+	a.lastFlush = now
+	a.lastStamp = stamp
+	a.stampLock.Unlock()
+
+	for i := range metrics {
+		if (metrics[i].metricType == count || metrics[i].metricType == gauge) && metrics[i].timestamp == noTimestamp {
+			metrics[i].timestamp = stamp
+		}
+	}
 }
 
 func (a *aggregator) flushTelemetryMetrics(t *Telemetry) {
@@ -173,10 +287,14 @@ func (a *aggregator) flushTelemetryMetrics(t *Telemetry) {
 	t.AggregationNbContextHistogram = a.histograms.getNbContext()
 	t.AggregationNbContextDistribution = a.distributions.getNbContext()
 	t.AggregationNbContextTiming = a.timings.getNbContext()
+	if a.limiter != nil {
+		t.AggregationContextsOverflowed = atomic.LoadUint64(&a.limiter.overflowed)
+	}
 }
 
 func (a *aggregator) flushMetrics() []metric {
 	metrics := []metric{}
+	a.limiter.reset()
 
 	// We reset the values to avoid sending 'zero' values for metrics not
 	// sampled during this flush interval
@@ -190,6 +308,7 @@ func (a *aggregator) flushMetrics() []metric {
 			continue
 		}
 		shard.sets = nil
+		shard.epoch++
 		shard.Unlock()
 		for _, s := range sets {
 			metrics = append(metrics, s.flushUnsafe()...)
@@ -206,6 +325,7 @@ func (a *aggregator) flushMetrics() []metric {
 			continue
 		}
 		shard.gauges = nil
+		shard.epoch++
 		shard.Unlock()
 		for _, g := range gauges {
 			metrics = append(metrics, g.flushUnsafe())
@@ -222,6 +342,7 @@ func (a *aggregator) flushMetrics() []metric {
 			continue
 		}
 		shard.counts = nil
+		shard.epoch++
 		shard.Unlock()
 		for _, c := range counts {
 			metrics = append(metrics, c.flushUnsafe())
@@ -232,6 +353,9 @@ func (a *aggregator) flushMetrics() []metric {
 	metrics = a.histograms.flush(metrics)
 	metrics = a.distributions.flush(metrics)
 	metrics = a.timings.flush(metrics)
+	if a.timestamped != nil {
+		metrics = a.flushTimestamped(metrics)
+	}
 
 	return metrics
 }
@@ -327,7 +451,7 @@ func (a *aggregator) count(name string, value int64, tags []string, cardinality
 	contextLen := getContextLength(name, tags, cardString)
 	if contextLen <= smallContextBufferSize {
 		var contextBuffer [smallContextBufferSize]byte
-		return a.countWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.countWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, false)
 	}
 	return a.countWithLargeContextBuffer(contextLen, name, value, tags, cardinality, cardString)
 }
@@ -337,12 +461,12 @@ func (a *aggregator) count(name string, value int64, tags []string, cardinality
 func (a *aggregator) countWithLargeContextBuffer(contextLen int, name string, value int64, tags []string, cardinality Cardinality, cardString string) error {
 	if contextLen <= largeContextBufferSize {
 		var contextBuffer [largeContextBufferSize]byte
-		return a.countWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.countWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, false)
 	}
-	return a.countWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString)
+	return a.countWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString, false)
 }
 
-func (a *aggregator) countWithContextBuffer(contextBuffer []byte, name string, value int64, tags []string, cardinality Cardinality, cardString string) error {
+func (a *aggregator) countWithContextBuffer(contextBuffer []byte, name string, value int64, tags []string, cardinality Cardinality, cardString string, overflowContext bool) error {
 	contextHash := uint32(0)
 	if a.shardsCount > 1 {
 		contextBuffer, contextHash = appendContextAndHash(contextBuffer, name, tags, cardString)
@@ -367,6 +491,11 @@ func (a *aggregator) countWithContextBuffer(contextBuffer []byte, name string, v
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, overflowContext); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.countOverflow(name, value, cardinality)
+	}
 
 	if shard.counts == nil {
 		shard.counts = countsMap{}
@@ -397,6 +526,11 @@ func (a *aggregator) countWithStringContext(context string, contextHash uint32,
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, false); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.countOverflow(name, value, CardinalityNotSet)
+	}
 
 	if shard.counts == nil {
 		shard.counts = countsMap{}
@@ -406,35 +540,54 @@ func (a *aggregator) countWithStringContext(context string, contextHash uint32,
 	return nil
 }
 
+// countOverflow aggregates the sample in the overflow context of the metric, see contextLimiter.
+func (a *aggregator) countOverflow(name string, value int64, cardinality Cardinality) error {
+	cardString := cardinality.String()
+	contextBuffer := make([]byte, 0, getContextLength(name, overflowTags, cardString))
+	return a.countWithContextBuffer(contextBuffer, name, value, overflowTags, cardinality, cardString, true)
+}
+
 func (a *aggregator) gauge(name string, value float64, tags []string, cardinality Cardinality) error {
+	return a.gaugeWithAggregation(name, value, tags, cardinality, a.gaugeAggregation)
+}
+
+func (a *aggregator) newGaugeMetric(name string, value float64, tags []string, cardinality Cardinality, aggregation GaugeAggregation) *gaugeMetric {
+	gauge := newGaugeMetric(name, value, tags, cardinality)
+	gauge.aggregation = aggregation
+	return gauge
+}
+
+// gaugeWithAggregation samples a gauge with the given aggregation. The aggregation of a context is the one of the
+// sample that created it during the interval.
+func (a *aggregator) gaugeWithAggregation(name string, value float64, tags []string, cardinality Cardinality, aggregation GaugeAggregation) error {
 	if len(tags) == 0 && cardinality == CardinalityNotSet {
 		contextHash := uint32(0)
 		if a.shardsCount > 1 {
 			contextHash = hashString32(name)
 		}
-		return a.gaugeWithStringContext(name, contextHash, name, value)
+		return a.gaugeWithStringContext(name, contextHash, name, value, aggregation)
 	}
 
 	cardString := cardinality.String()
 	contextLen := getContextLength(name, tags, cardString)
 	if contextLen <= smallContextBufferSize {
 		var contextBuffer [smallContextBufferSize]byte
-		return a.gaugeWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.gaugeWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, aggregation, false)
 	}
-	return a.gaugeWithLargeContextBuffer(contextLen, name, value, tags, cardinality, cardString)
+	return a.gaugeWithLargeContextBuffer(contextLen, name, value, tags, cardinality, cardString, aggregation)
 }
 
 // gaugeWithLargeContextBuffer keeps the 4 KiB stack array out of gauge's frame
 // so the common small-context path is not penalized by the larger frame.
-func (a *aggregator) gaugeWithLargeContextBuffer(contextLen int, name string, value float64, tags []string, cardinality Cardinality, cardString string) error {
+func (a *aggregator) gaugeWithLargeContextBuffer(contextLen int, name string, value float64, tags []string, cardinality Cardinality, cardString string, aggregation GaugeAggregation) error {
 	if contextLen <= largeContextBufferSize {
 		var contextBuffer [largeContextBufferSize]byte
-		return a.gaugeWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.gaugeWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, aggregation, false)
 	}
-	return a.gaugeWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString)
+	return a.gaugeWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString, aggregation, false)
 }
 
-func (a *aggregator) gaugeWithContextBuffer(contextBuffer []byte, name string, value float64, tags []string, cardinality Cardinality, cardString string) error {
+func (a *aggregator) gaugeWithContextBuffer(contextBuffer []byte, name string, value float64, tags []string, cardinality Cardinality, cardString string, aggregation GaugeAggregation, overflowContext bool) error {
 	contextHash := uint32(0)
 	if a.shardsCount > 1 {
 		contextBuffer, contextHash = appendContextAndHash(contextBuffer, name, tags, cardString)
@@ -450,7 +603,7 @@ func (a *aggregator) gaugeWithContextBuffer(contextBuffer []byte, name string, v
 	}
 	shard.RUnlock()
 
-	gauge := newGaugeMetric(name, value, tags, cardinality)
+	gauge := a.newGaugeMetric(name, value, tags, cardinality, aggregation)
 
 	shard.Lock()
 	// Check if another goroutines hasn't created the value between the 'RUnlock' and 'Lock'
@@ -459,6 +612,11 @@ func (a *aggregator) gaugeWithContextBuffer(contextBuffer []byte, name string, v
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, overflowContext); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.gaugeOverflow(name, value, cardinality, aggregation)
+	}
 	if shard.gauges == nil {
 		shard.gauges = gaugesMap{}
 	}
@@ -469,7 +627,7 @@ func (a *aggregator) gaugeWithContextBuffer(contextBuffer []byte, name string, v
 }
 
 // handles the no-tags/no-cardinality fast path where the context key is the metric name itself.
-func (a *aggregator) gaugeWithStringContext(context string, contextHash uint32, name string, value float64) error {
+func (a *aggregator) gaugeWithStringContext(context string, contextHash uint32, name string, value float64, aggregation GaugeAggregation) error {
 	shard := &a.gaugeShards[getShardIndexFromHash(a.shardsCount, contextHash)]
 	shard.RLock()
 	if gauge, found := shard.gauges[context]; found {
@@ -479,7 +637,7 @@ func (a *aggregator) gaugeWithStringContext(context string, contextHash uint32,
 	}
 	shard.RUnlock()
 
-	gauge := newGaugeMetric(name, value, nil, CardinalityNotSet)
+	gauge := a.newGaugeMetric(name, value, nil, CardinalityNotSet, aggregation)
 
 	shard.Lock()
 	// Check if another goroutines hasn't created the value between the 'RUnlock' and 'Lock'
@@ -488,6 +646,11 @@ func (a *aggregator) gaugeWithStringContext(context string, contextHash uint32,
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, false); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.gaugeOverflow(name, value, CardinalityNotSet, aggregation)
+	}
 	if shard.gauges == nil {
 		shard.gauges = gaugesMap{}
 	}
@@ -496,6 +659,19 @@ func (a *aggregator) gaugeWithStringContext(context string, contextHash uint32,
 	return nil
 }
 
+// gaugeOverflow aggregates the sample in the overflow context of the metric, see contextLimiter.
+func (a *aggregator) gaugeOverflow(name string, value float64, cardinality Cardinality, aggregation GaugeAggregation) error {
+	cardString := cardinality.String()
+	contextBuffer := make([]byte, 0, getContextLength(name, overflowTags, cardString))
+	return a.gaugeWithContextBuffer(contextBuffer, name, value, overflowTags, cardinality, cardString, aggregation, true)
+}
+
+func (a *aggregator) newSetMetric(name string, value string, tags []string, cardinality Cardinality) *setMetric {
+	set := newSetMetric(name, value, tags, cardinality)
+	set.estimationThreshold = a.setEstimationThreshold
+	return set
+}
+
 func (a *aggregator) set(name string, value string, tags []string, cardinality Cardinality) error {
 	if len(tags) == 0 && cardinality == CardinalityNotSet {
 		contextHash := uint32(0)
@@ -509,7 +685,7 @@ func (a *aggregator) set(name string, value string, tags []string, cardinality C
 	contextLen := getContextLength(name, tags, cardString)
 	if contextLen <= smallContextBufferSize {
 		var contextBuffer [smallContextBufferSize]byte
-		return a.setWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.setWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, false)
 	}
 	return a.setWithLargeContextBuffer(contextLen, name, value, tags, cardinality, cardString)
 }
@@ -519,12 +695,12 @@ func (a *aggregator) set(name string, value string, tags []string, cardinality C
 func (a *aggregator) setWithLargeContextBuffer(contextLen int, name string, value string, tags []string, cardinality Cardinality, cardString string) error {
 	if contextLen <= largeContextBufferSize {
 		var contextBuffer [largeContextBufferSize]byte
-		return a.setWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString)
+		return a.setWithContextBuffer(contextBuffer[:0], name, value, tags, cardinality, cardString, false)
 	}
-	return a.setWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString)
+	return a.setWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, cardinality, cardString, false)
 }
 
-func (a *aggregator) setWithContextBuffer(contextBuffer []byte, name string, value string, tags []string, cardinality Cardinality, cardString string) error {
+func (a *aggregator) setWithContextBuffer(contextBuffer []byte, name string, value string, tags []string, cardinality Cardinality, cardString string, overflowContext bool) error {
 	contextHash := uint32(0)
 	if a.shardsCount > 1 {
 		contextBuffer, contextHash = appendContextAndHash(contextBuffer, name, tags, cardString)
@@ -540,7 +716,7 @@ func (a *aggregator) setWithContextBuffer(contextBuffer []byte, name string, val
 	}
 	shard.RUnlock()
 
-	metric := newSetMetric(name, value, tags, cardinality)
+	metric := a.newSetMetric(name, value, tags, cardinality)
 
 	shard.Lock()
 	// Check if another goroutines hasn't created the value between the 'RUnlock' and 'Lock'
@@ -549,6 +725,11 @@ func (a *aggregator) setWithContextBuffer(contextBuffer []byte, name string, val
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, overflowContext); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.setOverflow(name, value, cardinality)
+	}
 	if shard.sets == nil {
 		shard.sets = setsMap{}
 	}
@@ -569,7 +750,7 @@ func (a *aggregator) setWithStringContext(context string, contextHash uint32, na
 	}
 	shard.RUnlock()
 
-	metric := newSetMetric(name, value, nil, CardinalityNotSet)
+	metric := a.newSetMetric(name, value, nil, CardinalityNotSet)
 
 	shard.Lock()
 	// Check if another goroutines hasn't created the value between the 'RUnlock' and 'Lock'
@@ -578,6 +759,11 @@ func (a *aggregator) setWithStringContext(context string, contextHash uint32, na
 		shard.Unlock()
 		return nil
 	}
+	if admitted, overflow := a.limiter.admit(name, false); !admitted {
+		shard.Unlock()
+		a.limiter.report(overflow)
+		return a.setOverflow(name, value, CardinalityNotSet)
+	}
 	if shard.sets == nil {
 		shard.sets = setsMap{}
 	}
@@ -593,6 +779,13 @@ func (a *aggregator) setWithStringContext(context string, contextHash uint32, na
 // type alias for Client.sendToAggregator
 type bufferedMetricSampleFunc func(name string, value float64, tags []string, rate float64, cardinality Cardinality) error
 
+// setOverflow aggregates the sample in the overflow context of the metric, see contextLimiter.
+func (a *aggregator) setOverflow(name string, value string, cardinality Cardinality) error {
+	cardString := cardinality.String()
+	contextBuffer := make([]byte, 0, getContextLength(name, overflowTags, cardString))
+	return a.setWithContextBuffer(contextBuffer, name, value, overflowTags, cardinality, cardString, true)
+}
+
 func (a *aggregator) histogram(name string, value float64, tags []string, rate float64, cardinality Cardinality) error {
 	return a.histograms.sample(name, value, tags, rate, cardinality)
 }
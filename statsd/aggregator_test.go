@@ -918,3 +918,139 @@ func TestAggregatorContextBufferSecondCheckLocking(t *testing.T) {
 	})
 }
 
+
+func TestWindowStart(t *testing.T) {
+	assert.Equal(t, time.Unix(1002, 0), windowStart(time.Unix(1003, int64(500*time.Millisecond)), 2*time.Second))
+	assert.Equal(t, time.Unix(1002, 0), windowStart(time.Unix(1002, 0), 2*time.Second))
+	assert.Equal(t, time.Unix(1000, int64(200*time.Millisecond)), windowStart(time.Unix(1000, int64(300*time.Millisecond)), 200*time.Millisecond))
+	now := time.Now()
+	assert.Equal(t, now, windowStart(now, 0))
+}
+
+func TestAggregatorStampWindow(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.count("count", 1, nil, CardinalityNotSet)
+	a.gauge("gauge", 2, nil, CardinalityNotSet)
+	a.set("set", "value", nil, CardinalityNotSet)
+
+	metrics := a.flushMetrics()
+	a.stampWindow(metrics, time.Unix(1000, 0), time.Unix(1000, 0))
+	for _, m := range metrics {
+		assert.Equal(t, noTimestamp, m.timestamp)
+	}
+
+	a.windowTimestamps = true
+	metrics = append(metrics, metric{metricType: count, name: "timestamped", timestamp: 500})
+	a.stampWindow(metrics, time.Unix(1000, 0), time.Unix(1000, 0))
+	timestamps := map[string]int64{}
+	for _, m := range metrics {
+		timestamps[m.name] = m.timestamp
+	}
+	assert.Equal(t, map[string]int64{"count": 1000, "gauge": 1000, "set": noTimestamp, "timestamped": 500}, timestamps)
+}
+
+func TestAlignedAggregationFlush(t *testing.T) {
+	interval := 200 * time.Millisecond
+	flushes := make(chan time.Time, 10)
+	client, err := NewEx("localhost:8775",
+		WithAggregationInterval(interval),
+		WithAlignedAggregationFlush(),
+		WithFlushObserver(func([]FlushedMetric) { flushes <- time.Now() }),
+		WithoutTelemetry(),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, client.Count("count", 1, nil, 1))
+		select {
+		case flushed := <-flushes:
+			offset := flushed.Sub(windowStart(flushed, interval))
+			assert.True(t, offset < interval/2, "flushed %s after the window boundary", offset)
+		case <-time.After(time.Second):
+			require.Fail(t, "no aggregation flush")
+		}
+	}
+}
+
+func TestAggregationWindowTimestamp(t *testing.T) {
+	o, err := resolveOptions([]Option{WithAggregationWindowTimestamp()})
+	require.NoError(t, err)
+	assert.True(t, o.aggregation)
+	assert.True(t, o.alignedAggregationFlush)
+
+	c, err := NewRecordingClient(WithAggregationWindowTimestamp(), WithAggregationInterval(10*time.Second))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Count("count", 1, nil, 1))
+	before := windowStart(time.Now(), 10*time.Second)
+	require.NoError(t, c.Flush())
+	after := windowStart(time.Now(), 10*time.Second)
+
+	metrics := c.FindMetrics("count")
+	require.Len(t, metrics, 1)
+	assert.Contains(t, []time.Time{before, after}, metrics[0].Timestamp)
+}
+
+func TestAggregationWindowTimestampManualFlush(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.windowTimestamps = true
+	stamp := func(start, now int64) int64 {
+		metrics := []metric{{metricType: gauge, name: "gauge"}}
+		a.stampWindow(metrics, time.Unix(start, 0), time.Unix(now, 0))
+		return metrics[0].timestamp
+	}
+
+	// a Flush in the middle of the window, then the aligned flush at the end of the window
+	assert.Equal(t, int64(1000), stamp(1000, 1004))
+	assert.Equal(t, int64(1004), stamp(1000, 1010))
+	// the next window isn't affected
+	assert.Equal(t, int64(1010), stamp(1010, 1020))
+	// two flushes during the same second don't share a timestamp
+	assert.Equal(t, int64(1020), stamp(1020, 1020))
+	assert.Equal(t, int64(1021), stamp(1020, 1030))
+}
+
+func TestAggregationWindowTimestampSubSecondFlushes(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.windowTimestamps = true
+
+	interval := 500 * time.Millisecond
+	base := time.Unix(1000, 0)
+	for i := 0; i < 100; i++ {
+		start := base.Add(time.Duration(i) * interval)
+		now := start.Add(interval)
+		metrics := []metric{{metricType: count, name: "count"}}
+		a.stampWindow(metrics, start, now)
+		assert.True(t, metrics[0].timestamp <= now.Unix(), "flush %d stamped %d at %d", i, metrics[0].timestamp, now.Unix())
+	}
+}
+
+func TestAggregationWindowTimestampInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond} {
+		_, err := resolveOptions([]Option{WithAggregationWindowTimestamp(), WithAggregationInterval(interval)})
+		assert.EqualError(t, err, "WithAggregationWindowTimestamp requires an aggregation interval of a whole number of seconds, got "+interval.String())
+	}
+	_, err := resolveOptions([]Option{WithAggregationInterval(3 * time.Second), WithAggregationWindowTimestamp()})
+	assert.NoError(t, err)
+	_, err = resolveOptions([]Option{WithAggregationInterval(500 * time.Millisecond)})
+	assert.NoError(t, err)
+}
+
+func TestRecordingClientWindowTimestampManualFlush(t *testing.T) {
+	c, err := NewRecordingClient(WithAggregationWindowTimestamp(), WithAggregationInterval(time.Hour))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, c.Count("count", 1, nil, 1))
+	require.NoError(t, c.Flush())
+	require.NoError(t, c.Gauge("gauge", 2, nil, 1))
+	require.NoError(t, c.Count("count", 1, nil, 1))
+	require.NoError(t, c.Flush())
+
+	for _, name := range []string{"gauge", "count"} {
+		metrics := c.FindMetrics(name)
+		require.Len(t, metrics, 2)
+		assert.True(t, metrics[1].Timestamp.After(metrics[0].Timestamp), name)
+	}
+}
@@ -29,6 +29,8 @@ type statsdBuffer struct {
 	maxSize      int
 	maxElements  int
 	elementCount int
+	// features are the protocol features supported by the agent, the fields it can't parse are omitted.
+	features agentFeatures
 }
 
 func newStatsdBuffer(maxSize, maxElements int) *statsdBuffer {
@@ -36,6 +38,7 @@ func newStatsdBuffer(maxSize, maxElements int) *statsdBuffer {
 		buffer:      make([]byte, 0, maxSize+metricOverhead), // pre-allocate the needed size + metricOverhead to avoid having Go re-allocate on it's own if an element does not fit
 		maxSize:     maxSize,
 		maxElements: maxElements,
+		features:    allAgentFeatures,
 	}
 }
 
@@ -44,9 +47,9 @@ func (b *statsdBuffer) writeGauge(namespace string, globalTags []string, name st
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendGauge(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTimestamp(b.buffer, timestamp)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendGauge(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTimestamp(b.buffer, timestamp, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -56,9 +59,9 @@ func (b *statsdBuffer) writeCount(namespace string, globalTags []string, name st
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendCount(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTimestamp(b.buffer, timestamp)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendCount(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTimestamp(b.buffer, timestamp, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -68,8 +71,8 @@ func (b *statsdBuffer) writeHistogram(namespace string, globalTags []string, nam
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendHistogram(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendHistogram(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -81,15 +84,15 @@ func (b *statsdBuffer) writeAggregated(metricSymbol []byte, namespace string, gl
 	}
 
 	// Augment tagSize with trailing fields the caller's extraSize estimate omits.
-	if containerID := getContainerID(); len(containerID) > 0 {
+	if containerID := getContainerID(); len(containerID) > 0 && b.features.has(agentFeatureContainerID) {
 		tagSize += 3 + len(containerID) // "|c:" + containerID
 	}
-	if originDetection {
+	if originDetection && b.features.has(agentFeatureExternalEnv) {
 		if externalEnv := getExternalEnv(); externalEnv != "" {
 			tagSize += 3 + len(externalEnv) // "|e:" + externalEnv
 		}
 	}
-	if cardString := cardinality.String(); cardString != "" {
+	if cardString := cardinality.String(); cardString != "" && b.features.has(agentFeatureCardinality) {
 		tagSize += 6 + len(cardString) // "|card:" + cardString
 	}
 
@@ -130,9 +133,9 @@ func (b *statsdBuffer) writeAggregated(metricSymbol []byte, namespace string, gl
 	b.buffer = append(b.buffer, metricSymbol...)
 	b.buffer = appendRate(b.buffer, rate)
 	b.buffer = appendTagsAggregated(b.buffer, globalTags, tags)
-	b.buffer = appendContainerID(b.buffer)
-	b.buffer = appendExternalEnv(b.buffer, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendContainerID(b.buffer, b.features)
+	b.buffer = appendExternalEnv(b.buffer, originDetection && b.features.has(agentFeatureExternalEnv))
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	b.elementCount++
 
@@ -148,8 +151,8 @@ func (b *statsdBuffer) writeDistribution(namespace string, globalTags []string,
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendDistribution(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendDistribution(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -159,8 +162,8 @@ func (b *statsdBuffer) writeSet(namespace string, globalTags []string, name stri
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendSet(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendSet(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -170,8 +173,8 @@ func (b *statsdBuffer) writeTiming(namespace string, globalTags []string, name s
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendTiming(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendTiming(b.buffer, namespace, globalTags, name, value, tags, rate, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -181,8 +184,8 @@ func (b *statsdBuffer) writeEvent(event *Event, globalTags []string, originDetec
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendEvent(b.buffer, event, globalTags, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendEvent(b.buffer, event, globalTags, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
@@ -192,8 +195,8 @@ func (b *statsdBuffer) writeServiceCheck(serviceCheck *ServiceCheck, globalTags
 		return errBufferFull
 	}
 	originalBuffer := b.buffer
-	b.buffer = appendServiceCheck(b.buffer, serviceCheck, globalTags, originDetection)
-	b.buffer = appendTagCardinality(b.buffer, cardinality)
+	b.buffer = appendServiceCheck(b.buffer, serviceCheck, globalTags, originDetection, b.features)
+	b.buffer = appendTagCardinality(b.buffer, cardinality, b.features)
 	b.writeSeparator()
 	return b.validateNewElement(originalBuffer)
 }
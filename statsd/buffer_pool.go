@@ -4,6 +4,8 @@ type bufferPool struct {
 	pool              chan *statsdBuffer
 	bufferMaxSize     int
 	bufferMaxElements int
+	// features are set on the borrowed buffers, see WithAgentVersion.
+	features agentFeatures
 }
 
 func newBufferPool(poolSize, bufferMaxSize, bufferMaxElements int) *bufferPool {
@@ -11,6 +13,7 @@ func newBufferPool(poolSize, bufferMaxSize, bufferMaxElements int) *bufferPool {
 		pool:              make(chan *statsdBuffer, poolSize),
 		bufferMaxSize:     bufferMaxSize,
 		bufferMaxElements: bufferMaxElements,
+		features:          allAgentFeatures,
 	}
 	for i := 0; i < poolSize; i++ {
 		p.addNewBuffer()
@@ -23,12 +26,14 @@ func (p *bufferPool) addNewBuffer() {
 }
 
 func (p *bufferPool) borrowBuffer() *statsdBuffer {
+	var b *statsdBuffer
 	select {
-	case b := <-p.pool:
-		return b
+	case b = <-p.pool:
 	default:
-		return newStatsdBuffer(p.bufferMaxSize, p.bufferMaxElements)
+		b = newStatsdBuffer(p.bufferMaxSize, p.bufferMaxElements)
 	}
+	b.features = p.features
+	return b
 }
 
 func (p *bufferPool) returnBuffer(buffer *statsdBuffer) {
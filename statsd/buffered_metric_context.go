@@ -14,7 +14,15 @@ type bufferedMetricContexts struct {
 	nbContext uint64
 	mutex     sync.RWMutex
 	values    bufferedMetricMap
+	// epoch is incremented every time values is reset by a flush so handles know they must re-attach.
+	epoch     uint64
 	newMetric func(string, float64, string, float64, Cardinality) *bufferedMetric
+	// summary is set when the metrics are summarized on the client instead of sending their samples.
+	summary *histogramSummary
+	// sketch is set when the metrics are accumulated in sketches instead of keeping their samples.
+	sketch *sketchMapping
+	// limiter is set when the number of contexts is limited, see WithMaxContexts.
+	limiter *contextLimiter
 
 	// Each bufferedMetricContexts uses its own random source and random
 	// lock to prevent goroutines from contending for the lock on the
@@ -43,26 +51,43 @@ func (bc *bufferedMetricContexts) flush(metrics []metric) []metric {
 	bc.mutex.Lock()
 	values := bc.values
 	bc.values = bufferedMetricMap{}
+	bc.epoch++
 	bc.mutex.Unlock()
 
 	for _, d := range values {
 		d.Lock()
-		metrics = append(metrics, d.flushUnsafe())
+		if d.summary != nil {
+			metrics = d.summarizeUnsafe(metrics)
+		} else if d.sketch != nil {
+			metrics = d.flushSketchUnsafe(metrics)
+		} else {
+			metrics = d.flushUnsafe(metrics)
+		}
 		d.Unlock()
 	}
 	atomic.AddUint64(&bc.nbContext, uint64(len(values)))
 	return metrics
 }
 
+func (bc *bufferedMetricContexts) newContext(name string, value float64, stringTags string, rate float64, cardinality Cardinality) *bufferedMetric {
+	m := bc.newMetric(name, value, stringTags, rate, cardinality)
+	if bc.summary != nil {
+		m.summary = bc.summary
+		m.stats = newSummaryStats(value, sampleWeight(rate))
+	}
+	if bc.sketch != nil {
+		m.sketch = newDDSketch(bc.sketch)
+		m.sketch.add(value, sampleWeight(rate))
+		m.data = nil
+		m.storedSamples = 0
+	}
+	return m
+}
+
 func (bc *bufferedMetricContexts) sample(name string, value float64, tags []string, rate float64, cardinality Cardinality) error {
 	// For user-sampled metrics, return early when the sample is dropped. If we
-	// keep it, the metric stores the first observed sampling rate. This matches
-	// the existing behavior; correcting it would require tracking later rates
-	// without adding contention on this hot path.
-	// TODO: change this behavior in the future, probably by introducing
-	// thread-local storage and lockless structures. If this early return is
-	// removed, also remove the observed sampling rate in bufferedMetric and fix
-	// bufferedMetric.flushUnsafe.
+	// keep it, the metric records its sampling rate so the flushed values
+	// carry the right rate even when a context is sampled with several rates.
 	if rate < 1 && !shouldSample(rate, bc.random, &bc.randomLock) {
 		return nil
 	}
@@ -72,7 +97,7 @@ func (bc *bufferedMetricContexts) sample(name string, value float64, tags []stri
 		v := bc.values[name]
 		bc.mutex.RUnlock()
 		if v != nil {
-			v.maybeKeepSample(value, bc.random, &bc.randomLock)
+			v.maybeKeepSample(value, rate, bc.random, &bc.randomLock)
 			return nil
 		}
 
@@ -81,8 +106,13 @@ func (bc *bufferedMetricContexts) sample(name string, value float64, tags []stri
 		// It might have been created by another goroutine since last call
 		v = bc.values[name]
 		if v == nil {
+			if admitted, overflow := bc.limiter.admit(name, false); !admitted {
+				bc.mutex.Unlock()
+				bc.limiter.report(overflow)
+				return bc.sampleOverflow(name, value, rate, cardinality)
+			}
 			// If we might keep a sample that we should have skipped, but that should not drastically affect performances.
-			bc.values[name] = bc.newMetric(name, value, "", rate, cardinality)
+			bc.values[name] = bc.newContext(name, value, "", rate, cardinality)
 			// We added a new value, we need to unlock the mutex and quit
 			bc.mutex.Unlock()
 			return nil
@@ -90,7 +120,7 @@ func (bc *bufferedMetricContexts) sample(name string, value float64, tags []stri
 		bc.mutex.Unlock()
 
 		// Now we can keep the sample.
-		v.maybeKeepSample(value, bc.random, &bc.randomLock)
+		v.maybeKeepSample(value, rate, bc.random, &bc.randomLock)
 
 		return nil
 	}
@@ -99,7 +129,7 @@ func (bc *bufferedMetricContexts) sample(name string, value float64, tags []stri
 	contextLen := getContextLength(name, tags, cardString)
 	if contextLen <= smallContextBufferSize {
 		var contextBuffer [smallContextBufferSize]byte
-		return bc.sampleWithContextBuffer(contextBuffer[:0], name, value, tags, rate, cardinality, cardString)
+		return bc.sampleWithContextBuffer(contextBuffer[:0], name, value, tags, rate, cardinality, cardString, false)
 	}
 	return bc.sampleWithLargeContextBuffer(contextLen, name, value, tags, rate, cardinality, cardString)
 }
@@ -109,12 +139,12 @@ func (bc *bufferedMetricContexts) sample(name string, value float64, tags []stri
 func (bc *bufferedMetricContexts) sampleWithLargeContextBuffer(contextLen int, name string, value float64, tags []string, rate float64, cardinality Cardinality, cardString string) error {
 	if contextLen <= largeContextBufferSize {
 		var contextBuffer [largeContextBufferSize]byte
-		return bc.sampleWithContextBuffer(contextBuffer[:0], name, value, tags, rate, cardinality, cardString)
+		return bc.sampleWithContextBuffer(contextBuffer[:0], name, value, tags, rate, cardinality, cardString, false)
 	}
-	return bc.sampleWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, rate, cardinality, cardString)
+	return bc.sampleWithContextBuffer(make([]byte, 0, contextLen), name, value, tags, rate, cardinality, cardString, false)
 }
 
-func (bc *bufferedMetricContexts) sampleWithContextBuffer(contextBuffer []byte, name string, value float64, tags []string, rate float64, cardinality Cardinality, cardString string) error {
+func (bc *bufferedMetricContexts) sampleWithContextBuffer(contextBuffer []byte, name string, value float64, tags []string, rate float64, cardinality Cardinality, cardString string, overflowContext bool) error {
 	contextBuffer, tagsStart := appendContext(contextBuffer, name, tags, cardString)
 	var v *bufferedMetric
 
@@ -128,13 +158,18 @@ func (bc *bufferedMetricContexts) sampleWithContextBuffer(contextBuffer []byte,
 		// It might have been created by another goroutine since last call
 		v, _ = bc.values[string(contextBuffer)]
 		if v == nil {
+			if admitted, overflow := bc.limiter.admit(name, overflowContext); !admitted {
+				bc.mutex.Unlock()
+				bc.limiter.report(overflow)
+				return bc.sampleOverflow(name, value, rate, cardinality)
+			}
 			// If we might keep a sample that we should have skipped, but that should not drastically affect performances.
 			context := string(contextBuffer)
 			stringTags := ""
 			if tagsStart >= 0 {
 				stringTags = context[tagsStart:]
 			}
-			bc.values[context] = bc.newMetric(name, value, stringTags, rate, cardinality)
+			bc.values[context] = bc.newContext(name, value, stringTags, rate, cardinality)
 			// We added a new value, we need to unlock the mutex and quit
 			bc.mutex.Unlock()
 			return nil
@@ -143,11 +178,18 @@ func (bc *bufferedMetricContexts) sampleWithContextBuffer(contextBuffer []byte,
 	}
 
 	// Now we can keep the sample.
-	v.maybeKeepSample(value, bc.random, &bc.randomLock)
+	v.maybeKeepSample(value, rate, bc.random, &bc.randomLock)
 
 	return nil
 }
 
+// sampleOverflow keeps the sample in the overflow context of the metric, see contextLimiter.
+func (bc *bufferedMetricContexts) sampleOverflow(name string, value float64, rate float64, cardinality Cardinality) error {
+	cardString := cardinality.String()
+	contextBuffer := make([]byte, 0, getContextLength(name, overflowTags, cardString))
+	return bc.sampleWithContextBuffer(contextBuffer, name, value, overflowTags, rate, cardinality, cardString, true)
+}
+
 func (bc *bufferedMetricContexts) getNbContext() uint64 {
 	return atomic.LoadUint64(&bc.nbContext)
 }
@@ -0,0 +1,177 @@
+package statsd
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+)
+
+// Compression is an algorithm used to compress the payloads written to stream transports. See WithCompression.
+type Compression byte
+
+const (
+	// CompressionFlate compresses payloads with DEFLATE (RFC 1951).
+	CompressionFlate Compression = 1
+	// CompressionGzip compresses payloads with gzip (RFC 1952).
+	CompressionGzip Compression = 2
+)
+
+// compressedPayloadMagic starts the header of compressed payloads. A NUL byte can't start a DogStatsD payload, which
+// allows to tell compressed payloads from raw ones. The header ends with the Compression used.
+var compressedPayloadMagic = []byte{0, 'D', 'Z'}
+
+const compressedPayloadHeaderSize = 4
+
+// maxFrameSize is the size of the largest frame ReadPayload accepts: a payload of MaxUDPPayloadSize bytes with the
+// compression header and room for the worst case overhead of DEFLATE stored blocks and of the gzip header and trailer.
+const maxFrameSize = compressedPayloadHeaderSize + MaxUDPPayloadSize + 1024
+
+// compressionTelemetry contains telemetry about the compressed transports of a client
+type compressionTelemetry struct {
+	totalBytesUncompressed uint64
+	totalBytesCompressed   uint64
+}
+
+// compressionConfig is created by WithCompression, each client gets its own telemetry.
+type compressionConfig struct {
+	algorithm Compression
+	level     int
+	telemetry *compressionTelemetry
+}
+
+// compressedWriter is a Transport compressing payloads before writing them to a stream transport, which frames them.
+//
+// compressedWriter is only used by the sender goroutine.
+type compressedWriter struct {
+	transport Transport
+	algorithm Compression
+	buffer    bytes.Buffer
+	flate     *flate.Writer
+	gzip      *gzip.Writer
+	telemetry *compressionTelemetry
+}
+
+func newCompressedWriter(transport Transport, config *compressionConfig) (*compressedWriter, error) {
+	w := &compressedWriter{
+		transport: transport,
+		algorithm: config.algorithm,
+		telemetry: config.telemetry,
+	}
+	var err error
+	switch config.algorithm {
+	case CompressionFlate:
+		w.flate, err = flate.NewWriter(&w.buffer, config.level)
+	case CompressionGzip:
+		w.gzip, err = gzip.NewWriterLevel(&w.buffer, config.level)
+	default:
+		err = fmt.Errorf("unknown compression %d", config.algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write compresses data and writes it, with its header, to the underlying transport.
+func (w *compressedWriter) Write(data []byte) (int, error) {
+	w.buffer.Reset()
+	w.buffer.Write(compressedPayloadMagic)
+	w.buffer.WriteByte(byte(w.algorithm))
+
+	var compressor io.WriteCloser
+	if w.flate != nil {
+		w.flate.Reset(&w.buffer)
+		compressor = w.flate
+	} else {
+		w.gzip.Reset(&w.buffer)
+		compressor = w.gzip
+	}
+	if _, err := compressor.Write(data); err != nil {
+		return 0, err
+	}
+	if err := compressor.Close(); err != nil {
+		return 0, err
+	}
+
+	if _, err := w.transport.Write(w.buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&w.telemetry.totalBytesUncompressed, uint64(len(data)))
+	atomic.AddUint64(&w.telemetry.totalBytesCompressed, uint64(w.buffer.Len()))
+	return len(data), nil
+}
+
+func (w *compressedWriter) Close() error {
+	return w.transport.Close()
+}
+
+// GetTransportName returns the name of the underlying transport.
+func (w *compressedWriter) GetTransportName() string {
+	return w.transport.GetTransportName()
+}
+
+func (c *compressionConfig) flushTelemetryMetrics(t *Telemetry) {
+	t.TotalBytesUncompressed = atomic.LoadUint64(&c.telemetry.totalBytesUncompressed)
+	t.TotalBytesCompressed = atomic.LoadUint64(&c.telemetry.totalBytesCompressed)
+}
+
+// DecodePayload returns the DogStatsD payload contained in a frame read from a stream transport, decompressing it if
+// it was compressed by a client using WithCompression. Frames of clients not using compression are returned as is.
+// An error is returned when a payload decompresses to more than MaxUDPPayloadSize bytes, the largest payload the agent
+// can receive.
+func DecodePayload(frame []byte) ([]byte, error) {
+	if !bytes.HasPrefix(frame, compressedPayloadMagic) {
+		return frame, nil
+	}
+	if len(frame) < compressedPayloadHeaderSize {
+		return nil, fmt.Errorf("truncated compressed payload header")
+	}
+
+	body := bytes.NewReader(frame[compressedPayloadHeaderSize:])
+	var reader io.ReadCloser
+	switch Compression(frame[compressedPayloadHeaderSize-1]) {
+	case CompressionFlate:
+		reader = flate.NewReader(body)
+	case CompressionGzip:
+		var err error
+		reader, err = gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression %d", frame[compressedPayloadHeaderSize-1])
+	}
+	defer reader.Close()
+	// A corrupted or hostile payload could otherwise decompress to an unbounded size.
+	payload, err := ioutil.ReadAll(io.LimitReader(reader, MaxUDPPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > MaxUDPPayloadSize {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes", MaxUDPPayloadSize)
+	}
+	return payload, nil
+}
+
+// ReadPayload reads a frame, prefixed with its length as a 4 bytes little-endian integer, from a stream transport
+// and returns the DogStatsD payload it contains. See DecodePayload. Frames longer than any frame a client can write
+// are rejected before being read.
+func ReadPayload(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds %d bytes", length, maxFrameSize)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return DecodePayload(frame)
+}
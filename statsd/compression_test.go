@@ -0,0 +1,186 @@
+package statsd
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedWriter(t *testing.T) {
+	for _, algorithm := range []Compression{CompressionFlate, CompressionGzip} {
+		config := &compressionConfig{algorithm: algorithm, level: flate.BestCompression, telemetry: &compressionTelemetry{}}
+		writer := &toggleWriter{}
+		w, err := newCompressedWriter(writer, config)
+		require.NoError(t, err)
+
+		payload := []byte("gauge:1|g|#tag\ngauge:2|g|#tag\ngauge:3|g|#tag\ngauge:4|g|#tag\n")
+		for i := 0; i < 2; i++ {
+			n, err := w.Write(payload)
+			require.NoError(t, err)
+			assert.Equal(t, len(payload), n)
+		}
+
+		require.Len(t, writer.written, 2)
+		// the compressor is reset between payloads
+		assert.Equal(t, writer.written[0], writer.written[1])
+		assert.Equal(t, byte(algorithm), writer.written[0][3])
+		decoded, err := DecodePayload([]byte(writer.written[0]))
+		require.NoError(t, err)
+		assert.Equal(t, payload, decoded)
+
+		tlm := Telemetry{}
+		config.flushTelemetryMetrics(&tlm)
+		assert.Equal(t, uint64(2*len(payload)), tlm.TotalBytesUncompressed)
+		assert.Equal(t, uint64(2*len(writer.written[0])), tlm.TotalBytesCompressed)
+		assert.True(t, tlm.TotalBytesCompressed < tlm.TotalBytesUncompressed)
+	}
+}
+
+func TestCompressedWriterError(t *testing.T) {
+	config := &compressionConfig{algorithm: CompressionFlate, level: flate.DefaultCompression, telemetry: &compressionTelemetry{}}
+	w, err := newCompressedWriter(&toggleWriter{fail: true}, config)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("gauge:1|g\n"))
+	assert.Error(t, err)
+	assert.Equal(t, uint64(0), config.telemetry.totalBytesUncompressed)
+	assert.Equal(t, uint64(0), config.telemetry.totalBytesCompressed)
+}
+
+func TestDecodePayload(t *testing.T) {
+	decoded, err := DecodePayload([]byte("gauge:1|g\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("gauge:1|g\n"), decoded)
+
+	_, err = DecodePayload([]byte{0, 'D', 'Z'})
+	assert.EqualError(t, err, "truncated compressed payload header")
+	_, err = DecodePayload([]byte{0, 'D', 'Z', 42})
+	assert.EqualError(t, err, "unknown compression 42")
+	_, err = DecodePayload([]byte{0, 'D', 'Z', byte(CompressionGzip), 1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestDecodePayloadSizeLimit(t *testing.T) {
+	config := &compressionConfig{algorithm: CompressionFlate, level: flate.BestCompression, telemetry: &compressionTelemetry{}}
+	writer := &toggleWriter{}
+	w, err := newCompressedWriter(writer, config)
+	require.NoError(t, err)
+
+	_, err = w.Write(bytes.Repeat([]byte("a"), MaxUDPPayloadSize))
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte("a"), MaxUDPPayloadSize+1))
+	require.NoError(t, err)
+
+	decoded, err := DecodePayload([]byte(writer.written[0]))
+	require.NoError(t, err)
+	assert.Len(t, decoded, MaxUDPPayloadSize)
+	_, err = DecodePayload([]byte(writer.written[1]))
+	assert.EqualError(t, err, "decompressed payload exceeds 65467 bytes")
+}
+
+func TestReadPayload(t *testing.T) {
+	stream := &bytes.Buffer{}
+	for _, payload := range []string{"a:1|c\n", "b:2|c\n"} {
+		binary.Write(stream, binary.LittleEndian, uint32(len(payload)))
+		stream.WriteString(payload)
+	}
+
+	payload, err := ReadPayload(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1|c\n", string(payload))
+	payload, err = ReadPayload(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "b:2|c\n", string(payload))
+	_, err = ReadPayload(stream)
+	assert.Error(t, err)
+}
+
+func TestReadPayloadFrameLimit(t *testing.T) {
+	stream := &bytes.Buffer{}
+	binary.Write(stream, binary.LittleEndian, uint32(math.MaxUint32))
+	_, err := ReadPayload(stream)
+	assert.EqualError(t, err, fmt.Sprintf("frame of %d bytes exceeds %d bytes", uint32(math.MaxUint32), maxFrameSize))
+
+	// the largest payload, incompressible, still fits in a frame
+	random := make([]byte, MaxUDPPayloadSize)
+	rand.New(rand.NewSource(1)).Read(random)
+	for _, algorithm := range []Compression{CompressionFlate, CompressionGzip} {
+		config := &compressionConfig{algorithm: algorithm, level: flate.BestCompression, telemetry: &compressionTelemetry{}}
+		writer := &toggleWriter{}
+		w, err := newCompressedWriter(writer, config)
+		require.NoError(t, err)
+		_, err = w.Write(random)
+		require.NoError(t, err)
+		assert.True(t, len(writer.written[0]) > MaxUDPPayloadSize)
+
+		stream.Reset()
+		binary.Write(stream, binary.LittleEndian, uint32(len(writer.written[0])))
+		stream.WriteString(writer.written[0])
+		payload, err := ReadPayload(stream)
+		require.NoError(t, err)
+		assert.Equal(t, random, payload)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	_, err := resolveOptions([]Option{WithCompression(42, flate.DefaultCompression)})
+	assert.EqualError(t, err, "unknown compression 42")
+	_, err = resolveOptions([]Option{WithCompression(CompressionGzip, 10)})
+	assert.EqualError(t, err, "invalid compression level 10")
+	_, err = resolveOptions([]Option{WithMaxBytesPerPayload(MaxUDPPayloadSize + 1), WithCompression(CompressionGzip, flate.DefaultCompression)})
+	assert.EqualError(t, err, "WithCompression requires payloads of at most 65467 bytes, got 65468")
+	_, err = resolveOptions([]Option{WithCompression(CompressionGzip, flate.DefaultCompression), WithMaxBytesPerPayload(MaxUDPPayloadSize)})
+	assert.NoError(t, err)
+
+	// only stream transports are compressed
+	client, err := NewEx("localhost:8765", WithCompression(CompressionGzip, flate.DefaultCompression), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	_, ok := client.sender.transport.(*compressedWriter)
+	assert.False(t, ok)
+}
+
+func TestCompressionTCPClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	client, err := NewEx(TCPAddressPrefix+listener.Addr().String(),
+		WithCompression(CompressionGzip, flate.BestSpeed),
+		WithoutOriginDetection(),
+		WithoutClientSideAggregation(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, writerNameTCP, client.GetTransport())
+
+	require.NoError(t, client.Gauge("gauge", 1, []string{"tag"}, 1))
+	require.NoError(t, client.Flush())
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	payload, err := ReadPayload(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "gauge:1|g|#tag\n", string(payload))
+	require.NoError(t, client.Close())
+
+	tlm := client.GetTelemetry()
+	assert.Equal(t, uint64(len(payload)), tlm.TotalBytesUncompressed)
+	assert.NotZero(t, tlm.TotalBytesCompressed)
+
+	compressed := 0
+	for _, m := range client.telemetryClient.flush() {
+		if m.name == "datadog.dogstatsd.client.bytes_compressed" {
+			compressed++
+		}
+	}
+	assert.Equal(t, 1, compressed)
+}
@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// overflowTag is the tag of the context new contexts of a metric are aggregated in once a context limit is reached,
+// see WithMaxContexts and WithMaxContextsPerName.
+const overflowTag = "overflow:true"
+
+var overflowTags = []string{overflowTag}
+
+// ErrorContextsOverflow is passed to the error handler the first time a context limit is reached for a metric name
+// during an aggregation interval.
+type ErrorContextsOverflow struct {
+	Name string
+	Msg  string
+}
+
+func (e *ErrorContextsOverflow) Error() string {
+	return e.Msg
+}
+
+// contextLimiter limits the number of contexts created by the aggregator during a flush interval.
+type contextLimiter struct {
+	maxContexts        uint64
+	maxContextsPerName uint64
+	errorHandler       ErrorHandler
+
+	// overflowed is the number of samples of new contexts aggregated in an overflow context.
+	overflowed uint64
+
+	sync.Mutex
+	contexts uint64
+	perName  map[string]uint64
+	reported map[string]struct{}
+}
+
+func newContextLimiter(maxContexts int, maxContextsPerName int, errorHandler ErrorHandler) *contextLimiter {
+	return &contextLimiter{
+		maxContexts:        uint64(maxContexts),
+		maxContextsPerName: uint64(maxContextsPerName),
+		errorHandler:       errorHandler,
+		perName:            map[string]uint64{},
+		reported:           map[string]struct{}{},
+	}
+}
+
+// contextOverflow is returned by admit when a new context is refused. The caller must pass it to report once it
+// released its locks: the error handler can use the client and would deadlock on them.
+type contextOverflow struct {
+	name string
+	// first is set the first time a context of the metric is refused during the interval.
+	first bool
+}
+
+// admit returns whether a new context can be created for the metric. When it can't the sample must be aggregated in
+// the overflow context of the metric, which is always admitted: overflowContext is only set by the aggregation paths
+// redirecting samples to it, never from the tags given by the user. admit can be called on a nil contextLimiter.
+func (l *contextLimiter) admit(name string, overflowContext bool) (bool, contextOverflow) {
+	if l == nil || overflowContext {
+		return true, contextOverflow{}
+	}
+
+	l.Lock()
+	if (l.maxContexts == 0 || l.contexts < l.maxContexts) &&
+		(l.maxContextsPerName == 0 || l.perName[name] < l.maxContextsPerName) {
+		l.contexts++
+		if l.maxContextsPerName != 0 {
+			l.perName[name]++
+		}
+		l.Unlock()
+		return true, contextOverflow{}
+	}
+	_, reported := l.reported[name]
+	if !reported {
+		l.reported[name] = struct{}{}
+	}
+	l.Unlock()
+
+	atomic.AddUint64(&l.overflowed, 1)
+	return false, contextOverflow{name: name, first: !reported}
+}
+
+// report calls the error handler with an ErrorContextsOverflow the first time a context of a metric is refused during
+// the interval. It must be called without holding any lock.
+func (l *contextLimiter) report(o contextOverflow) {
+	if l == nil || !o.first || l.errorHandler == nil {
+		return
+	}
+	l.errorHandler(&ErrorContextsOverflow{
+		Name: o.name,
+		Msg:  fmt.Sprintf("Too many contexts for metric %q, new contexts are aggregated with the %q tag", o.name, overflowTag),
+	})
+}
+
+// reset starts a new flush interval. It can be called on a nil contextLimiter.
+func (l *contextLimiter) reset() {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	l.contexts = 0
+	if len(l.perName) != 0 {
+		l.perName = map[string]uint64{}
+	}
+	if len(l.reported) != 0 {
+		l.reported = map[string]struct{}{}
+	}
+	l.Unlock()
+}
@@ -0,0 +1,217 @@
+package statsd
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextLimiter(t *testing.T) {
+	errs := []error{}
+	l := newContextLimiter(3, 2, func(err error) { errs = append(errs, err) })
+	admit := func(name string, overflowContext bool) bool {
+		admitted, overflow := l.admit(name, overflowContext)
+		l.report(overflow)
+		return admitted
+	}
+
+	assert.True(t, admit("a", false))
+	assert.True(t, admit("a", false))
+	// per name limit
+	assert.False(t, admit("a", false))
+	assert.False(t, admit("a", false))
+	assert.True(t, admit("b", false))
+	// global limit
+	assert.False(t, admit("c", false))
+	// the overflow contexts are always admitted
+	assert.True(t, admit("a", true))
+	assert.True(t, admit("c", true))
+
+	assert.Equal(t, uint64(3), l.overflowed)
+	// the error handler is called once per name
+	require.Len(t, errs, 2)
+	assert.Equal(t, "a", errs[0].(*ErrorContextsOverflow).Name)
+	assert.EqualError(t, errs[1], `Too many contexts for metric "c", new contexts are aggregated with the "overflow:true" tag`)
+
+	l.reset()
+	assert.True(t, admit("a", false))
+	assert.True(t, admit("a", false))
+	assert.False(t, admit("a", false))
+	assert.True(t, admit("c", false))
+	assert.False(t, admit("d", false))
+	assert.Len(t, errs, 4)
+
+	var nilLimiter *contextLimiter
+	admitted, overflow := nilLimiter.admit("a", false)
+	assert.True(t, admitted)
+	nilLimiter.report(overflow)
+	nilLimiter.reset()
+}
+
+func TestAggregatorMaxContexts(t *testing.T) {
+	a := newAggregator(nil, 0, 4)
+	a.limitContexts(newContextLimiter(3, 0, nil))
+
+	a.count("count", 1, []string{"user:1"}, CardinalityNotSet)
+	a.count("count", 2, []string{"user:2"}, CardinalityNotSet)
+	a.gauge("gauge", 3, nil, CardinalityNotSet)
+	a.count("count", 4, []string{"user:3"}, CardinalityNotSet)
+	a.count("count", 5, []string{"user:4"}, CardinalityNotSet)
+	a.count("count", 6, []string{"user:1"}, CardinalityNotSet)
+	a.gauge("other", 7, nil, CardinalityNotSet)
+	a.set("set", "value", []string{"user:1"}, CardinalityNotSet)
+	a.histogram("histogram", 8, []string{"user:1"}, 1, CardinalityNotSet)
+	a.distribution("distribution", 9, nil, 1, CardinalityNotSet)
+
+	counts := getAllCounts(a)
+	require.Len(t, counts, 3)
+	assert.Equal(t, int64(7), counts["count:user:1"].value)
+	assert.Equal(t, int64(9), counts["count:overflow:true"].value)
+	assert.Equal(t, []string{"overflow:true"}, counts["count:overflow:true"].tags)
+	assert.Contains(t, getAllGauges(a), "other:overflow:true")
+	assert.Contains(t, getAllSets(a), "set:overflow:true")
+	assert.Contains(t, a.histograms.values, "histogram:overflow:true")
+	assert.Contains(t, a.distributions.values, "distribution:overflow:true")
+	assert.Equal(t, uint64(6), a.limiter.overflowed)
+
+	// the limits apply to each flush interval
+	a.flushMetrics()
+	a.count("count", 1, []string{"user:3"}, CardinalityNotSet)
+	assert.Contains(t, getAllCounts(a), "count:user:3")
+
+	tlm := Telemetry{}
+	a.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(6), tlm.AggregationContextsOverflowed)
+}
+
+func TestAggregatorMaxContextsPerName(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.limitContexts(newContextLimiter(0, 1, nil))
+
+	a.timing("timing", 1, []string{"user:1"}, 1, CardinalityNotSet)
+	a.timing("timing", 2, []string{"user:2"}, 1, CardinalityNotSet)
+	a.timing("timing", 3, []string{"user:3"}, 1, CardinalityHigh)
+	a.timing("other", 4, []string{"user:2"}, 1, CardinalityNotSet)
+
+	require.Len(t, a.timings.values, 4)
+	assert.Equal(t, []float64{2}, a.timings.values["timing:overflow:true"].data)
+	assert.Equal(t, []float64{3}, a.timings.values["timing:high|overflow:true"].data)
+	assert.Contains(t, a.timings.values, "other:user:2")
+}
+
+func TestAggregatorMaxContextsUserOverflowTag(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.limitContexts(newContextLimiter(1, 0, nil))
+
+	// a user setting the overflow tag doesn't escape the limits
+	a.count("a", 1, []string{"overflow:true"}, CardinalityNotSet)
+	a.count("b", 2, []string{"overflow:true"}, CardinalityNotSet)
+	a.gauge("c", 3, []string{"overflow:true"}, CardinalityNotSet)
+	a.set("d", "value", []string{"overflow:true"}, CardinalityNotSet)
+	a.distribution("e", 4, []string{"overflow:true"}, 1, CardinalityNotSet)
+
+	assert.Equal(t, uint64(1), a.limiter.contexts)
+	assert.Equal(t, uint64(4), a.limiter.overflowed)
+	assert.Equal(t, int64(2), getAllCounts(a)["b:overflow:true"].value)
+}
+
+func TestHandlesMaxContexts(t *testing.T) {
+	c := newHandleTestClient(t, WithExtendedClientSideAggregation(), WithMaxContexts(1))
+	defer c.Close()
+
+	require.NoError(t, c.NewCounter("count", []string{"user:1"}).Add(1))
+	require.NoError(t, c.NewCounter("count", []string{"user:2"}).Add(2))
+	require.NoError(t, c.NewGauge("gauge", nil).Set(3))
+	require.NoError(t, c.NewSet("set", nil).Add("value"))
+	require.NoError(t, c.NewDistribution("distribution", nil).Sample(4))
+
+	assert.Contains(t, getAllCounts(c.agg), "count:user:1")
+	assert.Equal(t, int64(2), getAllCounts(c.agg)["count:overflow:true"].value)
+	assert.Contains(t, getAllGauges(c.agg), "gauge:overflow:true")
+	assert.Contains(t, getAllSets(c.agg), "set:overflow:true")
+	assert.Contains(t, c.agg.distributions.values, "distribution:overflow:true")
+	assert.Equal(t, uint64(4), c.agg.limiter.overflowed)
+}
+
+func TestWithMaxContexts(t *testing.T) {
+	_, err := resolveOptions([]Option{WithMaxContexts(0)})
+	assert.EqualError(t, err, "maxContexts must be positive")
+	_, err = resolveOptions([]Option{WithMaxContextsPerName(-1)})
+	assert.EqualError(t, err, "maxContexts must be positive")
+
+	o, err := resolveOptions([]Option{WithoutClientSideAggregation(), WithMaxContextsPerName(10)})
+	require.NoError(t, err)
+	assert.True(t, o.aggregation)
+	assert.Equal(t, 10, o.maxContextsPerName)
+
+	client, err := NewEx("localhost:8765", WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Nil(t, client.agg.limiter)
+
+	client, err = NewEx("localhost:8765", WithMaxContexts(10), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	require.NotNil(t, client.agg.limiter)
+	assert.Equal(t, uint64(10), client.agg.limiter.maxContexts)
+}
+
+func TestMaxContextsTelemetry(t *testing.T) {
+	client, err := NewEx("localhost:8765", WithMaxContexts(1), WithAggregationInterval(time.Hour))
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.Count("count", 1, []string{"user:1"}, 1))
+	require.NoError(t, client.Count("count", 1, []string{"user:2"}, 1))
+	require.NoError(t, client.Count("count", 1, []string{"user:3"}, 1))
+	assert.Equal(t, uint64(2), client.GetTelemetry().AggregationContextsOverflowed)
+
+	for _, m := range client.telemetryClient.flush() {
+		if m.name == "datadog.dogstatsd.client.aggregated_context_overflowed" {
+			assert.Equal(t, int64(2), m.ivalue)
+			return
+		}
+	}
+	assert.Fail(t, "aggregated_context_overflowed not found")
+}
+
+func TestMaxContextsErrorHandlerUsesClient(t *testing.T) {
+	var client *ClientEx
+	var handled uint64
+	client, err := NewEx("localhost:8765",
+		WithMaxContexts(1),
+		WithExtendedClientSideAggregation(),
+		WithAggregationInterval(time.Hour),
+		WithoutTelemetry(),
+		WithErrorHandler(func(err error) {
+			if overflow, ok := err.(*ErrorContextsOverflow); ok {
+				atomic.AddUint64(&handled, 1)
+				// the handler is called once the aggregator released its locks
+				client.Count("overflows", 1, []string{overflow.Name}, 1)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Count("count", 1, []string{"user:1"}, 1)
+		client.Count("count", 1, []string{"user:2"}, 1)
+		client.Distribution("distribution", 1, []string{"user:1"}, 1)
+		client.NewGauge("gauge", []string{"user:1"}).Set(1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "deadlock in the error handler")
+	}
+
+	// count, distribution, gauge and the overflows count of the handler itself are reported once each
+	assert.Equal(t, uint64(4), atomic.LoadUint64(&handled))
+	assert.Equal(t, int64(4), getAllCounts(client.agg)["overflows:overflow:true"].value)
+}
@@ -0,0 +1,209 @@
+package statsd
+
+import (
+	"context"
+	"time"
+)
+
+type contextTagsKey struct{}
+
+// ContextWithTags returns a copy of ctx carrying the given tags in addition to the ones already carried by ctx. The
+// tags are added to every metric sent through the Ctx variants of the client methods (GaugeCtx, CountCtx, ...).
+func ContextWithTags(ctx context.Context, tags ...string) context.Context {
+	if len(tags) == 0 {
+		return ctx
+	}
+	parentTags := TagsFromContext(ctx)
+	newTags := make([]string, 0, len(parentTags)+len(tags))
+	newTags = append(newTags, parentTags...)
+	newTags = append(newTags, tags...)
+	return context.WithValue(ctx, contextTagsKey{}, newTags)
+}
+
+// TagsFromContext returns the tags carried by ctx, if any. The returned slice must not be modified.
+func TagsFromContext(ctx context.Context) []string {
+	tags, _ := ctx.Value(contextTagsKey{}).([]string)
+	return tags
+}
+
+// mergeContextTags returns the tags carried by ctx followed by the given tags. The given tags are returned as-is when
+// ctx carries no tags.
+func mergeContextTags(ctx context.Context, tags []string) []string {
+	return prependTags(TagsFromContext(ctx), tags)
+}
+
+// prependTags returns prefix followed by tags. Either slice is returned as-is when the other one is empty.
+func prependTags(prefix []string, tags []string) []string {
+	if len(prefix) == 0 {
+		return tags
+	}
+	if len(tags) == 0 {
+		return prefix
+	}
+	merged := make([]string, 0, len(prefix)+len(tags))
+	merged = append(merged, prefix...)
+	return append(merged, tags...)
+}
+
+// contextParameter is passed by the Ctx methods to the non-Ctx ones so that the wait for room in the sender queue of
+// WithSenderBlockingMode ends when ctx is done.
+type contextParameter struct {
+	ctx context.Context
+}
+
+// withContextParameter returns a copy of parameters with ctx added.
+func withContextParameter(ctx context.Context, parameters []Parameter) []Parameter {
+	withCtx := make([]Parameter, 0, len(parameters)+1)
+	withCtx = append(withCtx, parameters...)
+	return append(withCtx, contextParameter{ctx})
+}
+
+// parameterContext returns the context passed by a Ctx method, or nil.
+func parameterContext(parameters []Parameter) context.Context {
+	for _, o := range parameters {
+		if p, ok := o.(contextParameter); ok {
+			return p.ctx
+		}
+	}
+	return nil
+}
+
+// GaugeCtx is Gauge with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *ClientEx) GaugeCtx(ctx context.Context, name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Gauge(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// CountCtx is Count with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *ClientEx) CountCtx(ctx context.Context, name string, value int64, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Count(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// HistogramCtx is Histogram with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything
+// if ctx is done.
+func (c *ClientEx) HistogramCtx(ctx context.Context, name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Histogram(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// DistributionCtx is Distribution with the tags carried by ctx added to tags. It returns ctx.Err() without sending
+// anything if ctx is done.
+func (c *ClientEx) DistributionCtx(ctx context.Context, name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Distribution(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// DecrCtx is just CountCtx of -1
+func (c *ClientEx) DecrCtx(ctx context.Context, name string, tags []string, rate float64, parameters ...Parameter) error {
+	return c.CountCtx(ctx, name, -1, tags, rate, parameters...)
+}
+
+// IncrCtx is just CountCtx of 1
+func (c *ClientEx) IncrCtx(ctx context.Context, name string, tags []string, rate float64, parameters ...Parameter) error {
+	return c.CountCtx(ctx, name, 1, tags, rate, parameters...)
+}
+
+// SetCtx is Set with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *ClientEx) SetCtx(ctx context.Context, name string, value string, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// TimingCtx is Timing with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx
+// is done.
+func (c *ClientEx) TimingCtx(ctx context.Context, name string, value time.Duration, tags []string, rate float64, parameters ...Parameter) error {
+	return c.TimeInMillisecondsCtx(ctx, name, value.Seconds()*1000, tags, rate, parameters...)
+}
+
+// TimeInMillisecondsCtx is TimeInMilliseconds with the tags carried by ctx added to tags. It returns ctx.Err() without
+// sending anything if ctx is done.
+func (c *ClientEx) TimeInMillisecondsCtx(ctx context.Context, name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.TimeInMilliseconds(name, value, mergeContextTags(ctx, tags), rate, withContextParameter(ctx, parameters)...)
+}
+
+// GaugeCtx is Gauge with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *Client) GaugeCtx(ctx context.Context, name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.GaugeCtx(ctx, name, value, tags, rate)
+}
+
+// CountCtx is Count with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *Client) CountCtx(ctx context.Context, name string, value int64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.CountCtx(ctx, name, value, tags, rate)
+}
+
+// HistogramCtx is Histogram with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything
+// if ctx is done.
+func (c *Client) HistogramCtx(ctx context.Context, name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.HistogramCtx(ctx, name, value, tags, rate)
+}
+
+// DistributionCtx is Distribution with the tags carried by ctx added to tags. It returns ctx.Err() without sending
+// anything if ctx is done.
+func (c *Client) DistributionCtx(ctx context.Context, name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.DistributionCtx(ctx, name, value, tags, rate)
+}
+
+// DecrCtx is just CountCtx of -1
+func (c *Client) DecrCtx(ctx context.Context, name string, tags []string, rate float64) error {
+	return c.CountCtx(ctx, name, -1, tags, rate)
+}
+
+// IncrCtx is just CountCtx of 1
+func (c *Client) IncrCtx(ctx context.Context, name string, tags []string, rate float64) error {
+	return c.CountCtx(ctx, name, 1, tags, rate)
+}
+
+// SetCtx is Set with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx is
+// done.
+func (c *Client) SetCtx(ctx context.Context, name string, value string, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.SetCtx(ctx, name, value, tags, rate)
+}
+
+// TimingCtx is Timing with the tags carried by ctx added to tags. It returns ctx.Err() without sending anything if ctx
+// is done.
+func (c *Client) TimingCtx(ctx context.Context, name string, value time.Duration, tags []string, rate float64) error {
+	return c.TimeInMillisecondsCtx(ctx, name, value.Seconds()*1000, tags, rate)
+}
+
+// TimeInMillisecondsCtx is TimeInMilliseconds with the tags carried by ctx added to tags. It returns ctx.Err() without
+// sending anything if ctx is done.
+func (c *Client) TimeInMillisecondsCtx(ctx context.Context, name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.TimeInMillisecondsCtx(ctx, name, value, tags, rate)
+}
@@ -0,0 +1,189 @@
+package statsd
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWithTags(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, TagsFromContext(ctx))
+	assert.Equal(t, ctx, ContextWithTags(ctx))
+
+	parent := ContextWithTags(ctx, "a", "b")
+	child := ContextWithTags(parent, "c")
+	sibling := ContextWithTags(parent, "d")
+
+	assert.Equal(t, []string{"a", "b"}, TagsFromContext(parent))
+	assert.Equal(t, []string{"a", "b", "c"}, TagsFromContext(child))
+	assert.Equal(t, []string{"a", "b", "d"}, TagsFromContext(sibling))
+}
+
+func TestMergeContextTags(t *testing.T) {
+	ctx := context.Background()
+	tags := []string{"call"}
+	assert.Equal(t, tags, mergeContextTags(ctx, tags))
+	assert.Nil(t, mergeContextTags(ctx, nil))
+
+	ctx = ContextWithTags(ctx, "ctx")
+	assert.Equal(t, []string{"ctx"}, mergeContextTags(ctx, nil))
+	assert.Equal(t, []string{"ctx", "call"}, mergeContextTags(ctx, tags))
+	assert.Equal(t, []string{"call"}, tags)
+}
+
+func TestContextTagsPipeline(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		[]string{"global"},
+		WithTags([]string{"global"}),
+		WithoutTelemetry(),
+		WithoutClientSideAggregation(),
+	)
+
+	ctx := ContextWithTags(ContextWithTags(context.Background(), "ctx:1"), "ctx:2")
+	tags := []string{"custom:1"}
+	require.NoError(t, client.GaugeCtx(ctx, "Gauge", 1, tags, 1))
+	require.NoError(t, client.CountCtx(ctx, "Count", 2, tags, 1))
+	require.NoError(t, client.HistogramCtx(ctx, "Histogram", 3, tags, 1))
+	require.NoError(t, client.DistributionCtx(ctx, "Distribution", 4, tags, 1))
+	require.NoError(t, client.DecrCtx(ctx, "Decr", tags, 1))
+	require.NoError(t, client.IncrCtx(ctx, "Incr", tags, 1))
+	require.NoError(t, client.SetCtx(ctx, "Set", "value", tags, 1))
+	require.NoError(t, client.TimingCtx(ctx, "Timing", 5*time.Second, tags, 1))
+	require.NoError(t, client.TimeInMillisecondsCtx(ctx, "TimeInMilliseconds", 6, nil, 1))
+
+	finalTags := ts.getFinalTags("ctx:1", "ctx:2", "custom:1")
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"Gauge:1|g" + finalTags + containerID,
+		"Count:2|c" + finalTags + containerID,
+		"Histogram:3|h" + finalTags + containerID,
+		"Distribution:4|d" + finalTags + containerID,
+		"Decr:-1|c" + finalTags + containerID,
+		"Incr:1|c" + finalTags + containerID,
+		"Set:value|s" + finalTags + containerID,
+		"Timing:5000.000000|ms" + finalTags + containerID,
+		"TimeInMilliseconds:6.000000|ms" + ts.getFinalTags("ctx:1", "ctx:2") + containerID,
+	})
+}
+
+func TestContextTagsWithAggregation(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithoutTelemetry(),
+		WithClientSideAggregation(),
+	)
+
+	ctx := ContextWithTags(context.Background(), "ctx")
+	require.NoError(t, client.IncrCtx(ctx, "Count", nil, 1))
+	require.NoError(t, client.CountCtx(ctx, "Count", 2, nil, 1))
+	require.NoError(t, client.Count("Count", 4, []string{"ctx"}, 1))
+
+	ts.assert(t, client, []string{
+		"Count:7|c|#ctx" + ts.getContainerID(),
+	})
+}
+
+func TestContextTagsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(ContextWithTags(context.Background(), "ctx"))
+	cancel()
+
+	client, err := NewEx("localhost:8765", WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert.Equal(t, context.Canceled, client.GaugeCtx(ctx, "Gauge", 1, nil, 1))
+	assert.Equal(t, context.Canceled, client.CountCtx(ctx, "Count", 1, nil, 1))
+	assert.Equal(t, context.Canceled, client.HistogramCtx(ctx, "Histogram", 1, nil, 1))
+	assert.Equal(t, context.Canceled, client.DistributionCtx(ctx, "Distribution", 1, nil, 1))
+	assert.Equal(t, context.Canceled, client.SetCtx(ctx, "Set", "value", nil, 1))
+	assert.Equal(t, context.Canceled, client.TimingCtx(ctx, "Timing", time.Second, nil, 1))
+	assert.Equal(t, statsdTelemetry{}, *client.telemetry)
+}
+
+// blockedWriter blocks every write until release is closed.
+type blockedWriter struct {
+	sync.Mutex
+	writing chan struct{}
+	release chan struct{}
+	written []string
+}
+
+func (w *blockedWriter) Write(data []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.release
+	w.Lock()
+	defer w.Unlock()
+	w.written = append(w.written, string(data))
+	return len(data), nil
+}
+
+func (w *blockedWriter) Close() error {
+	return nil
+}
+
+func TestContextCancelsSenderBlockingMode(t *testing.T) {
+	writer := &blockedWriter{writing: make(chan struct{}, 1), release: make(chan struct{})}
+	client, err := NewWithWriterEx(writer,
+		WithoutTelemetry(),
+		WithoutClientSideAggregation(),
+		WithoutOriginDetection(),
+		WithSenderBlockingMode(time.Hour),
+		WithSenderQueueSize(1),
+		WithMaxMessagesPerPayload(1),
+		WithWorkersCount(1),
+		WithBufferFlushInterval(time.Hour),
+	)
+	require.NoError(t, err)
+
+	// the first payload is stuck in the writer and the second one fills the queue
+	require.NoError(t, client.CountCtx(context.Background(), "Count", 1, nil, 1))
+	require.NoError(t, client.CountCtx(context.Background(), "Count", 2, nil, 1))
+	<-writer.writing
+	// another caller sharing the worker fills its buffer
+	require.NoError(t, client.CountCtx(context.Background(), "Count", 3, nil, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error, 1)
+	go func() {
+		errC <- client.CountCtx(ctx, "Count", 4, nil, 1)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errC:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("CountCtx is still blocked after its context was cancelled")
+	}
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&client.sender.telemetry.totalPayloadsBlockedQueueFull))
+	assert.Equal(t, uint64(0), atomic.LoadUint64(&client.sender.telemetry.totalPayloadsDroppedQueueFull))
+
+	// the metric of the other caller is still sent, the one of the cancelled caller isn't
+	close(writer.release)
+	require.NoError(t, client.Close())
+	writer.Lock()
+	defer writer.Unlock()
+	assert.Equal(t, []string{"Count:1|c\n", "Count:2|c\n", "Count:3|c\n"}, writer.written)
+}
+
+func TestContextTagsNilClient(t *testing.T) {
+	var c *Client
+	assert.Equal(t, ErrNoClient, c.GaugeCtx(context.Background(), "Gauge", 1, nil, 1))
+
+	var cEx *ClientEx
+	assert.Equal(t, ErrNoClient, cEx.CountCtx(context.Background(), "Count", 1, nil, 1))
+}
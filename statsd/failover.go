@@ -0,0 +1,77 @@
+package statsd
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// failoverWriter is a Transport writing to the first of several transports that works. It switches to the next
+// transport after a number of consecutive write failures and periodically probes the first transport, the preferred
+// one, to switch back to it once it works again.
+//
+// failoverWriter is only written to by the sender goroutine.
+type failoverWriter struct {
+	transports    []Transport
+	threshold     int
+	probeInterval time.Duration
+	// active is the index of the transport in use. It's read by the telemetry goroutine through GetTransportName.
+	active    int32
+	failures  int
+	lastProbe time.Time
+}
+
+func newFailoverWriter(transports []Transport, threshold int, probeInterval time.Duration) *failoverWriter {
+	return &failoverWriter{
+		transports:    transports,
+		threshold:     threshold,
+		probeInterval: probeInterval,
+	}
+}
+
+// Write writes data to the active transport. When the preferred transport isn't active and the probe interval expired,
+// data is written to the preferred transport first: if it succeeds the writer switches back to it.
+func (w *failoverWriter) Write(data []byte) (int, error) {
+	active := int(atomic.LoadInt32(&w.active))
+
+	if active != 0 && time.Since(w.lastProbe) >= w.probeInterval {
+		w.lastProbe = time.Now()
+		if n, err := w.transports[0].Write(data); err == nil {
+			w.switchTo(0)
+			return n, nil
+		}
+	}
+
+	n, err := w.transports[active].Write(data)
+	if err == nil {
+		w.failures = 0
+		return n, nil
+	}
+
+	w.failures++
+	if w.failures >= w.threshold && active+1 < len(w.transports) {
+		w.switchTo(active + 1)
+	}
+	return n, err
+}
+
+func (w *failoverWriter) switchTo(index int) {
+	atomic.StoreInt32(&w.active, int32(index))
+	w.failures = 0
+	w.lastProbe = time.Now()
+}
+
+// Close closes every transport and returns the first error.
+func (w *failoverWriter) Close() error {
+	var err error
+	for _, t := range w.transports {
+		if closeErr := t.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// GetTransportName returns the name of the active transport.
+func (w *failoverWriter) GetTransportName() string {
+	return w.transports[atomic.LoadInt32(&w.active)].GetTransportName()
+}
@@ -0,0 +1,142 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedWriter struct {
+	toggleWriter
+	name string
+}
+
+func (w *namedWriter) GetTransportName() string {
+	return w.name
+}
+
+func newTestFailoverWriter(threshold int) (*failoverWriter, *namedWriter, *namedWriter) {
+	primary := &namedWriter{toggleWriter: toggleWriter{fail: true}, name: "primary"}
+	fallback := &namedWriter{name: "fallback"}
+	return newFailoverWriter([]Transport{primary, fallback}, threshold, time.Minute), primary, fallback
+}
+
+func TestFailoverWriterSwitch(t *testing.T) {
+	w, primary, fallback := newTestFailoverWriter(2)
+	assert.Equal(t, "primary", w.GetTransportName())
+
+	_, err := w.Write([]byte("a"))
+	assert.Error(t, err)
+	assert.Equal(t, "primary", w.GetTransportName())
+
+	_, err = w.Write([]byte("b"))
+	assert.Error(t, err)
+	assert.Equal(t, "fallback", w.GetTransportName())
+
+	_, err = w.Write([]byte("c"))
+	require.NoError(t, err)
+	assert.Empty(t, primary.written)
+	assert.Equal(t, []string{"c"}, fallback.written)
+}
+
+func TestFailoverWriterConsecutiveFailures(t *testing.T) {
+	w, primary, _ := newTestFailoverWriter(2)
+
+	_, err := w.Write([]byte("a"))
+	assert.Error(t, err)
+
+	primary.fail = false
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+
+	primary.fail = true
+	_, err = w.Write([]byte("c"))
+	assert.Error(t, err)
+	assert.Equal(t, "primary", w.GetTransportName())
+}
+
+func TestFailoverWriterProbe(t *testing.T) {
+	w, primary, fallback := newTestFailoverWriter(1)
+	w.Write([]byte("a"))
+	require.Equal(t, "fallback", w.GetTransportName())
+
+	// probe interval not expired
+	primary.fail = false
+	w.Write([]byte("b"))
+	assert.Equal(t, []string{"b"}, fallback.written)
+
+	// failed probe
+	primary.fail = true
+	w.lastProbe = time.Now().Add(-time.Hour)
+	_, err := w.Write([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, fallback.written)
+	assert.Equal(t, "fallback", w.GetTransportName())
+
+	// successful probe
+	primary.fail = false
+	w.lastProbe = time.Now().Add(-time.Hour)
+	_, err = w.Write([]byte("d"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, primary.written)
+	assert.Equal(t, "primary", w.GetTransportName())
+}
+
+func TestFailoverWriterLastTransport(t *testing.T) {
+	w, primary, fallback := newTestFailoverWriter(1)
+	fallback.fail = true
+
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("a"))
+	}
+	assert.Equal(t, "fallback", w.GetTransportName())
+	assert.Empty(t, primary.written)
+}
+
+func TestFailoverClient(t *testing.T) {
+	// nothing listens on the TCP address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tcpAddr := listener.Addr().String()
+	listener.Close()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client, err := NewEx("tcp://"+tcpAddr+",udp://"+conn.LocalAddr().String(),
+		WithTransportFailover(1, time.Hour),
+		WithoutTelemetry(),
+		WithoutClientSideAggregation(),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, writerNameTCP, client.GetTransport())
+	assert.Equal(t, OptimalUDPPayloadSize, client.sender.pool.bufferMaxSize)
+
+	require.NoError(t, client.Gauge("lost", 1, nil, 1))
+	require.NoError(t, client.Flush())
+	assert.Equal(t, writerNameUDP, client.GetTransport())
+
+	require.NoError(t, client.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, client.Flush())
+
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "gauge:1|g\n", string(buffer[:n]))
+}
+
+func TestFailoverInvalidAddress(t *testing.T) {
+	_, err := NewEx("localhost:8765,localhost:invalid")
+	assert.Error(t, err)
+
+	_, err = resolveOptions([]Option{WithTransportFailover(0, time.Second)})
+	assert.EqualError(t, err, "failures must be a positive integer")
+	_, err = resolveOptions([]Option{WithTransportFailover(1, 0)})
+	assert.EqualError(t, err, "probeInterval must be a positive duration")
+}
@@ -0,0 +1,98 @@
+package statsd
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// destinationTelemetry contains telemetry about a single destination of a fanOutWriter
+type destinationTelemetry struct {
+	totalPayloadsSent    uint64
+	totalPayloadsDropped uint64
+	totalBytesSent       uint64
+	totalBytesDropped    uint64
+}
+
+type fanOutDestination struct {
+	name      string
+	transport Transport
+	telemetry *destinationTelemetry
+}
+
+// fanOutWriter is a Transport writing every payload to several transports. Each destination is handled independently:
+// a payload is only reported as failed to the sender when no destination could write it, the failures of the other
+// destinations are reported to the error handler and counted in their own telemetry.
+//
+// Destinations are written one after the other: a slow destination delays the others by up to its write timeout.
+type fanOutWriter struct {
+	destinations []fanOutDestination
+	errorHandler ErrorHandler
+}
+
+func newFanOutWriter(errorHandler ErrorHandler) *fanOutWriter {
+	return &fanOutWriter{errorHandler: errorHandler}
+}
+
+func (w *fanOutWriter) addDestination(name string, transport Transport) {
+	w.destinations = append(w.destinations, fanOutDestination{
+		name:      name,
+		transport: transport,
+		telemetry: &destinationTelemetry{},
+	})
+}
+
+// Write writes data to every destination. It only returns an error if all destinations failed.
+func (w *fanOutWriter) Write(data []byte) (int, error) {
+	var errs []string
+	for _, d := range w.destinations {
+		_, err := d.transport.Write(data)
+		if err != nil {
+			atomic.AddUint64(&d.telemetry.totalPayloadsDropped, 1)
+			atomic.AddUint64(&d.telemetry.totalBytesDropped, uint64(len(data)))
+			errs = append(errs, fmt.Sprintf("destination %s: %v", d.name, err))
+			continue
+		}
+		atomic.AddUint64(&d.telemetry.totalPayloadsSent, 1)
+		atomic.AddUint64(&d.telemetry.totalBytesSent, uint64(len(data)))
+	}
+
+	if len(errs) == len(w.destinations) {
+		return 0, fmt.Errorf("could not write payload to any destination: %s", strings.Join(errs, ", "))
+	}
+	if w.errorHandler != nil {
+		for _, err := range errs {
+			w.errorHandler(fmt.Errorf("could not write payload to %s", err))
+		}
+	}
+	return len(data), nil
+}
+
+// Close closes every destination and returns the first error.
+func (w *fanOutWriter) Close() error {
+	var err error
+	for _, d := range w.destinations {
+		if closeErr := d.transport.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// GetTransportName returns the transport of the first destination, the one given to NewEx or NewWithWriter.
+func (w *fanOutWriter) GetTransportName() string {
+	return w.destinations[0].transport.GetTransportName()
+}
+
+func (w *fanOutWriter) flushTelemetryMetrics(t *Telemetry) {
+	t.Destinations = make([]DestinationTelemetry, 0, len(w.destinations))
+	for _, d := range w.destinations {
+		t.Destinations = append(t.Destinations, DestinationTelemetry{
+			Name:                 d.name,
+			TotalPayloadsSent:    atomic.LoadUint64(&d.telemetry.totalPayloadsSent),
+			TotalPayloadsDropped: atomic.LoadUint64(&d.telemetry.totalPayloadsDropped),
+			TotalBytesSent:       atomic.LoadUint64(&d.telemetry.totalBytesSent),
+			TotalBytesDropped:    atomic.LoadUint64(&d.telemetry.totalBytesDropped),
+		})
+	}
+}
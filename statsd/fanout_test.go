@@ -0,0 +1,169 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closeRecorder struct {
+	toggleWriter
+	closed bool
+}
+
+func (w *closeRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestFanOutWriterPartialFailure(t *testing.T) {
+	var errs []error
+	first := &toggleWriter{}
+	second := &toggleWriter{fail: true}
+	w := newFanOutWriter(func(err error) { errs = append(errs, err) })
+	w.addDestination("first", first)
+	w.addDestination("second", second)
+
+	n, err := w.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"abc"}, first.written)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "could not write payload to destination second: agent unreachable")
+
+	second.fail = false
+	_, err = w.Write([]byte("de"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de"}, second.written)
+
+	tlm := Telemetry{}
+	w.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, []DestinationTelemetry{
+		{Name: "first", TotalPayloadsSent: 2, TotalBytesSent: 5},
+		{Name: "second", TotalPayloadsSent: 1, TotalBytesSent: 2, TotalPayloadsDropped: 1, TotalBytesDropped: 3},
+	}, tlm.Destinations)
+}
+
+func TestFanOutWriterAllFailed(t *testing.T) {
+	called := false
+	w := newFanOutWriter(func(error) { called = true })
+	w.addDestination("first", &toggleWriter{fail: true})
+	w.addDestination("second", &toggleWriter{fail: true})
+
+	_, err := w.Write([]byte("abc"))
+	assert.EqualError(t, err, "could not write payload to any destination: destination first: agent unreachable, destination second: agent unreachable")
+	// the error is reported once, by the sender
+	assert.False(t, called)
+}
+
+func TestFanOutWriterClose(t *testing.T) {
+	first := &closeRecorder{}
+	second := &closeRecorder{}
+	w := newFanOutWriter(nil)
+	w.addDestination("first", first)
+	w.addDestination("second", second)
+	assert.Equal(t, "toggle", w.GetTransportName())
+
+	require.NoError(t, w.Close())
+	assert.True(t, first.closed)
+	assert.True(t, second.closed)
+}
+
+func TestSenderFanOutPartialFailure(t *testing.T) {
+	w := newFanOutWriter(nil)
+	w.addDestination("first", &toggleWriter{})
+	w.addDestination("second", &toggleWriter{fail: true})
+	sender, pool := newTestRetrySender(w, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, 10)
+
+	writeTestPayload(sender, pool, "a")
+	assert.Empty(t, sender.retries)
+
+	tlm := Telemetry{}
+	sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(1), tlm.TotalPayloadsSent)
+	assert.Equal(t, uint64(0), tlm.TotalPayloadsDroppedWriter)
+	require.Len(t, tlm.Destinations, 2)
+	assert.Equal(t, uint64(1), tlm.Destinations[1].TotalPayloadsDropped)
+}
+
+func TestFanOutClient(t *testing.T) {
+	conns := []*net.UDPConn{}
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+		require.NoError(t, err)
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	writer := &toggleWriter{}
+
+	client, err := NewEx(conns[0].LocalAddr().String(),
+		WithFanOut(conns[1].LocalAddr().String()),
+		WithFanOutWriter(writer),
+		WithoutTelemetry(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, client.Close())
+
+	buffer := make([]byte, 1024)
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buffer)
+		require.NoError(t, err)
+		assert.Equal(t, "gauge:1|g\n", string(buffer[:n]))
+	}
+	assert.Equal(t, []string{"gauge:1|g\n"}, writer.written)
+}
+
+func TestFanOutClientDefaults(t *testing.T) {
+	client, err := NewWithWriterEx(&toggleWriter{}, WithFanOut("tcp://localhost:8765"), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, OptimalUDPPayloadSize, client.sender.pool.bufferMaxSize)
+
+	client, err = NewEx("tcp://localhost:8765", WithFanOut("tcp://localhost:8766"), WithoutTelemetry())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, DefaultMaxAgentPayloadSize, client.sender.pool.bufferMaxSize)
+	assert.Equal(t, writerNameTCP, client.GetTransport())
+}
+
+func TestFanOutInvalidDestination(t *testing.T) {
+	_, err := NewEx("localhost:8765", WithFanOut("localhost:invalid"))
+	assert.Error(t, err)
+
+	_, err = resolveOptions([]Option{WithFanOut("")})
+	assert.EqualError(t, err, "fan-out address must not be empty")
+	_, err = resolveOptions([]Option{WithFanOutWriter(nil)})
+	assert.EqualError(t, err, "fan-out writer must not be nil")
+}
+
+func TestTelemetryFanOut(t *testing.T) {
+	client, err := NewEx("localhost:8765", WithFanOut("localhost:8766"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	fanOut := client.sender.transport.(*fanOutWriter)
+	fanOut.destinations[1].telemetry.totalPayloadsDropped = 3
+
+	metrics := map[string]int64{}
+	for _, m := range client.telemetryClient.flush() {
+		if strings.HasPrefix(m.name, "datadog.dogstatsd.client.destination.") {
+			metrics[m.name+" "+m.tags[len(m.tags)-1]] = m.ivalue
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"datadog.dogstatsd.client.destination.packets_sent destination:localhost:8765":    0,
+		"datadog.dogstatsd.client.destination.packets_dropped destination:localhost:8765": 0,
+		"datadog.dogstatsd.client.destination.bytes_sent destination:localhost:8765":      0,
+		"datadog.dogstatsd.client.destination.bytes_dropped destination:localhost:8765":   0,
+		"datadog.dogstatsd.client.destination.packets_sent destination:localhost:8766":    0,
+		"datadog.dogstatsd.client.destination.packets_dropped destination:localhost:8766": 3,
+		"datadog.dogstatsd.client.destination.bytes_sent destination:localhost:8766":      0,
+		"datadog.dogstatsd.client.destination.bytes_dropped destination:localhost:8766":   0,
+	}, metrics)
+}
@@ -0,0 +1,86 @@
+package statsd
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+// FlushedMetric is a read-only view of a metric sent by the aggregator, see WithFlushObserver. Its slices are not
+// shared with the client and can be kept after the observer returns.
+type FlushedMetric struct {
+	// Name of the metric, including the client namespace if any.
+	Name string
+	// Type of the metric as written on the wire.
+	Type protocol.MetricType
+	// Values holds the numeric values of the metric. Aggregated histograms, distributions and timings can hold more
+	// than one value. Values is empty for sets.
+	Values []float64
+	// StringValue is the value of a set.
+	StringValue string
+	// Rate is the sample rate sent with the metric, 1 when none is sent.
+	Rate float64
+	// Tags of the metric, including the client global tags.
+	Tags []string
+	// Cardinality sent with the metric, empty when none is sent.
+	Cardinality string
+	// Timestamp sent with the metric, zero when none is sent.
+	Timestamp time.Time
+}
+
+func toFlushedMetric(m metric) FlushedMetric {
+	f := FlushedMetric{
+		Name:        m.namespace + m.name,
+		Rate:        recordedRate(m.rate),
+		Tags:        recordedTags(m),
+		Cardinality: m.cardinality.String(),
+	}
+	if m.timestamp > noTimestamp {
+		f.Timestamp = time.Unix(m.timestamp, 0)
+	}
+
+	switch m.metricType {
+	case gauge:
+		f.Type = protocol.Gauge
+		f.Values = []float64{m.fvalue}
+	case count:
+		f.Type = protocol.Count
+		f.Values = []float64{float64(m.ivalue)}
+	case set:
+		f.Type = protocol.Set
+		f.StringValue = m.svalue
+	case histogram:
+		f.Type = protocol.Histogram
+		f.Values = []float64{m.fvalue}
+	case distribution:
+		f.Type = protocol.Distribution
+		f.Values = []float64{m.fvalue}
+	case timing:
+		f.Type = protocol.Timing
+		f.Values = []float64{m.fvalue}
+	case histogramAggregated:
+		f.Type = protocol.Histogram
+		f.Values = append([]float64{}, m.fvalues...)
+	case distributionAggregated:
+		f.Type = protocol.Distribution
+		f.Values = append([]float64{}, m.fvalues...)
+	case timingAggregated:
+		f.Type = protocol.Timing
+		f.Values = append([]float64{}, m.fvalues...)
+	}
+	return f
+}
+
+// observe passes the flushed metrics to the flush observer, if any.
+func (a *aggregator) observe(metrics []metric, namespace string, globalTags []string) {
+	if a.flushObserver == nil || len(metrics) == 0 {
+		return
+	}
+	flushed := make([]FlushedMetric, len(metrics))
+	for i, m := range metrics {
+		m.namespace = namespace
+		m.globalTags = globalTags
+		flushed[i] = toFlushedMetric(m)
+	}
+	a.flushObserver(flushed)
+}
@@ -0,0 +1,82 @@
+package statsd
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+type testFlushObserver struct {
+	sync.Mutex
+	flushes [][]FlushedMetric
+}
+
+func (o *testFlushObserver) observe(metrics []FlushedMetric) {
+	o.Lock()
+	defer o.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	o.flushes = append(o.flushes, metrics)
+}
+
+func TestWithFlushObserver(t *testing.T) {
+	observer := &testFlushObserver{}
+	client, err := NewEx("localhost:8774",
+		WithFlushObserver(observer.observe),
+		WithExtendedClientSideAggregation(),
+		WithNamespace("ns."),
+		WithTags([]string{"global"}),
+		WithoutTelemetry(),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+	// this seed keeps the first two sampled distributions and drops the third one
+	client.agg.distributions.random = rand.New(rand.NewSource(9))
+
+	require.NoError(t, client.Count("count", 2, []string{"tag"}, 1))
+	require.NoError(t, client.Count("count", 3, []string{"tag"}, 1))
+	require.NoError(t, client.Gauge("gauge", 4, nil, 1))
+	require.NoError(t, client.Set("set", "value", nil, 1))
+	require.NoError(t, client.Distribution("distribution", 5, nil, 0.5, CardinalityHigh))
+	require.NoError(t, client.Distribution("distribution", 6, nil, 0.5, CardinalityHigh))
+	require.NoError(t, client.Distribution("distribution", 7, nil, 0.5, CardinalityHigh))
+	require.NoError(t, client.Flush())
+	// nothing is observed when nothing is flushed
+	require.NoError(t, client.Flush())
+
+	require.Len(t, observer.flushes, 1)
+	assert.Equal(t, []FlushedMetric{
+		{Name: "ns.count", Type: protocol.Count, Values: []float64{5}, Rate: 1, Tags: []string{"global", "tag"}},
+		{Name: "ns.distribution", Type: protocol.Distribution, Values: []float64{5, 6}, Rate: 0.5, Tags: []string{"global"}, Cardinality: "high"},
+		{Name: "ns.gauge", Type: protocol.Gauge, Values: []float64{4}, Rate: 1, Tags: []string{"global"}},
+		{Name: "ns.set", Type: protocol.Set, StringValue: "value", Rate: 1, Tags: []string{"global"}},
+	}, observer.flushes[0])
+
+	o, err := resolveOptions([]Option{WithoutClientSideAggregation(), WithFlushObserver(observer.observe)})
+	require.NoError(t, err)
+	assert.True(t, o.aggregation)
+}
+
+func TestFlushObserverTimestamp(t *testing.T) {
+	observer := &testFlushObserver{}
+	c, err := NewRecordingClient(WithFlushObserver(observer.observe), WithTimestampAggregation(0))
+	require.NoError(t, err)
+
+	ts := time.Unix(time.Now().Unix()-10, 0)
+	require.NoError(t, c.CountWithTimestamp("count", 1, nil, 1, ts))
+	require.NoError(t, c.CountWithTimestamp("count", 1, nil, 1, ts))
+	require.NoError(t, c.Flush())
+
+	require.Len(t, observer.flushes, 1)
+	require.Len(t, observer.flushes[0], 1)
+	assert.Equal(t, ts, observer.flushes[0][0].Timestamp)
+	assert.Equal(t, []float64{2}, observer.flushes[0][0].Values)
+	// the observer sees what the recording client records
+	assert.Equal(t, c.Metrics()[0].Values, observer.flushes[0][0].Values)
+}
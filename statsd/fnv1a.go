@@ -7,6 +7,10 @@ const (
 
 	// init32 is what 32 bits hash values should be initialized with.
 	init32 = offset32
+
+	// FNV-1a 64 bits
+	offset64 = uint64(14695981039346656037)
+	prime64  = uint64(1099511628211)
 )
 
 // HashString32 returns the hash of s.
@@ -44,3 +48,12 @@ func addString32(h uint32, s string) uint32 {
 func appendString32(b []byte, h uint32, s string) ([]byte, uint32) {
 	return append(b, s...), addString32(h, s)
 }
+
+// hashString64 returns the 64 bits hash of s.
+func hashString64(s string) uint64 {
+	h := offset64
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * prime64
+	}
+	return h
+}
@@ -99,64 +99,64 @@ func appendTagsAggregated(buffer []byte, globalTags []string, tags string) []byt
 	return buffer
 }
 
-func appendFloatMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, precision int, originDetection bool) []byte {
+func appendFloatMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, precision int, originDetection bool, features agentFeatures) []byte {
 	buffer = appendHeader(buffer, namespace, name)
 	buffer = strconv.AppendFloat(buffer, value, 'f', precision, 64)
 	buffer = append(buffer, '|')
 	buffer = append(buffer, typeSymbol...)
 	buffer = appendRate(buffer, rate)
 	buffer = appendTags(buffer, globalTags, tags)
-	buffer = appendContainerID(buffer)
-	buffer = appendExternalEnv(buffer, originDetection)
+	buffer = appendContainerID(buffer, features)
+	buffer = appendExternalEnv(buffer, originDetection && features.has(agentFeatureExternalEnv))
 	return buffer
 }
 
-func appendIntegerMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value int64, tags []string, rate float64, originDetection bool) []byte {
+func appendIntegerMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value int64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
 	buffer = appendHeader(buffer, namespace, name)
 	buffer = strconv.AppendInt(buffer, value, 10)
 	buffer = append(buffer, '|')
 	buffer = append(buffer, typeSymbol...)
 	buffer = appendRate(buffer, rate)
 	buffer = appendTags(buffer, globalTags, tags)
-	buffer = appendContainerID(buffer)
-	buffer = appendExternalEnv(buffer, originDetection)
+	buffer = appendContainerID(buffer, features)
+	buffer = appendExternalEnv(buffer, originDetection && features.has(agentFeatureExternalEnv))
 	return buffer
 }
 
-func appendStringMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value string, tags []string, rate float64, originDetection bool) []byte {
+func appendStringMetric(buffer []byte, typeSymbol []byte, namespace string, globalTags []string, name string, value string, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
 	buffer = appendHeader(buffer, namespace, name)
 	buffer = append(buffer, value...)
 	buffer = append(buffer, '|')
 	buffer = append(buffer, typeSymbol...)
 	buffer = appendRate(buffer, rate)
 	buffer = appendTags(buffer, globalTags, tags)
-	buffer = appendContainerID(buffer)
-	buffer = appendExternalEnv(buffer, originDetection)
+	buffer = appendContainerID(buffer, features)
+	buffer = appendExternalEnv(buffer, originDetection && features.has(agentFeatureExternalEnv))
 	return buffer
 }
 
-func appendGauge(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool) []byte {
-	return appendFloatMetric(buffer, gaugeSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection)
+func appendGauge(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendFloatMetric(buffer, gaugeSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection, features)
 }
 
-func appendCount(buffer []byte, namespace string, globalTags []string, name string, value int64, tags []string, rate float64, originDetection bool) []byte {
-	return appendIntegerMetric(buffer, countSymbol, namespace, globalTags, name, value, tags, rate, originDetection)
+func appendCount(buffer []byte, namespace string, globalTags []string, name string, value int64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendIntegerMetric(buffer, countSymbol, namespace, globalTags, name, value, tags, rate, originDetection, features)
 }
 
-func appendHistogram(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool) []byte {
-	return appendFloatMetric(buffer, histogramSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection)
+func appendHistogram(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendFloatMetric(buffer, histogramSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection, features)
 }
 
-func appendDistribution(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool) []byte {
-	return appendFloatMetric(buffer, distributionSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection)
+func appendDistribution(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendFloatMetric(buffer, distributionSymbol, namespace, globalTags, name, value, tags, rate, -1, originDetection, features)
 }
 
-func appendSet(buffer []byte, namespace string, globalTags []string, name string, value string, tags []string, rate float64, originDetection bool) []byte {
-	return appendStringMetric(buffer, setSymbol, namespace, globalTags, name, value, tags, rate, originDetection)
+func appendSet(buffer []byte, namespace string, globalTags []string, name string, value string, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendStringMetric(buffer, setSymbol, namespace, globalTags, name, value, tags, rate, originDetection, features)
 }
 
-func appendTiming(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool) []byte {
-	return appendFloatMetric(buffer, timingSymbol, namespace, globalTags, name, value, tags, rate, 6, originDetection)
+func appendTiming(buffer []byte, namespace string, globalTags []string, name string, value float64, tags []string, rate float64, originDetection bool, features agentFeatures) []byte {
+	return appendFloatMetric(buffer, timingSymbol, namespace, globalTags, name, value, tags, rate, 6, originDetection, features)
 }
 
 func escapedEventTextLen(text string) int {
@@ -174,7 +174,7 @@ func appendEscapedEventText(buffer []byte, text string) []byte {
 	return buffer
 }
 
-func appendEvent(buffer []byte, event *Event, globalTags []string, originDetection bool) []byte {
+func appendEvent(buffer []byte, event *Event, globalTags []string, originDetection bool, features agentFeatures) []byte {
 	escapedTextLen := escapedEventTextLen(event.Text)
 
 	buffer = append(buffer, "_e{"...)
@@ -221,8 +221,8 @@ func appendEvent(buffer []byte, event *Event, globalTags []string, originDetecti
 	}
 
 	buffer = appendTags(buffer, globalTags, event.Tags)
-	buffer = appendContainerID(buffer)
-	buffer = appendExternalEnv(buffer, originDetection)
+	buffer = appendContainerID(buffer, features)
+	buffer = appendExternalEnv(buffer, originDetection && features.has(agentFeatureExternalEnv))
 	return buffer
 }
 
@@ -240,7 +240,7 @@ func appendEscapedServiceCheckText(buffer []byte, text string) []byte {
 	return buffer
 }
 
-func appendServiceCheck(buffer []byte, serviceCheck *ServiceCheck, globalTags []string, originDetection bool) []byte {
+func appendServiceCheck(buffer []byte, serviceCheck *ServiceCheck, globalTags []string, originDetection bool, features agentFeatures) []byte {
 	buffer = append(buffer, "_sc|"...)
 	buffer = append(buffer, serviceCheck.Name...)
 	buffer = append(buffer, '|')
@@ -263,8 +263,8 @@ func appendServiceCheck(buffer []byte, serviceCheck *ServiceCheck, globalTags []
 		buffer = appendEscapedServiceCheckText(buffer, serviceCheck.Message)
 	}
 
-	buffer = appendContainerID(buffer)
-	buffer = appendExternalEnv(buffer, originDetection)
+	buffer = appendContainerID(buffer, features)
+	buffer = appendExternalEnv(buffer, originDetection && features.has(agentFeatureExternalEnv))
 	return buffer
 }
 
@@ -272,7 +272,10 @@ func appendSeparator(buffer []byte) []byte {
 	return append(buffer, '\n')
 }
 
-func appendContainerID(buffer []byte) []byte {
+func appendContainerID(buffer []byte, features agentFeatures) []byte {
+	if !features.has(agentFeatureContainerID) {
+		return buffer
+	}
 	if containerID := getContainerID(); len(containerID) > 0 {
 		buffer = append(buffer, "|c:"...)
 		buffer = append(buffer, containerID...)
@@ -280,8 +283,9 @@ func appendContainerID(buffer []byte) []byte {
 	return buffer
 }
 
-func appendTimestamp(buffer []byte, timestamp int64) []byte {
-	if timestamp > noTimestamp {
+// appendTimestamp omits the timestamp when the agent doesn't support it: the agent uses the reception time instead.
+func appendTimestamp(buffer []byte, timestamp int64, features agentFeatures) []byte {
+	if timestamp > noTimestamp && features.has(agentFeatureTimestamp) {
 		buffer = append(buffer, "|T"...)
 		buffer = strconv.AppendInt(buffer, timestamp, 10)
 	}
@@ -301,7 +305,10 @@ func appendExternalEnv(buffer []byte, originDetection bool) []byte {
 	return buffer
 }
 
-func appendTagCardinality(buffer []byte, cardinality Cardinality) []byte {
+func appendTagCardinality(buffer []byte, cardinality Cardinality, features agentFeatures) []byte {
+	if !features.has(agentFeatureCardinality) {
+		return buffer
+	}
 	cardString := cardinality.String()
 	if cardString != "" {
 		buffer = append(buffer, "|card:"...)
@@ -35,14 +35,14 @@ func benchmarkFormat(b *testing.B, tagsNumber int) {
 	}
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
-		payloadSink = appendGauge(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true)
-		payloadSink = appendCount(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true)
-		payloadSink = appendHistogram(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true)
-		payloadSink = appendDistribution(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true)
-		payloadSink = appendSet(payloadSink[:0], "namespace", []string{}, "metric", "setelement", tags, 0.1, true)
-		payloadSink = appendTiming(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true)
-		payloadSink = appendEvent(payloadSink[:0], event, []string{}, true)
-		payloadSink = appendServiceCheck(payloadSink[:0], serviceCheck, []string{}, true)
+		payloadSink = appendGauge(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendCount(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendHistogram(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendDistribution(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendSet(payloadSink[:0], "namespace", []string{}, "metric", "setelement", tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendTiming(payloadSink[:0], "namespace", []string{}, "metric", 1, tags, 0.1, true, allAgentFeatures)
+		payloadSink = appendEvent(payloadSink[:0], event, []string{}, true, allAgentFeatures)
+		payloadSink = appendServiceCheck(payloadSink[:0], serviceCheck, []string{}, true, allAgentFeatures)
 	}
 }
 
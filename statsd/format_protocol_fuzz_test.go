@@ -0,0 +1,133 @@
+//go:build go1.18
+// +build go1.18
+
+package statsd
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+// The fuzz tests skip the inputs the format cannot represent: metric names with ':', tags with ',', fields with '|'
+// or newlines, and backslashes in escaped texts.
+
+func FuzzFormatProtocolMetric(f *testing.F) {
+	f.Add("metric", 1.5, int64(2), "tag:value", "set", 0.5, int64(1658934092), 2)
+	f.Add("ns.metric", -0.000001, int64(-1), "a", "a:b", 1.0, int64(0), 0)
+	f.Add("m", math.Inf(1), int64(0), "tag:a:b", "1", 0.1, int64(-1), 4)
+
+	f.Fuzz(func(t *testing.T, name string, value float64, intValue int64, tag string, setValue string, rate float64, timestamp int64, card int) {
+		if name == "" || strings.ContainsAny(name, ":|\n") || tag == "" || strings.ContainsAny(tag, ",|\n") ||
+			setValue == "" || strings.ContainsAny(setValue, "|\n") || math.IsNaN(value) || math.IsNaN(rate) {
+			t.Skip()
+		}
+		cardinality := Cardinality(card % 5)
+		if cardinality < 0 {
+			cardinality = -cardinality
+		}
+		expectedRate := rate
+		if rate >= 1 {
+			expectedRate = 1
+		}
+
+		buffer := newStatsdBuffer(1<<20, 10)
+		if err := buffer.writeGauge("", nil, name, value, []string{tag}, rate, timestamp, false, cardinality); err != nil {
+			t.Fatal(err)
+		}
+		if err := buffer.writeCount("", nil, name, intValue, []string{tag}, rate, timestamp, false, cardinality); err != nil {
+			t.Fatal(err)
+		}
+		if err := buffer.writeSet("", nil, name, setValue, []string{tag}, rate, false, cardinality); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(string(buffer.bytes()), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines but got %q", lines)
+		}
+		for i, line := range lines {
+			m, err := protocol.ParseMetric(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Name != name || m.Rate != expectedRate || len(m.Tags) != 1 || m.Tags[0] != tag || m.Cardinality != cardinality.String() {
+				t.Fatalf("unexpected metric %+v for line %q", m, line)
+			}
+			switch i {
+			case 0:
+				if len(m.Values) != 1 || m.Values[0] != value || m.Type != protocol.Gauge {
+					t.Fatalf("unexpected gauge %+v for line %q", m, line)
+				}
+			case 1:
+				if len(m.Values) != 1 || m.Values[0] != float64(intValue) || m.Type != protocol.Count {
+					t.Fatalf("unexpected count %+v for line %q", m, line)
+				}
+			case 2:
+				if m.StringValue != setValue || m.Type != protocol.Set {
+					t.Fatalf("unexpected set %+v for line %q", m, line)
+				}
+			}
+			if i < 2 && timestamp > noTimestamp && m.Timestamp.Unix() != timestamp {
+				t.Fatalf("unexpected timestamp %v for line %q", m.Timestamp, line)
+			}
+		}
+	})
+}
+
+func FuzzFormatProtocolEvent(f *testing.F) {
+	f.Add("title", "text", "hostname", "tag", int64(1471219200))
+	f.Add("title|pipe", "multi\nline|text", "", "tag:value", int64(0))
+
+	f.Fuzz(func(t *testing.T, title string, text string, hostname string, tag string, timestamp int64) {
+		if strings.ContainsAny(title, "\n") || strings.ContainsAny(text, "\\") ||
+			strings.ContainsAny(hostname, "|\n") || tag == "" || strings.ContainsAny(tag, ",|\n") {
+			t.Skip()
+		}
+
+		event := &Event{Title: title, Text: text, Hostname: hostname, Tags: []string{tag}}
+		if timestamp > 0 {
+			event.Timestamp = time.Unix(timestamp, 0)
+		}
+		line := string(appendEvent(nil, event, nil, false, allAgentFeatures))
+
+		e, err := protocol.ParseEvent(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Title != title || e.Text != text || e.Hostname != hostname || len(e.Tags) != 1 || e.Tags[0] != tag ||
+			!e.Timestamp.Equal(event.Timestamp) {
+			t.Fatalf("unexpected event %+v for line %q", e, line)
+		}
+	})
+}
+
+func FuzzFormatProtocolServiceCheck(f *testing.F) {
+	f.Add("service.check", uint8(2), "message", "tag", int64(1471219200))
+	f.Add("sc", uint8(0), "multi\nline m:message", "tag:value", int64(0))
+
+	f.Fuzz(func(t *testing.T, name string, status uint8, message string, tag string, timestamp int64) {
+		if name == "" || strings.ContainsAny(name, "|\n") || strings.ContainsAny(message, "|\\") ||
+			tag == "" || strings.ContainsAny(tag, ",|\n") {
+			t.Skip()
+		}
+
+		serviceCheck := &ServiceCheck{Name: name, Status: ServiceCheckStatus(status), Message: message, Tags: []string{tag}}
+		if timestamp > 0 {
+			serviceCheck.Timestamp = time.Unix(timestamp, 0)
+		}
+		line := string(appendServiceCheck(nil, serviceCheck, nil, false, allAgentFeatures))
+
+		sc, err := protocol.ParseServiceCheck(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc.Name != name || sc.Status != int(status) || sc.Message != message || len(sc.Tags) != 1 || sc.Tags[0] != tag ||
+			!sc.Timestamp.Equal(serviceCheck.Timestamp) {
+			t.Fatalf("unexpected service check %+v for line %q", sc, line)
+		}
+	})
+}
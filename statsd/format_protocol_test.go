@@ -0,0 +1,148 @@
+package statsd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+func parseBuffer(t *testing.T, buffer *statsdBuffer) []interface{} {
+	t.Helper()
+
+	var messages []interface{}
+	for _, line := range strings.Split(string(buffer.bytes()), "\n") {
+		if line == "" {
+			continue
+		}
+		msg, err := protocol.Parse(line)
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestFormatProtocolRoundTripMetrics(t *testing.T) {
+	withoutOriginGlobals(t)
+	patchContainerID("container-id")
+	defer resetContainerID()
+	patchExternalEnv("it-false,cn-name,pu-uid")
+	defer resetExternalEnv()
+
+	buffer := newStatsdBuffer(1024, 10)
+	require.NoError(t, buffer.writeGauge("ns.", []string{"global"}, "gauge", 1.5, []string{"tag:1"}, 0.5, 1658934092, true, CardinalityLow))
+	require.NoError(t, buffer.writeCount("ns.", nil, "count", -21, nil, 1, noTimestamp, false, CardinalityNotSet))
+	require.NoError(t, buffer.writeHistogram("ns.", nil, "histogram", 3, nil, 1, true, CardinalityHigh))
+	require.NoError(t, buffer.writeDistribution("ns.", nil, "distribution", 4, nil, 1, true, CardinalityNone))
+	require.NoError(t, buffer.writeSet("ns.", nil, "set", "value", nil, 1, true, CardinalityOrchestrator))
+	require.NoError(t, buffer.writeTiming("ns.", nil, "timing", 1.25, nil, 1, true, CardinalityNotSet))
+	_, err := buffer.writeAggregated(distributionSymbol, "ns.", []string{"global"}, "aggregated", []float64{1, 2.5, -3}, "tag:1,tag:2", 12, -1, 0.25, true, CardinalityLow)
+	require.NoError(t, err)
+
+	messages := parseBuffer(t, buffer)
+	require.Len(t, messages, 7)
+
+	gauge := messages[0].(protocol.Metric)
+	assert.Equal(t, "ns.gauge", gauge.Name)
+	assert.Equal(t, protocol.Gauge, gauge.Type)
+	assert.Equal(t, []float64{1.5}, gauge.Values)
+	assert.Equal(t, 0.5, gauge.Rate)
+	assert.Equal(t, []string{"global", "tag:1"}, gauge.Tags)
+	assert.Equal(t, "container-id", gauge.ContainerID)
+	assert.Equal(t, "it-false,cn-name,pu-uid", gauge.ExternalEnv)
+	assert.Equal(t, "low", gauge.Cardinality)
+	assert.Equal(t, int64(1658934092), gauge.Timestamp.Unix())
+
+	count := messages[1].(protocol.Metric)
+	assert.Equal(t, protocol.Count, count.Type)
+	assert.Equal(t, []float64{-21}, count.Values)
+	assert.Equal(t, 1.0, count.Rate)
+	assert.Nil(t, count.Tags)
+	assert.Equal(t, "", count.ExternalEnv)
+	assert.True(t, count.Timestamp.IsZero())
+
+	assert.Equal(t, protocol.Histogram, messages[2].(protocol.Metric).Type)
+	assert.Equal(t, "high", messages[2].(protocol.Metric).Cardinality)
+	assert.Equal(t, protocol.Distribution, messages[3].(protocol.Metric).Type)
+	assert.Equal(t, "none", messages[3].(protocol.Metric).Cardinality)
+
+	set := messages[4].(protocol.Metric)
+	assert.Equal(t, protocol.Set, set.Type)
+	assert.Equal(t, "value", set.StringValue)
+	assert.Equal(t, "orchestrator", set.Cardinality)
+
+	timing := messages[5].(protocol.Metric)
+	assert.Equal(t, protocol.Timing, timing.Type)
+	assert.Equal(t, []float64{1.25}, timing.Values)
+
+	aggregated := messages[6].(protocol.Metric)
+	assert.Equal(t, "ns.aggregated", aggregated.Name)
+	assert.Equal(t, []float64{1, 2.5, -3}, aggregated.Values)
+	assert.Equal(t, 0.25, aggregated.Rate)
+	assert.Equal(t, []string{"global", "tag:1", "tag:2"}, aggregated.Tags)
+	assert.Equal(t, "container-id", aggregated.ContainerID)
+	assert.Equal(t, "low", aggregated.Cardinality)
+}
+
+func TestFormatProtocolRoundTripEvent(t *testing.T) {
+	withoutOriginGlobals(t)
+	patchContainerID("container-id")
+	defer resetContainerID()
+
+	buffer := newStatsdBuffer(1024, 10)
+	require.NoError(t, buffer.writeEvent(&Event{
+		Title:          "title|with pipe",
+		Text:           "line1\nline2|with pipe",
+		Timestamp:      time.Unix(1471219200, 0),
+		Hostname:       "hostname",
+		AggregationKey: "key",
+		Priority:       Low,
+		SourceTypeName: "source",
+		AlertType:      Warning,
+		Tags:           []string{"tag:1"},
+	}, []string{"global"}, true, CardinalityHigh))
+
+	messages := parseBuffer(t, buffer)
+	require.Len(t, messages, 1)
+	event := messages[0].(protocol.Event)
+	assert.Equal(t, "title|with pipe", event.Title)
+	assert.Equal(t, "line1\nline2|with pipe", event.Text)
+	assert.Equal(t, int64(1471219200), event.Timestamp.Unix())
+	assert.Equal(t, "hostname", event.Hostname)
+	assert.Equal(t, "key", event.AggregationKey)
+	assert.Equal(t, "low", event.Priority)
+	assert.Equal(t, "source", event.SourceTypeName)
+	assert.Equal(t, "warning", event.AlertType)
+	assert.Equal(t, []string{"global", "tag:1"}, event.Tags)
+	assert.Equal(t, "container-id", event.ContainerID)
+	assert.Equal(t, "high", event.Cardinality)
+}
+
+func TestFormatProtocolRoundTripServiceCheck(t *testing.T) {
+	withoutOriginGlobals(t)
+
+	buffer := newStatsdBuffer(1024, 10)
+	require.NoError(t, buffer.writeServiceCheck(&ServiceCheck{
+		Name:      "service.check",
+		Status:    Critical,
+		Timestamp: time.Unix(1471219200, 0),
+		Hostname:  "hostname",
+		Message:   "line1\nm:line2\nmessagem:hello",
+		Tags:      []string{"tag:1"},
+	}, nil, true, CardinalityNone))
+
+	messages := parseBuffer(t, buffer)
+	require.Len(t, messages, 1)
+	sc := messages[0].(protocol.ServiceCheck)
+	assert.Equal(t, "service.check", sc.Name)
+	assert.Equal(t, int(Critical), sc.Status)
+	assert.Equal(t, int64(1471219200), sc.Timestamp.Unix())
+	assert.Equal(t, "hostname", sc.Hostname)
+	assert.Equal(t, "line1\nm:line2\nmessagem:hello", sc.Message)
+	assert.Equal(t, []string{"tag:1"}, sc.Tags)
+	assert.Equal(t, "none", sc.Cardinality)
+}
@@ -48,91 +48,91 @@ func TestFormatAppendTagsAggregated(t *testing.T) {
 func TestFormatAppendGauge(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "namespace.", []string{"global:tag"}, "gauge", 1., []string{"tag:tag"}, 1, true)
+	buffer = appendGauge(buffer, "namespace.", []string{"global:tag"}, "gauge", 1., []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.gauge:1|g|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatAppendCount(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendCount(buffer, "namespace.", []string{"global:tag"}, "count", 2, []string{"tag:tag"}, 1, true)
+	buffer = appendCount(buffer, "namespace.", []string{"global:tag"}, "count", 2, []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.count:2|c|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatAppendHistogram(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendHistogram(buffer, "namespace.", []string{"global:tag"}, "histogram", 3., []string{"tag:tag"}, 1, true)
+	buffer = appendHistogram(buffer, "namespace.", []string{"global:tag"}, "histogram", 3., []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.histogram:3|h|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatAppendDistribution(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendDistribution(buffer, "namespace.", []string{"global:tag"}, "distribution", 4., []string{"tag:tag"}, 1, true)
+	buffer = appendDistribution(buffer, "namespace.", []string{"global:tag"}, "distribution", 4., []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.distribution:4|d|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatAppendSet(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendSet(buffer, "namespace.", []string{"global:tag"}, "set", "five", []string{"tag:tag"}, 1, true)
+	buffer = appendSet(buffer, "namespace.", []string{"global:tag"}, "set", "five", []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.set:five|s|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatAppendTiming(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendTiming(buffer, "namespace.", []string{"global:tag"}, "timing", 6., []string{"tag:tag"}, 1, true)
+	buffer = appendTiming(buffer, "namespace.", []string{"global:tag"}, "timing", 6., []string{"tag:tag"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `namespace.timing:6.000000|ms|#global:tag,tag:tag`, string(buffer))
 }
 
 func TestFormatNoTag(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{}, "gauge", 1., []string{}, 1, true)
+	buffer = appendGauge(buffer, "", []string{}, "gauge", 1., []string{}, 1, true, allAgentFeatures)
 	assert.Equal(t, `gauge:1|g`, string(buffer))
 }
 
 func TestFormatOneTag(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{}, "gauge", 1., []string{"tag1:tag1"}, 1, true)
+	buffer = appendGauge(buffer, "", []string{}, "gauge", 1., []string{"tag1:tag1"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `gauge:1|g|#tag1:tag1`, string(buffer))
 }
 
 func TestFormatTwoTag(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{"tag1:tag1", "tag2:tag2"}, 1, true)
+	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{"tag1:tag1", "tag2:tag2"}, 1, true, allAgentFeatures)
 	assert.Equal(t, `metric:1|g|#tag1:tag1,tag2:tag2`, string(buffer))
 }
 
 func TestFormatRate(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{}, 0.1, true)
+	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{}, 0.1, true, allAgentFeatures)
 	assert.Equal(t, `metric:1|g|@0.1`, string(buffer))
 }
 
 func TestFormatRateAndTag(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{"tag1:tag1"}, 0.1, true)
+	buffer = appendGauge(buffer, "", []string{}, "metric", 1., []string{"tag1:tag1"}, 0.1, true, allAgentFeatures)
 	assert.Equal(t, `metric:1|g|@0.1|#tag1:tag1`, string(buffer))
 }
 
 func TestFormatNil(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", nil, "metric", 1., nil, 1, true)
+	buffer = appendGauge(buffer, "", nil, "metric", 1., nil, 1, true, allAgentFeatures)
 	assert.Equal(t, `metric:1|g`, string(buffer))
 }
 
 func TestFormatTagRemoveNewLines(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendGauge(buffer, "", []string{"tag\n:d\nog\n"}, "metric", 1., []string{"\ntag\n:d\nog2\n"}, 0.1, true)
+	buffer = appendGauge(buffer, "", []string{"tag\n:d\nog\n"}, "metric", 1., []string{"\ntag\n:d\nog2\n"}, 0.1, true, allAgentFeatures)
 	assert.Equal(t, `metric:1|g|@0.1|#tag:dog,tag:dog2`, string(buffer))
 }
 
@@ -142,7 +142,7 @@ func TestFormatEvent(t *testing.T) {
 	buffer = appendEvent(buffer, &Event{
 		Title: "EvenTitle",
 		Text:  "EventText",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText`, string(buffer))
 }
 
@@ -152,7 +152,7 @@ func TestFormatEventEscapeText(t *testing.T) {
 	buffer = appendEvent(buffer, &Event{
 		Title: "EvenTitle",
 		Text:  "\nEventText\nLine2\n\nLine4\n",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,29}:EvenTitle|\nEventText\nLine2\n\nLine4\n`, string(buffer))
 }
 
@@ -163,7 +163,7 @@ func TestFormatEventTimeStamp(t *testing.T) {
 		Title:     "EvenTitle",
 		Text:      "EventText",
 		Timestamp: time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC),
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|d:1471219200`, string(buffer))
 }
 
@@ -174,7 +174,7 @@ func TestFormatEventHostname(t *testing.T) {
 		Title:    "EvenTitle",
 		Text:     "EventText",
 		Hostname: "hostname",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|h:hostname`, string(buffer))
 }
 
@@ -185,7 +185,7 @@ func TestFormatEventAggregationKey(t *testing.T) {
 		Title:          "EvenTitle",
 		Text:           "EventText",
 		AggregationKey: "aggregationKey",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|k:aggregationKey`, string(buffer))
 }
 
@@ -196,7 +196,7 @@ func TestFormatEventPriority(t *testing.T) {
 		Title:    "EvenTitle",
 		Text:     "EventText",
 		Priority: "priority",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|p:priority`, string(buffer))
 }
 
@@ -207,7 +207,7 @@ func TestFormatEventSourceTypeName(t *testing.T) {
 		Title:          "EvenTitle",
 		Text:           "EventText",
 		SourceTypeName: "sourceTypeName",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|s:sourceTypeName`, string(buffer))
 }
 
@@ -218,7 +218,7 @@ func TestFormatEventAlertType(t *testing.T) {
 		Title:     "EvenTitle",
 		Text:      "EventText",
 		AlertType: "alertType",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|t:alertType`, string(buffer))
 }
 
@@ -228,7 +228,7 @@ func TestFormatEventOneTag(t *testing.T) {
 	buffer = appendEvent(buffer, &Event{
 		Title: "EvenTitle",
 		Text:  "EventText",
-	}, []string{"tag:test"}, true)
+	}, []string{"tag:test"}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|#tag:test`, string(buffer))
 }
 
@@ -239,7 +239,7 @@ func TestFormatEventTwoTag(t *testing.T) {
 		Title: "EvenTitle",
 		Text:  "EventText",
 		Tags:  []string{"tag1:test"},
-	}, []string{"tag2:test"}, true)
+	}, []string{"tag2:test"}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|#tag2:test,tag1:test`, string(buffer))
 }
 
@@ -256,14 +256,14 @@ func TestFormatEventAllOptions(t *testing.T) {
 		SourceTypeName: "SourceTypeName",
 		AlertType:      "alertType",
 		Tags:           []string{"tag:normal"},
-	}, []string{"tag:global"}, true)
+	}, []string{"tag:global"}, true, allAgentFeatures)
 	assert.Equal(t, `_e{9,9}:EvenTitle|EventText|d:1471219200|h:hostname|k:aggregationKey|p:priority|s:SourceTypeName|t:alertType|#tag:global,tag:normal`, string(buffer))
 }
 
 func TestFormatEventNil(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendEvent(buffer, &Event{}, []string{}, true)
+	buffer = appendEvent(buffer, &Event{}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_e{0,0}:|`, string(buffer))
 }
 
@@ -273,7 +273,7 @@ func TestFormatServiceCheck(t *testing.T) {
 	buffer = appendServiceCheck(buffer, &ServiceCheck{
 		Name:   "service.check",
 		Status: Ok,
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0`, string(buffer))
 }
 
@@ -284,7 +284,7 @@ func TestFormatServiceCheckEscape(t *testing.T) {
 		Name:    "service.check",
 		Status:  Ok,
 		Message: "\n\nmessagem:hello...\n\nm:aa\nm:m",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|m:\n\nmessagem\:hello...\n\nm\:aa\nm\:m`, string(buffer))
 }
 
@@ -295,7 +295,7 @@ func TestFormatServiceCheckTimestamp(t *testing.T) {
 		Name:      "service.check",
 		Status:    Ok,
 		Timestamp: time.Date(2016, time.August, 15, 0, 0, 0, 0, time.UTC),
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|d:1471219200`, string(buffer))
 }
 
@@ -306,7 +306,7 @@ func TestFormatServiceCheckHostname(t *testing.T) {
 		Name:     "service.check",
 		Status:   Ok,
 		Hostname: "hostname",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|h:hostname`, string(buffer))
 }
 
@@ -317,7 +317,7 @@ func TestFormatServiceCheckMessage(t *testing.T) {
 		Name:    "service.check",
 		Status:  Ok,
 		Message: "message",
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|m:message`, string(buffer))
 }
 
@@ -328,7 +328,7 @@ func TestFormatServiceCheckOneTag(t *testing.T) {
 		Name:   "service.check",
 		Status: Ok,
 		Tags:   []string{"tag:tag"},
-	}, []string{}, true)
+	}, []string{}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|#tag:tag`, string(buffer))
 }
 
@@ -339,7 +339,7 @@ func TestFormatServiceCheckTwoTag(t *testing.T) {
 		Name:   "service.check",
 		Status: Ok,
 		Tags:   []string{"tag1:tag1"},
-	}, []string{"tag2:tag2"}, true)
+	}, []string{"tag2:tag2"}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|#tag2:tag2,tag1:tag1`, string(buffer))
 }
 
@@ -353,14 +353,14 @@ func TestFormatServiceCheckAllOptions(t *testing.T) {
 		Hostname:  "hostname",
 		Message:   "message",
 		Tags:      []string{"tag1:tag1"},
-	}, []string{"tag2:tag2"}, true)
+	}, []string{"tag2:tag2"}, true, allAgentFeatures)
 	assert.Equal(t, `_sc|service.check|0|d:1471219200|h:hostname|#tag2:tag2,tag1:tag1|m:message`, string(buffer))
 }
 
 func TestFormatServiceCheckNil(t *testing.T) {
 	withoutOriginGlobals(t)
 	var buffer []byte
-	buffer = appendServiceCheck(buffer, &ServiceCheck{}, nil, true)
+	buffer = appendServiceCheck(buffer, &ServiceCheck{}, nil, true, allAgentFeatures)
 	assert.Equal(t, `_sc||0`, string(buffer))
 }
 
@@ -0,0 +1,34 @@
+package statsd
+
+// GaugeAggregation is how the samples of a gauge are aggregated on the client during an aggregation interval. It is
+// set for every gauge with WithGaugeAggregation and can be overridden for a call by passing it as a Parameter to
+// Gauge or NewGauge.
+type GaugeAggregation int
+
+const (
+	GaugeAggregationNotSet GaugeAggregation = iota
+	// GaugeAggregationLast sends the last value of the interval, this is the default.
+	GaugeAggregationLast
+	// GaugeAggregationMax sends the highest value of the interval.
+	GaugeAggregationMax
+	// GaugeAggregationMin sends the lowest value of the interval.
+	GaugeAggregationMin
+	// GaugeAggregationSum sends the sum of the values of the interval.
+	GaugeAggregationSum
+	// GaugeAggregationMean sends the average of the values of the interval.
+	GaugeAggregationMean
+)
+
+func (g GaugeAggregation) isValid() bool {
+	return g >= GaugeAggregationNotSet && g <= GaugeAggregationMean
+}
+
+func parameterGaugeAggregation(parameters []Parameter, defaultAggregation GaugeAggregation) GaugeAggregation {
+	for _, o := range parameters {
+		g, ok := o.(GaugeAggregation)
+		if ok && g.isValid() && g != GaugeAggregationNotSet {
+			return g
+		}
+	}
+	return defaultAggregation
+}
@@ -0,0 +1,108 @@
+package statsd
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGaugeMetricAggregation(t *testing.T) {
+	for aggregation, expected := range map[GaugeAggregation]float64{
+		GaugeAggregationNotSet: 5,
+		GaugeAggregationLast:   5,
+		GaugeAggregationMax:    7,
+		GaugeAggregationMin:    1,
+		GaugeAggregationSum:    16,
+		GaugeAggregationMean:   4,
+	} {
+		g := newGaugeMetric("test", 3, nil, CardinalityNotSet)
+		g.aggregation = aggregation
+		g.sample(7)
+		g.sample(1)
+		g.sample(5)
+		assert.Equal(t, expected, g.flushUnsafe().fvalue, "aggregation %d", aggregation)
+	}
+}
+
+func TestGaugeMetricAggregationConcurrency(t *testing.T) {
+	sum := newGaugeMetric("sum", 0, nil, CardinalityNotSet)
+	sum.aggregation = GaugeAggregationSum
+	max := newGaugeMetric("max", 0, nil, CardinalityNotSet)
+	max.aggregation = GaugeAggregationMax
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				sum.sample(1)
+				max.sample(float64(i*1000 + j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8000.0, sum.flushUnsafe().fvalue)
+	assert.Equal(t, 7999.0, max.flushUnsafe().fvalue)
+}
+
+func TestParameterGaugeAggregation(t *testing.T) {
+	assert.Equal(t, GaugeAggregationLast, parameterGaugeAggregation(nil, GaugeAggregationLast))
+	assert.Equal(t, GaugeAggregationMax, parameterGaugeAggregation([]Parameter{CardinalityHigh, GaugeAggregationMax}, GaugeAggregationLast))
+	assert.Equal(t, GaugeAggregationSum, parameterGaugeAggregation([]Parameter{GaugeAggregationNotSet, GaugeAggregation(42)}, GaugeAggregationSum))
+}
+
+func TestWithGaugeAggregation(t *testing.T) {
+	_, err := resolveOptions([]Option{WithGaugeAggregation(GaugeAggregation(42))})
+	assert.EqualError(t, err, "invalid gauge aggregation 42")
+
+	c, err := NewRecordingClientEx(WithGaugeAggregation(GaugeAggregationMax))
+	require.NoError(t, err)
+
+	for _, v := range []float64{3, 9, 4} {
+		require.NoError(t, c.Gauge("queue.depth", v, nil, 1))
+		require.NoError(t, c.Gauge("queue.depth", v, []string{"queue:a"}, 1, CardinalityLow))
+		require.NoError(t, c.Gauge("queue.total", v, nil, 1, GaugeAggregationSum))
+	}
+	require.NoError(t, c.Flush())
+
+	for name, expected := range map[string]float64{
+		"queue.depth": 9,
+		"queue.total": 16,
+	} {
+		value, found := c.LastGauge(name)
+		assert.True(t, found, name)
+		assert.Equal(t, expected, value, name)
+	}
+	value, _ := c.LastGauge("queue.depth", "queue:a")
+	assert.Equal(t, 9.0, value)
+}
+
+func TestHandlesGaugeAggregation(t *testing.T) {
+	c := newHandleTestClient(t, WithGaugeAggregation(GaugeAggregationMax))
+	defer c.Close()
+
+	for _, v := range []float64{3, 9, 4} {
+		require.NoError(t, c.NewGauge("queue.max", nil).Set(v))
+		require.NoError(t, c.NewGauge("queue.min", nil, GaugeAggregationMin).Set(v))
+	}
+
+	gauges := getAllGauges(c.agg)
+	assert.Equal(t, 9.0, gauges["queue.max"].flushUnsafe().fvalue)
+	assert.Equal(t, 3.0, gauges["queue.min"].flushUnsafe().fvalue)
+}
+
+func TestGaugeAggregationOverflow(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.limitContexts(newContextLimiter(1, 0, nil))
+
+	require.NoError(t, a.gaugeWithAggregation("gauge", 1, []string{"user:1"}, CardinalityNotSet, GaugeAggregationSum))
+	require.NoError(t, a.gaugeWithAggregation("gauge", 2, []string{"user:2"}, CardinalityNotSet, GaugeAggregationSum))
+	require.NoError(t, a.gaugeWithAggregation("gauge", 3, []string{"user:3"}, CardinalityNotSet, GaugeAggregationSum))
+
+	// the overflow context keeps the aggregation of the samples
+	assert.Equal(t, 5.0, getAllGauges(a)["gauge:overflow:true"].flushUnsafe().fvalue)
+}
@@ -0,0 +1,324 @@
+package statsd
+
+import (
+	"sync/atomic"
+)
+
+/*
+Handles are pre-bound to a metric name, tags and cardinality. When client side aggregation is enabled they resolve the
+aggregation context and its shard once, at creation, and then update the aggregated metric directly: no context is
+built or hashed on the hot path.
+
+The aggregated metric of a context is replaced every time the aggregator flushes. Each shard keeps an epoch that is
+incremented on flush: a handle whose epoch doesn't match the one of its shard re-attaches itself to the new aggregated
+metric, creating it if needed, under the shard write lock.
+
+When the metric type isn't aggregated by the client, handles fall back on the regular client methods.
+*/
+
+// handleContext is the pre-computed aggregation context of a handle.
+type handleContext struct {
+	client      *ClientEx
+	name        string
+	tags        []string
+	cardinality Cardinality
+	context     string
+	// stringTags is the tags part of the context, used by histograms, distributions and timings.
+	stringTags string
+	shardIndex int
+}
+
+func newHandleContext(c *ClientEx, name string, tags []string, parameters []Parameter) handleContext {
+	h := handleContext{
+		client: c,
+		name:   name,
+		tags:   copySlice(tags),
+	}
+	if c == nil {
+		return h
+	}
+	h.cardinality = parameterCardinality(parameters, c.defaultCardinality)
+
+	cardString := h.cardinality.String()
+	contextBuffer, tagsStart := appendContext(make([]byte, 0, getContextLength(name, h.tags, cardString)), name, h.tags, cardString)
+	h.context = string(contextBuffer)
+	if tagsStart >= 0 {
+		h.stringTags = h.context[tagsStart:]
+	}
+	if c.agg != nil {
+		// The hash must be computed the same way the aggregator does to land on the same shard.
+		_, contextHash := appendContextAndHash(nil, name, h.tags, cardString)
+		h.shardIndex = getShardIndexFromHash(c.agg.shardsCount, contextHash)
+	}
+	return h
+}
+
+// Counter is a handle on a count with a fixed name, tags and cardinality. It is safe to use from multiple goroutines.
+type Counter struct {
+	handleContext
+	shard *countShard
+	// metric and epoch are protected by the shard lock.
+	metric *countMetric
+	epoch  uint64
+}
+
+// NewCounter returns a handle on the count with the given name and tags.
+func (c *ClientEx) NewCounter(name string, tags []string, parameters ...Parameter) *Counter {
+	counter := &Counter{handleContext: newHandleContext(c, name, tags, parameters)}
+	if c != nil && c.agg != nil {
+		counter.shard = &c.agg.countShards[counter.shardIndex]
+	}
+	return counter
+}
+
+// Add tracks how many times something happened per second.
+func (h *Counter) Add(value int64) error {
+	if h == nil || h.client == nil {
+		return ErrNoClient
+	}
+	if h.shard == nil {
+		return h.client.Count(h.name, value, h.tags, 1, h.cardinality)
+	}
+	atomic.AddUint64(&h.client.telemetry.totalMetricsCount, 1)
+
+	h.shard.RLock()
+	if h.metric != nil && h.epoch == h.shard.epoch {
+		h.metric.sample(value)
+		h.shard.RUnlock()
+		return nil
+	}
+	h.shard.RUnlock()
+
+	h.shard.Lock()
+	if count, found := h.shard.counts[h.context]; found {
+		count.sample(value)
+		h.metric = count
+	} else if admitted, overflow := h.client.agg.limiter.admit(h.name, false); !admitted {
+		h.shard.Unlock()
+		h.client.agg.limiter.report(overflow)
+		return h.client.agg.countOverflow(h.name, value, h.cardinality)
+	} else {
+		if h.shard.counts == nil {
+			h.shard.counts = countsMap{}
+		}
+		h.metric = newCountMetric(h.name, value, h.tags, h.cardinality)
+		h.shard.counts[h.context] = h.metric
+	}
+	h.epoch = h.shard.epoch
+	h.shard.Unlock()
+	return nil
+}
+
+// Incr is just Add of 1
+func (h *Counter) Incr() error {
+	return h.Add(1)
+}
+
+// Decr is just Add of -1
+func (h *Counter) Decr() error {
+	return h.Add(-1)
+}
+
+// Gauge is a handle on a gauge with a fixed name, tags, cardinality and aggregation. It is safe to use from multiple
+// goroutines.
+type Gauge struct {
+	handleContext
+	aggregation GaugeAggregation
+	shard       *gaugeShard
+	// metric and epoch are protected by the shard lock.
+	metric *gaugeMetric
+	epoch  uint64
+}
+
+// NewGauge returns a handle on the gauge with the given name and tags.
+func (c *ClientEx) NewGauge(name string, tags []string, parameters ...Parameter) *Gauge {
+	gauge := &Gauge{handleContext: newHandleContext(c, name, tags, parameters)}
+	if c != nil && c.agg != nil {
+		gauge.aggregation = parameterGaugeAggregation(parameters, c.agg.gaugeAggregation)
+		gauge.shard = &c.agg.gaugeShards[gauge.shardIndex]
+	}
+	return gauge
+}
+
+// Set measures the value of the gauge at a particular time.
+func (h *Gauge) Set(value float64) error {
+	if h == nil || h.client == nil {
+		return ErrNoClient
+	}
+	if h.shard == nil {
+		return h.client.Gauge(h.name, value, h.tags, 1, h.cardinality)
+	}
+	atomic.AddUint64(&h.client.telemetry.totalMetricsGauge, 1)
+
+	h.shard.RLock()
+	if h.metric != nil && h.epoch == h.shard.epoch {
+		h.metric.sample(value)
+		h.shard.RUnlock()
+		return nil
+	}
+	h.shard.RUnlock()
+
+	h.shard.Lock()
+	if gauge, found := h.shard.gauges[h.context]; found {
+		gauge.sample(value)
+		h.metric = gauge
+	} else if admitted, overflow := h.client.agg.limiter.admit(h.name, false); !admitted {
+		h.shard.Unlock()
+		h.client.agg.limiter.report(overflow)
+		return h.client.agg.gaugeOverflow(h.name, value, h.cardinality, h.aggregation)
+	} else {
+		if h.shard.gauges == nil {
+			h.shard.gauges = gaugesMap{}
+		}
+		h.metric = h.client.agg.newGaugeMetric(h.name, value, h.tags, h.cardinality, h.aggregation)
+		h.shard.gauges[h.context] = h.metric
+	}
+	h.epoch = h.shard.epoch
+	h.shard.Unlock()
+	return nil
+}
+
+// Set is a handle on a set with a fixed name, tags and cardinality. It is safe to use from multiple goroutines.
+type Set struct {
+	handleContext
+	shard *setShard
+	// metric and epoch are protected by the shard lock.
+	metric *setMetric
+	epoch  uint64
+}
+
+// NewSet returns a handle on the set with the given name and tags.
+func (c *ClientEx) NewSet(name string, tags []string, parameters ...Parameter) *Set {
+	set := &Set{handleContext: newHandleContext(c, name, tags, parameters)}
+	if c != nil && c.agg != nil {
+		set.shard = &c.agg.setShards[set.shardIndex]
+	}
+	return set
+}
+
+// Add counts the value as an element of the set.
+func (h *Set) Add(value string) error {
+	if h == nil || h.client == nil {
+		return ErrNoClient
+	}
+	if h.shard == nil {
+		return h.client.Set(h.name, value, h.tags, 1, h.cardinality)
+	}
+	atomic.AddUint64(&h.client.telemetry.totalMetricsSet, 1)
+
+	h.shard.RLock()
+	if h.metric != nil && h.epoch == h.shard.epoch {
+		h.metric.sample(value)
+		h.shard.RUnlock()
+		return nil
+	}
+	h.shard.RUnlock()
+
+	h.shard.Lock()
+	if set, found := h.shard.sets[h.context]; found {
+		set.sample(value)
+		h.metric = set
+	} else if admitted, overflow := h.client.agg.limiter.admit(h.name, false); !admitted {
+		h.shard.Unlock()
+		h.client.agg.limiter.report(overflow)
+		return h.client.agg.setOverflow(h.name, value, h.cardinality)
+	} else {
+		if h.shard.sets == nil {
+			h.shard.sets = setsMap{}
+		}
+		h.metric = h.client.agg.newSetMetric(h.name, value, h.tags, h.cardinality)
+		h.shard.sets[h.context] = h.metric
+	}
+	h.epoch = h.shard.epoch
+	h.shard.Unlock()
+	return nil
+}
+
+// Distribution is a handle on a distribution with a fixed name, tags and cardinality. It is safe to use from multiple
+// goroutines.
+type Distribution struct {
+	handleContext
+	contexts *bufferedMetricContexts
+	// metric and epoch are protected by the contexts lock.
+	metric *bufferedMetric
+	epoch  uint64
+}
+
+// NewDistribution returns a handle on the distribution with the given name and tags. Samples are only aggregated when
+// the extended client side aggregation is enabled with the mutex mode.
+func (c *ClientEx) NewDistribution(name string, tags []string, parameters ...Parameter) *Distribution {
+	distribution := &Distribution{handleContext: newHandleContext(c, name, tags, parameters)}
+	if c != nil && c.aggExtended != nil && c.aggregatorMode == mutexMode {
+		distribution.contexts = &c.aggExtended.distributions
+	}
+	return distribution
+}
+
+// Sample adds a value to the distribution.
+func (h *Distribution) Sample(value float64) error {
+	if h == nil || h.client == nil {
+		return ErrNoClient
+	}
+	if h.contexts == nil {
+		return h.client.Distribution(h.name, value, h.tags, 1, h.cardinality)
+	}
+	atomic.AddUint64(&h.client.telemetry.totalMetricsDistribution, 1)
+
+	bc := h.contexts
+	bc.mutex.RLock()
+	if h.metric != nil && h.epoch == bc.epoch {
+		h.metric.maybeKeepSample(value, 1, bc.random, &bc.randomLock)
+		bc.mutex.RUnlock()
+		return nil
+	}
+	bc.mutex.RUnlock()
+
+	bc.mutex.Lock()
+	if v, found := bc.values[h.context]; found {
+		v.maybeKeepSample(value, 1, bc.random, &bc.randomLock)
+		h.metric = v
+	} else if admitted, overflow := bc.limiter.admit(h.name, false); !admitted {
+		bc.mutex.Unlock()
+		bc.limiter.report(overflow)
+		return bc.sampleOverflow(h.name, value, 1, h.cardinality)
+	} else {
+		h.metric = bc.newContext(h.name, value, h.stringTags, 1, h.cardinality)
+		bc.values[h.context] = h.metric
+	}
+	h.epoch = bc.epoch
+	bc.mutex.Unlock()
+	return nil
+}
+
+// NewCounter returns a handle on the count with the given name and tags.
+func (c *Client) NewCounter(name string, tags []string) *Counter {
+	if c == nil {
+		return &Counter{}
+	}
+	return c.clientEx.NewCounter(name, tags)
+}
+
+// NewGauge returns a handle on the gauge with the given name and tags.
+func (c *Client) NewGauge(name string, tags []string) *Gauge {
+	if c == nil {
+		return &Gauge{}
+	}
+	return c.clientEx.NewGauge(name, tags)
+}
+
+// NewSet returns a handle on the set with the given name and tags.
+func (c *Client) NewSet(name string, tags []string) *Set {
+	if c == nil {
+		return &Set{}
+	}
+	return c.clientEx.NewSet(name, tags)
+}
+
+// NewDistribution returns a handle on the distribution with the given name and tags. Samples are only aggregated when
+// the extended client side aggregation is enabled with the mutex mode.
+func (c *Client) NewDistribution(name string, tags []string) *Distribution {
+	if c == nil {
+		return &Distribution{}
+	}
+	return c.clientEx.NewDistribution(name, tags)
+}
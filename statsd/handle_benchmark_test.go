@@ -0,0 +1,43 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+// Compares the regular aggregated Count with a pre-bound Counter handle on a
+// single hot context.
+func BenchmarkHandleCount(b *testing.B) {
+	tags := []string{"tag:1", "tag:2", "tag:3"}
+
+	b.Run("Count", func(b *testing.B) {
+		c, _ := NewEx("localhost:8765", WithoutTelemetry(), WithAggregationInterval(time.Hour))
+		defer c.Close()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var err error
+			for pb.Next() {
+				err = c.Count("metric.name", 1, tags, 1)
+			}
+			benchErr = err
+		})
+	})
+
+	b.Run("Counter", func(b *testing.B) {
+		c, _ := NewEx("localhost:8765", WithoutTelemetry(), WithAggregationInterval(time.Hour))
+		defer c.Close()
+		counter := c.NewCounter("metric.name", tags)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var err error
+			for pb.Next() {
+				err = counter.Add(1)
+			}
+			benchErr = err
+		})
+	})
+}
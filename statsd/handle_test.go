@@ -0,0 +1,269 @@
+package statsd
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHandleTestClient(t *testing.T, options ...Option) *ClientEx {
+	options = append([]Option{WithoutTelemetry(), WithAggregationInterval(time.Hour)}, options...)
+	c, err := NewEx("localhost:8765", options...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestCounterHandle(t *testing.T) {
+	c := newHandleTestClient(t, WithClientSideAggregation(), WithAggregatorShardCount(4))
+	defer c.Close()
+
+	counter := c.NewCounter("count", []string{"tag:1", "tag:2"})
+	require.NoError(t, counter.Add(2))
+	require.NoError(t, counter.Incr())
+	// Regular calls share the same context as the handle.
+	require.NoError(t, c.Count("count", 3, []string{"tag:1", "tag:2"}, 1))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, metric{metricType: count, name: "count", tags: []string{"tag:1", "tag:2"}, rate: 1, ivalue: 6}, metrics[0])
+
+	// The handle re-attaches after the flush.
+	require.NoError(t, counter.Decr())
+	metrics = c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(-1), metrics[0].ivalue)
+
+	// Regular calls made after a flush are merged with the handle.
+	require.NoError(t, c.Count("count", 5, []string{"tag:1", "tag:2"}, 1))
+	require.NoError(t, counter.Add(1))
+	metrics = c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(6), metrics[0].ivalue)
+
+	assert.Equal(t, uint64(6), c.telemetry.totalMetricsCount)
+}
+
+func TestCounterHandleNoTags(t *testing.T) {
+	c := newHandleTestClient(t, WithClientSideAggregation(), WithAggregatorShardCount(4))
+	defer c.Close()
+
+	counter := c.NewCounter("count", nil)
+	require.NoError(t, counter.Add(2))
+	require.NoError(t, c.Incr("count", nil, 1))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(3), metrics[0].ivalue)
+}
+
+func TestCounterHandleCardinality(t *testing.T) {
+	c := newHandleTestClient(t, WithClientSideAggregation())
+	defer c.Close()
+
+	require.NoError(t, c.NewCounter("count", []string{"tag"}, CardinalityHigh).Add(1))
+	require.NoError(t, c.NewCounter("count", []string{"tag"}).Add(2))
+	require.NoError(t, c.Count("count", 4, []string{"tag"}, 1, CardinalityHigh))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 2)
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].cardinality < metrics[j].cardinality })
+	assert.Equal(t, int64(2), metrics[0].ivalue)
+	assert.Equal(t, CardinalityNotSet, metrics[0].cardinality)
+	assert.Equal(t, int64(5), metrics[1].ivalue)
+	assert.Equal(t, CardinalityHigh, metrics[1].cardinality)
+}
+
+func TestGaugeHandle(t *testing.T) {
+	c := newHandleTestClient(t, WithClientSideAggregation(), WithAggregatorShardCount(4))
+	defer c.Close()
+
+	handle := c.NewGauge("gauge", []string{"tag"})
+	require.NoError(t, handle.Set(1))
+	require.NoError(t, handle.Set(2))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, metric{metricType: gauge, name: "gauge", tags: []string{"tag"}, rate: 1, fvalue: 2}, metrics[0])
+
+	require.NoError(t, c.Gauge("gauge", 3, []string{"tag"}, 1))
+	require.NoError(t, handle.Set(4))
+	metrics = c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 4.0, metrics[0].fvalue)
+
+	assert.Equal(t, uint64(4), c.telemetry.totalMetricsGauge)
+}
+
+func TestSetHandle(t *testing.T) {
+	c := newHandleTestClient(t, WithClientSideAggregation(), WithAggregatorShardCount(4))
+	defer c.Close()
+
+	set := c.NewSet("set", []string{"tag"})
+	require.NoError(t, set.Add("a"))
+	require.NoError(t, set.Add("a"))
+	require.NoError(t, c.Set("set", "b", []string{"tag"}, 1))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 2)
+	values := []string{metrics[0].svalue, metrics[1].svalue}
+	sort.Strings(values)
+	assert.Equal(t, []string{"a", "b"}, values)
+
+	require.NoError(t, set.Add("c"))
+	metrics = c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "c", metrics[0].svalue)
+
+	assert.Equal(t, uint64(4), c.telemetry.totalMetricsSet)
+}
+
+func TestDistributionHandle(t *testing.T) {
+	c := newHandleTestClient(t, WithExtendedClientSideAggregation())
+	defer c.Close()
+
+	distribution := c.NewDistribution("distribution", []string{"tag:1", "tag:2"})
+	require.NoError(t, distribution.Sample(1))
+	require.NoError(t, distribution.Sample(2))
+	require.NoError(t, c.Distribution("distribution", 3, []string{"tag:1", "tag:2"}, 1))
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, distributionAggregated, metrics[0].metricType)
+	assert.Equal(t, "tag:1,tag:2", metrics[0].stags)
+	assert.Equal(t, []float64{1, 2, 3}, metrics[0].fvalues)
+	assert.Equal(t, 1.0, metrics[0].rate)
+
+	require.NoError(t, distribution.Sample(4))
+	metrics = c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, []float64{4}, metrics[0].fvalues)
+
+	assert.Equal(t, uint64(4), c.telemetry.totalMetricsDistribution)
+}
+
+func TestDistributionHandleMaxSamples(t *testing.T) {
+	c := newHandleTestClient(t, WithExtendedClientSideAggregation(), WithMaxSamplesPerContext(2))
+	defer c.Close()
+
+	distribution := c.NewDistribution("distribution", nil)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, distribution.Sample(float64(i)))
+	}
+
+	metrics := c.agg.flushMetrics()
+	require.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].fvalues, 2)
+	assert.Equal(t, 0.2, metrics[0].rate)
+}
+
+func TestHandlesConcurrentFlush(t *testing.T) {
+	c := newHandleTestClient(t, WithExtendedClientSideAggregation(), WithAggregatorShardCount(4))
+	defer c.Close()
+
+	counter := c.NewCounter("count", []string{"tag"})
+	distribution := c.NewDistribution("distribution", []string{"tag"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.Incr()
+				distribution.Sample(1)
+			}
+		}()
+	}
+
+	var total int64
+	var samples int
+	collect := func(metrics []metric) {
+		for _, m := range metrics {
+			if m.metricType == count {
+				total += m.ivalue
+			} else {
+				samples += len(m.fvalues)
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			collect(c.agg.flushMetrics())
+		}
+	}
+	collect(c.agg.flushMetrics())
+
+	assert.Equal(t, int64(4000), total)
+	assert.Equal(t, 4000, samples)
+}
+
+func TestHandlesWithoutAggregation(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithoutTelemetry(),
+		WithoutClientSideAggregation(),
+	)
+
+	require.NoError(t, client.NewCounter("count", []string{"tag"}).Add(2))
+	require.NoError(t, client.NewGauge("gauge", []string{"tag"}).Set(3))
+	require.NoError(t, client.NewSet("set", []string{"tag"}).Add("value"))
+	require.NoError(t, client.NewDistribution("distribution", []string{"tag"}).Sample(4))
+
+	assert.Equal(t, uint64(1), client.clientEx.telemetry.totalMetricsCount)
+	assert.Equal(t, uint64(1), client.clientEx.telemetry.totalMetricsGauge)
+	assert.Equal(t, uint64(1), client.clientEx.telemetry.totalMetricsSet)
+	assert.Equal(t, uint64(1), client.clientEx.telemetry.totalMetricsDistribution)
+
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"count:2|c|#tag" + containerID,
+		"gauge:3|g|#tag" + containerID,
+		"set:value|s|#tag" + containerID,
+		"distribution:4|d|#tag" + containerID,
+	})
+}
+
+func TestDistributionHandleWithoutExtendedAggregation(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithoutTelemetry(),
+		WithClientSideAggregation(),
+	)
+
+	require.NoError(t, client.NewDistribution("distribution", nil).Sample(4))
+
+	ts.assert(t, client, []string{
+		"distribution:4|d" + ts.getContainerID(),
+	})
+}
+
+func TestHandlesNilClient(t *testing.T) {
+	var c *Client
+	assert.Equal(t, ErrNoClient, c.NewCounter("count", nil).Add(1))
+	assert.Equal(t, ErrNoClient, c.NewGauge("gauge", nil).Set(1))
+	assert.Equal(t, ErrNoClient, c.NewSet("set", nil).Add("value"))
+	assert.Equal(t, ErrNoClient, c.NewDistribution("distribution", nil).Sample(1))
+
+	var cEx *ClientEx
+	assert.Equal(t, ErrNoClient, cEx.NewCounter("count", nil).Incr())
+
+	var counter *Counter
+	assert.Equal(t, ErrNoClient, counter.Add(1))
+}
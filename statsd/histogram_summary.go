@@ -0,0 +1,152 @@
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultSummaryPercentiles are the percentiles computed by WithHistogramSummary when none are given.
+var defaultSummaryPercentiles = []float64{0.5, 0.95, 0.99}
+
+// histogramSummary is the configuration of the client-side summary of histograms and timings.
+type histogramSummary struct {
+	percentiles []float64
+	// suffixes are the metric name suffixes of the percentiles, like ".95percentile".
+	suffixes []string
+}
+
+func newHistogramSummary(percentiles []float64) (*histogramSummary, error) {
+	if len(percentiles) == 0 {
+		percentiles = defaultSummaryPercentiles
+	}
+	s := &histogramSummary{}
+	for _, p := range percentiles {
+		if !(p > 0 && p <= 1) {
+			return nil, fmt.Errorf("percentile must be in (0, 1], got %v", p)
+		}
+		s.percentiles = append(s.percentiles, p)
+		// The agent names the percentiles it computes from histograms the same way, except the median.
+		if p == 0.5 {
+			s.suffixes = append(s.suffixes, ".median")
+		} else {
+			s.suffixes = append(s.suffixes, "."+strconv.FormatFloat(math.Round(p*10000)/100, 'f', -1, 64)+"percentile")
+		}
+	}
+	return s, nil
+}
+
+// summaryStats are the exact statistics of all the samples of a context, weighted by their user-specified rate. The
+// percentiles are computed from the kept samples, which are all the samples unless WithMaxSamplesPerContext is used.
+type summaryStats struct {
+	sum    float64
+	weight float64
+	min    float64
+	max    float64
+}
+
+func newSummaryStats(value float64, weight float64) *summaryStats {
+	return &summaryStats{sum: value * weight, weight: weight, min: value, max: value}
+}
+
+func (s *summaryStats) add(v float64, weight float64) {
+	s.sum += v * weight
+	s.weight += weight
+	if v < s.min {
+		s.min = v
+	}
+	if v > s.max {
+		s.max = v
+	}
+}
+
+// weightedValues sorts values along with their weights.
+type weightedValues struct {
+	values  []float64
+	weights []float64
+}
+
+func (w weightedValues) Len() int           { return len(w.values) }
+func (w weightedValues) Less(i, j int) bool { return w.values[i] < w.values[j] }
+func (w weightedValues) Swap(i, j int) {
+	w.values[i], w.values[j] = w.values[j], w.values[i]
+	w.weights[i], w.weights[j] = w.weights[j], w.weights[i]
+}
+
+// percentile returns the nearest-rank percentile of sorted values. weights are the weights of the values, nil when
+// they all have the same weight.
+func percentile(sorted []float64, weights []float64, p float64) float64 {
+	if weights == nil {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	rank := p * total
+	seen := 0.0
+	for i, w := range weights {
+		seen += w
+		if seen >= rank {
+			return sorted[i]
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+// summarizeUnsafe appends the summary of the metric to metrics: the min, max, avg and percentiles as gauges and the
+// number of samples as a count. The agent turns the count into a rate per second, which is what it sends for the
+// ".count" of the histograms it summarizes.
+func (s *bufferedMetric) summarizeUnsafe(metrics []metric) []metric {
+	var tags []string
+	if s.tags != "" {
+		tags = strings.Split(s.tags, tagSeparatorSymbol)
+	}
+	newGauge := func(suffix string, value float64) metric {
+		return metric{
+			metricType:  gauge,
+			name:        s.name + suffix,
+			tags:        tags,
+			rate:        1,
+			fvalue:      value,
+			cardinality: s.cardinality,
+		}
+	}
+
+	metrics = append(metrics,
+		newGauge(".min", s.stats.min),
+		newGauge(".max", s.stats.max),
+		newGauge(".avg", s.stats.sum/s.stats.weight),
+		metric{
+			metricType:  count,
+			name:        s.name + ".count",
+			tags:        tags,
+			rate:        1,
+			ivalue:      int64(math.Round(s.stats.weight)),
+			cardinality: s.cardinality,
+		},
+	)
+
+	sorted := s.data[:s.storedSamples]
+	var weights []float64
+	if s.rates != nil {
+		weights = make([]float64, len(sorted))
+		for i := range weights {
+			weights[i] = sampleWeight(s.rates[i])
+		}
+		sort.Sort(weightedValues{values: sorted, weights: weights})
+	} else {
+		sort.Float64s(sorted)
+	}
+	for i, p := range s.summary.percentiles {
+		metrics = append(metrics, newGauge(s.summary.suffixes[i], percentile(sorted, weights, p)))
+	}
+	return metrics
+}
@@ -0,0 +1,136 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistogramSummary(t *testing.T) {
+	s, err := newHistogramSummary(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 0.95, 0.99}, s.percentiles)
+	assert.Equal(t, []string{".median", ".95percentile", ".99percentile"}, s.suffixes)
+
+	s, err = newHistogramSummary([]float64{0.999, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".99.9percentile", ".100percentile"}, s.suffixes)
+
+	for _, p := range []float64{0, -0.5, 1.5} {
+		_, err = newHistogramSummary([]float64{p})
+		assert.Error(t, err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 5.0, percentile(sorted, nil, 0.5))
+	assert.Equal(t, 10.0, percentile(sorted, nil, 0.95))
+	assert.Equal(t, 10.0, percentile(sorted, nil, 1))
+	assert.Equal(t, 1.0, percentile(sorted, nil, 0.01))
+	assert.Equal(t, 3.0, percentile([]float64{3}, nil, 0.5))
+
+	weights := []float64{1, 4, 1, 1, 1}
+	sorted = []float64{1, 2, 3, 4, 5}
+	assert.Equal(t, 2.0, percentile(sorted, weights, 0.5))
+	assert.Equal(t, 4.0, percentile(sorted, weights, 0.8))
+	assert.Equal(t, 5.0, percentile(sorted, weights, 1))
+}
+
+func TestBufferedMetricContextsSummary(t *testing.T) {
+	summary, err := newHistogramSummary([]float64{0.5, 0.9})
+	require.NoError(t, err)
+	contexts := newBufferedContexts(newHistogramMetric, 0)
+	contexts.summary = summary
+
+	for _, v := range []float64{5, 1, 4, 2, 3, 10, 6, 9, 8, 7} {
+		require.NoError(t, contexts.sample("latency", v, []string{"a:b", "c:d"}, 1, CardinalityNotSet))
+	}
+
+	metrics := contexts.flush(nil)
+	tags := []string{"a:b", "c:d"}
+	assert.Equal(t, []metric{
+		{metricType: gauge, name: "latency.min", tags: tags, rate: 1, fvalue: 1},
+		{metricType: gauge, name: "latency.max", tags: tags, rate: 1, fvalue: 10},
+		{metricType: gauge, name: "latency.avg", tags: tags, rate: 1, fvalue: 5.5},
+		{metricType: count, name: "latency.count", tags: tags, rate: 1, ivalue: 10},
+		{metricType: gauge, name: "latency.median", tags: tags, rate: 1, fvalue: 5},
+		{metricType: gauge, name: "latency.90percentile", tags: tags, rate: 1, fvalue: 9},
+	}, metrics)
+	assert.Empty(t, contexts.values)
+}
+
+func TestBufferedMetricContextsSummaryReservoir(t *testing.T) {
+	summary, err := newHistogramSummary([]float64{0.5})
+	require.NoError(t, err)
+	contexts := newBufferedContexts(newTimingMetric, 2)
+	contexts.summary = summary
+
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, contexts.sample("latency", float64(i), nil, 1, CardinalityNotSet))
+	}
+
+	metrics := contexts.flush(nil)
+	require.Len(t, metrics, 5)
+	// min, max, avg and count are exact even when only 2 samples are kept
+	assert.Equal(t, 1.0, metrics[0].fvalue)
+	assert.Equal(t, 100.0, metrics[1].fvalue)
+	assert.Equal(t, 50.5, metrics[2].fvalue)
+	assert.Equal(t, int64(100), metrics[3].ivalue)
+	assert.Nil(t, metrics[0].tags)
+}
+
+func TestBufferedMetricContextsSummaryRate(t *testing.T) {
+	summary, err := newHistogramSummary([]float64{0.5, 0.99})
+	require.NoError(t, err)
+	contexts := newBufferedContexts(newHistogramMetric, 0)
+	contexts.summary = summary
+	m := contexts.newContext("latency", 1, "", 0.5, CardinalityNotSet)
+	m.maybeKeepSample(4, 1, contexts.random, &contexts.randomLock)
+
+	// every sample is weighted by its own rate
+	assert.Equal(t, []metric{
+		{metricType: gauge, name: "latency.min", rate: 1, fvalue: 1},
+		{metricType: gauge, name: "latency.max", rate: 1, fvalue: 4},
+		{metricType: gauge, name: "latency.avg", rate: 1, fvalue: 2},
+		{metricType: count, name: "latency.count", rate: 1, ivalue: 3},
+		{metricType: gauge, name: "latency.median", rate: 1, fvalue: 1},
+		{metricType: gauge, name: "latency.99percentile", rate: 1, fvalue: 4},
+	}, m.summarizeUnsafe(nil))
+}
+
+func TestWithHistogramSummary(t *testing.T) {
+	_, err := resolveOptions([]Option{WithHistogramSummary(2)})
+	assert.EqualError(t, err, "percentile must be in (0, 1], got 2")
+
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithHistogramSummary(0.5),
+		WithoutTelemetry(),
+	)
+
+	require.NoError(t, client.Histogram("histogram", 1, []string{"tag"}, 1))
+	require.NoError(t, client.Histogram("histogram", 3, []string{"tag"}, 1))
+	require.NoError(t, client.TimeInMilliseconds("timing", 2, nil, 1))
+	require.NoError(t, client.Distribution("distribution", 4, nil, 1))
+	require.NoError(t, client.Flush())
+
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"histogram.min:1|g|#tag" + containerID,
+		"histogram.max:3|g|#tag" + containerID,
+		"histogram.avg:2|g|#tag" + containerID,
+		"histogram.count:2|c|#tag" + containerID,
+		"histogram.median:1|g|#tag" + containerID,
+		"timing.min:2|g" + containerID,
+		"timing.max:2|g" + containerID,
+		"timing.avg:2|g" + containerID,
+		"timing.count:1|c" + containerID,
+		"timing.median:2|g" + containerID,
+		// distributions are not summarized
+		"distribution:4|d" + containerID,
+	})
+}
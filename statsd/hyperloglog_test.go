@@ -0,0 +1,90 @@
+package statsd
+
+import (
+	"fmt"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLogAccuracy(t *testing.T) {
+	for _, n := range []int{10, 1000, 20000, 50000, 200000} {
+		h := &hyperLogLog{}
+		for i := 0; i < n; i++ {
+			v := fmt.Sprintf("user-%d", i)
+			h.add(v)
+			// duplicates don't change the estimate
+			h.add(v)
+		}
+		assert.InEpsilon(t, float64(n), h.estimate(), 0.03, "%d unique values", n)
+	}
+	assert.Equal(t, 0.0, (&hyperLogLog{}).estimate())
+}
+
+func TestSetMetricEstimation(t *testing.T) {
+	exact := newSetMetric("users", "user-0", []string{"tag"}, CardinalityLow)
+	estimated := newSetMetric("users", "user-0", []string{"tag"}, CardinalityLow)
+	estimated.estimationThreshold = 100
+
+	for i := 1; i < 100; i++ {
+		v := fmt.Sprintf("user-%d", i)
+		exact.sample(v)
+		estimated.sample(v)
+	}
+	// the set is exact up to the threshold
+	assert.Nil(t, estimated.hll)
+	assert.Len(t, estimated.flushUnsafe(), 100)
+
+	for i := 100; i < 50000; i++ {
+		v := fmt.Sprintf("user-%d", i%30000)
+		exact.sample(v)
+		estimated.sample(v)
+	}
+	require.NotNil(t, estimated.hll)
+	assert.Nil(t, estimated.data)
+
+	expected := exact.flushUnsafe()
+	require.Len(t, expected, 30000)
+	metrics := estimated.flushUnsafe()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, gauge, metrics[0].metricType)
+	assert.Equal(t, "users", metrics[0].name)
+	assert.Equal(t, []string{"tag"}, metrics[0].tags)
+	assert.Equal(t, CardinalityLow, metrics[0].cardinality)
+	assert.InEpsilon(t, float64(len(expected)), metrics[0].fvalue, 0.03)
+
+	// the memory of an estimated set doesn't depend on its number of values
+	assert.Equal(t, uintptr(hllRegisters), unsafe.Sizeof(*estimated.hll))
+}
+
+func TestWithSetCardinalityEstimation(t *testing.T) {
+	_, err := resolveOptions([]Option{WithSetCardinalityEstimation(0)})
+	assert.EqualError(t, err, "threshold must be positive")
+
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8773",
+		nil,
+		WithSetCardinalityEstimation(2),
+		WithoutTelemetry(),
+	)
+
+	require.NoError(t, client.Set("small", "a", nil, 1))
+	require.NoError(t, client.Set("small", "a", nil, 1))
+	for _, v := range []string{"a", "b", "c", "b"} {
+		require.NoError(t, client.Set("large", v, nil, 1))
+	}
+	require.NoError(t, client.NewSet("handle", []string{"tag"}).Add("a"))
+	require.NoError(t, client.NewSet("handle", []string{"tag"}).Add("b"))
+	require.NoError(t, client.NewSet("handle", []string{"tag"}).Add("c"))
+	require.NoError(t, client.Flush())
+
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"small:a|s" + containerID,
+		"large:3|g" + containerID,
+		"handle:3|g|#tag" + containerID,
+	})
+}
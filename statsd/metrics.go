@@ -48,7 +48,10 @@ func (c *countMetric) flushUnsafe() metric {
 // Gauge
 
 type gaugeMetric struct {
-	value       uint64
+	value uint64
+	// count is the number of samples, used by GaugeAggregationMean.
+	count       uint64
+	aggregation GaugeAggregation
 	name        string
 	tags        []string
 	cardinality Cardinality
@@ -57,6 +60,7 @@ type gaugeMetric struct {
 func newGaugeMetric(name string, value float64, tags []string, cardinality Cardinality) *gaugeMetric {
 	return &gaugeMetric{
 		value:       math.Float64bits(value),
+		count:       1,
 		name:        name,
 		tags:        copySlice(tags),
 		cardinality: cardinality,
@@ -64,16 +68,48 @@ func newGaugeMetric(name string, value float64, tags []string, cardinality Cardi
 }
 
 func (g *gaugeMetric) sample(v float64) {
-	atomic.StoreUint64(&g.value, math.Float64bits(v))
+	if g.aggregation == GaugeAggregationNotSet || g.aggregation == GaugeAggregationLast {
+		atomic.StoreUint64(&g.value, math.Float64bits(v))
+		return
+	}
+	if g.aggregation == GaugeAggregationMean {
+		atomic.AddUint64(&g.count, 1)
+	}
+
+	// The other aggregations depend on the current value: retry until no other sample changed it concurrently.
+	for {
+		old := atomic.LoadUint64(&g.value)
+		current := math.Float64frombits(old)
+		next := current + v
+		switch g.aggregation {
+		case GaugeAggregationMax:
+			if v <= current {
+				return
+			}
+			next = v
+		case GaugeAggregationMin:
+			if v >= current {
+				return
+			}
+			next = v
+		}
+		if atomic.CompareAndSwapUint64(&g.value, old, math.Float64bits(next)) {
+			return
+		}
+	}
 }
 
 func (g *gaugeMetric) flushUnsafe() metric {
+	value := math.Float64frombits(g.value)
+	if g.aggregation == GaugeAggregationMean {
+		value /= float64(g.count)
+	}
 	return metric{
 		metricType:  gauge,
 		name:        g.name,
 		tags:        g.tags,
 		rate:        1,
-		fvalue:      math.Float64frombits(g.value),
+		fvalue:      value,
 		cardinality: g.cardinality,
 	}
 }
@@ -81,10 +117,14 @@ func (g *gaugeMetric) flushUnsafe() metric {
 // Set
 
 type setMetric struct {
-	data        map[string]struct{}
-	name        string
-	tags        []string
-	cardinality Cardinality
+	data map[string]struct{}
+	// hll replaces data once the set has more than estimationThreshold unique values, see
+	// WithSetCardinalityEstimation.
+	hll                 *hyperLogLog
+	estimationThreshold int
+	name                string
+	tags                []string
+	cardinality         Cardinality
 	sync.Mutex
 }
 
@@ -102,12 +142,34 @@ func newSetMetric(name string, value string, tags []string, cardinality Cardinal
 func (s *setMetric) sample(v string) {
 	s.Lock()
 	defer s.Unlock()
+	if s.hll != nil {
+		s.hll.add(v)
+		return
+	}
 	s.data[v] = struct{}{}
+	if s.estimationThreshold > 0 && len(s.data) > s.estimationThreshold {
+		s.hll = &hyperLogLog{}
+		for value := range s.data {
+			s.hll.add(value)
+		}
+		s.data = nil
+	}
 }
 
 // Sets are aggregated on the agent side too. We flush the keys so a set from
 // multiple application can be correctly aggregated on the agent side.
+// Estimated sets are flushed as a gauge of their estimated number of unique values instead.
 func (s *setMetric) flushUnsafe() []metric {
+	if s.hll != nil {
+		return []metric{{
+			metricType:  gauge,
+			name:        s.name,
+			tags:        s.tags,
+			rate:        1,
+			fvalue:      math.Round(s.hll.estimate()),
+			cardinality: s.cardinality,
+		}}
+	}
 	if len(s.data) == 0 {
 		return nil
 	}
@@ -150,11 +212,22 @@ type bufferedMetric struct {
 	// maxSamples is the maximum number of samples we keep in memory
 	maxSamples int64
 
-	// The first observed user-specified sample rate. When specified
-	// it is used because we don't know better.
+	// The first observed user-specified sample rate, shared by all the kept
+	// samples as long as rates is nil.
 	specifiedRate float64
+	// rates holds the user-specified sample rate of each kept sample. It is
+	// only allocated once a sample is kept with a rate other than specifiedRate.
+	rates []float64
 
 	cardinality Cardinality
+
+	// summary is set when the metric is summarized on the client, see WithHistogramSummary. stats are then updated
+	// with every observed sample.
+	summary *histogramSummary
+	stats   *summaryStats
+
+	// sketch replaces data when the metric is accumulated in a sketch, see WithDistributionSketch.
+	sketch *ddSketch
 }
 
 func (s *bufferedMetric) sample(v float64) {
@@ -170,9 +243,17 @@ func (s *bufferedMetric) sampleUnsafe(v float64) {
 	atomic.AddInt64(&s.totalSamples, 1)
 }
 
-func (s *bufferedMetric) maybeKeepSample(v float64, rand *rand.Rand, randLock *sync.Mutex) {
+func (s *bufferedMetric) maybeKeepSample(v float64, rate float64, rand *rand.Rand, randLock *sync.Mutex) {
 	s.Lock()
 	defer s.Unlock()
+	if s.stats != nil {
+		s.stats.add(v, sampleWeight(rate))
+	}
+	if s.sketch != nil {
+		s.sketch.add(v, sampleWeight(rate))
+		atomic.AddInt64(&s.totalSamples, 1)
+		return
+	}
 	if s.maxSamples > 0 {
 		if s.storedSamples >= s.maxSamples {
 			// We reached the maximum number of samples we can keep in memory, so we randomly
@@ -182,42 +263,84 @@ func (s *bufferedMetric) maybeKeepSample(v float64, rand *rand.Rand, randLock *s
 			randLock.Unlock()
 			if i < s.maxSamples {
 				s.data[i] = v
+				s.setRateUnsafe(i, rate)
 			}
 		} else {
 			s.data[s.storedSamples] = v
+			s.setRateUnsafe(s.storedSamples, rate)
 			s.storedSamples++
 		}
 		s.totalSamples++
 	} else {
 		// This code path appends to the slice since we did not pre-allocate memory in this case.
 		s.sampleUnsafe(v)
+		s.setRateUnsafe(s.storedSamples-1, rate)
+	}
+}
+
+// setRateUnsafe records the user-specified rate of the sample kept at index i.
+func (s *bufferedMetric) setRateUnsafe(i int64, rate float64) {
+	if s.rates == nil {
+		if rate == s.specifiedRate {
+			return
+		}
+		s.rates = make([]float64, len(s.data))
+		for j := range s.rates {
+			s.rates[j] = s.specifiedRate
+		}
+	}
+	if i < int64(len(s.rates)) {
+		s.rates[i] = rate
+	} else {
+		s.rates = append(s.rates, rate)
 	}
 }
 
+// flushUnsafe appends the kept samples to metrics. Since a message has a single rate, samples kept with different
+// user-specified rates are sent in one message per rate.
+func (s *bufferedMetric) flushUnsafe(metrics []metric) []metric {
+	// The kept samples are a uniform selection of the samples that passed the user-specified sampling.
+	keptRatio := float64(s.storedSamples) / float64(atomic.LoadInt64(&s.totalSamples))
+	data := s.data[:s.storedSamples]
 
-func (s *bufferedMetric) flushUnsafe() metric {
-	totalSamples := atomic.LoadInt64(&s.totalSamples)
-	var rate float64
+	if s.rates == nil {
+		return append(metrics, s.newFlushedMetric(data, math.Min(s.specifiedRate, 1)*keptRatio))
+	}
 
-	// If the user had a specified rate send it because we don't know better.
-	// This code should be removed once we can also remove the early return at the top of
-	// `bufferedMetricContexts.sample`
-	if s.specifiedRate != 1.0 {
-		rate = s.specifiedRate
-	} else {
-		rate = float64(s.storedSamples) / float64(totalSamples)
+	byRate := map[float64][]float64{}
+	rates := []float64{}
+	for i, v := range data {
+		rate := s.rates[i]
+		if _, ok := byRate[rate]; !ok {
+			rates = append(rates, rate)
+		}
+		byRate[rate] = append(byRate[rate], v)
+	}
+	for _, rate := range rates {
+		metrics = append(metrics, s.newFlushedMetric(byRate[rate], math.Min(rate, 1)*keptRatio))
 	}
+	return metrics
+}
 
+func (s *bufferedMetric) newFlushedMetric(values []float64, rate float64) metric {
 	return metric{
 		metricType:  s.mtype,
 		name:        s.name,
 		stags:       s.tags,
 		rate:        rate,
-		fvalues:     s.data[:s.storedSamples],
+		fvalues:     values,
 		cardinality: s.cardinality,
 	}
 }
 
+// sampleWeight returns the number of samples a sample kept with the given user-specified rate stands for.
+func sampleWeight(rate float64) float64 {
+	if rate <= 0 || rate >= 1 {
+		return 1
+	}
+	return 1 / rate
+}
+
 type histogramMetric = bufferedMetric
 
 func newHistogramMetric(name string, value float64, stringTags string, maxSamples int64, rate float64, cardinality Cardinality) *histogramMetric {
@@ -2,8 +2,10 @@ package statsd
 
 import (
 	"math"
+	"math/rand"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -167,7 +169,9 @@ func TestHistogramMetricSample(t *testing.T) {
 
 func TestFlushUnsafeHistogramMetricSample(t *testing.T) {
 	s := newHistogramMetric("test", 1.0, "tag1,tag2", 0, 1.0, CardinalityLow)
-	m := s.flushUnsafe()
+	flushed := s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m := flushed[0]
 
 	assert.Equal(t, m.metricType, histogramAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0})
@@ -178,7 +182,9 @@ func TestFlushUnsafeHistogramMetricSample(t *testing.T) {
 
 	s.sample(21)
 	s.sample(123.45)
-	m = s.flushUnsafe()
+	flushed = s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m = flushed[0]
 
 	assert.Equal(t, m.metricType, histogramAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0, 21.0, 123.45})
@@ -209,7 +215,9 @@ func TestDistributionMetricSample(t *testing.T) {
 
 func TestFlushUnsafeDistributionMetricSample(t *testing.T) {
 	s := newDistributionMetric("test", 1.0, "tag1,tag2", 0, 1.0, CardinalityLow)
-	m := s.flushUnsafe()
+	flushed := s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m := flushed[0]
 
 	assert.Equal(t, m.metricType, distributionAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0})
@@ -220,7 +228,9 @@ func TestFlushUnsafeDistributionMetricSample(t *testing.T) {
 
 	s.sample(21)
 	s.sample(123.45)
-	m = s.flushUnsafe()
+	flushed = s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m = flushed[0]
 
 	assert.Equal(t, m.metricType, distributionAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0, 21.0, 123.45})
@@ -251,7 +261,9 @@ func TestTimingMetricSample(t *testing.T) {
 
 func TestFlushUnsafeTimingMetricSample(t *testing.T) {
 	s := newTimingMetric("test", 1.0, "tag1,tag2", 0, 1.0, CardinalityLow)
-	m := s.flushUnsafe()
+	flushed := s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m := flushed[0]
 
 	assert.Equal(t, m.metricType, timingAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0})
@@ -262,7 +274,9 @@ func TestFlushUnsafeTimingMetricSample(t *testing.T) {
 
 	s.sample(21)
 	s.sample(123.45)
-	m = s.flushUnsafe()
+	flushed = s.flushUnsafe(nil)
+	require.Len(t, flushed, 1)
+	m = flushed[0]
 
 	assert.Equal(t, m.metricType, timingAggregated)
 	assert.Equal(t, m.fvalues, []float64{1.0, 21.0, 123.45})
@@ -271,3 +285,49 @@ func TestFlushUnsafeTimingMetricSample(t *testing.T) {
 	assert.Nil(t, m.tags)
 	assert.Equal(t, m.cardinality, CardinalityLow)
 }
+
+func TestFlushUnsafeBufferedMetricMixedRates(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLock := &sync.Mutex{}
+
+	s := newHistogramMetric("test", 1.0, "tag1,tag2", 0, 1.0, CardinalityLow)
+	s.maybeKeepSample(2, 0.5, random, randomLock)
+	s.maybeKeepSample(3, 1, random, randomLock)
+	s.maybeKeepSample(4, 0.5, random, randomLock)
+	m := s.flushUnsafe(nil)
+
+	require.Len(t, m, 2)
+	assert.Equal(t, []float64{1, 3}, m[0].fvalues)
+	assert.Equal(t, 1.0, m[0].rate)
+	assert.Equal(t, []float64{2, 4}, m[1].fvalues)
+	assert.Equal(t, 0.5, m[1].rate)
+}
+
+func TestFlushUnsafeBufferedMetricRateWithMaxSamples(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLock := &sync.Mutex{}
+
+	s := newDistributionMetric("test", 1.0, "tag1,tag2", 2, 0.5, CardinalityLow)
+	s.maybeKeepSample(2, 0.5, random, randomLock)
+	s.maybeKeepSample(3, 0.5, random, randomLock)
+	s.maybeKeepSample(4, 0.5, random, randomLock)
+	m := s.flushUnsafe(nil)
+
+	// 2 of the 4 samples are kept, on top of the user-specified rate
+	require.Len(t, m, 1)
+	assert.Len(t, m[0].fvalues, 2)
+	assert.Equal(t, 0.25, m[0].rate)
+
+	s = newDistributionMetric("test", 1.0, "tag1,tag2", 2, 1, CardinalityLow)
+	s.maybeKeepSample(2, 0.5, random, randomLock)
+	s.maybeKeepSample(3, 1, random, randomLock)
+	s.maybeKeepSample(4, 1, random, randomLock)
+	m = s.flushUnsafe(nil)
+
+	total := 0
+	for _, flushed := range m {
+		total += len(flushed.fvalues)
+		assert.Contains(t, []float64{0.5, 0.25}, flushed.rate)
+	}
+	assert.Equal(t, 2, total)
+}
@@ -0,0 +1,34 @@
+package statsd
+
+import "sync"
+
+// monotonicCounts keeps the last total of each context sent with MonotonicCount. A context is kept for the lifetime of
+// the client.
+type monotonicCounts struct {
+	sync.Mutex
+	totals map[string]int64
+}
+
+// delta records total as the last total of the context and returns the increase since the previous total. ok is
+// false the first time a context is seen since there is nothing to compare total to. A total lower than the previous
+// one means the counter was reset: it counts from zero again so the increase is total itself.
+func (m *monotonicCounts) delta(name string, tags []string, cardinality Cardinality, total int64) (delta int64, ok bool) {
+	cardString := cardinality.String()
+	contextBuffer, _ := appendContext(make([]byte, 0, getContextLength(name, tags, cardString)), name, tags, cardString)
+	context := string(contextBuffer)
+
+	m.Lock()
+	defer m.Unlock()
+	if m.totals == nil {
+		m.totals = map[string]int64{}
+	}
+	previous, found := m.totals[context]
+	m.totals[context] = total
+	if !found {
+		return 0, false
+	}
+	if total < previous {
+		return total, true
+	}
+	return total - previous, true
+}
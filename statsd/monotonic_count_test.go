@@ -0,0 +1,83 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonotonicCountsDelta(t *testing.T) {
+	m := monotonicCounts{}
+
+	_, ok := m.delta("total", []string{"tag"}, CardinalityNotSet, 10)
+	assert.False(t, ok)
+
+	for _, step := range []struct{ total, delta int64 }{
+		{15, 5},
+		{15, 0},
+		// the counter was reset
+		{3, 3},
+		{7, 4},
+	} {
+		delta, ok := m.delta("total", []string{"tag"}, CardinalityNotSet, step.total)
+		assert.True(t, ok)
+		assert.Equal(t, step.delta, delta)
+	}
+
+	// each context has its own total
+	_, ok = m.delta("total", []string{"other"}, CardinalityNotSet, 100)
+	assert.False(t, ok)
+	_, ok = m.delta("total", []string{"tag"}, CardinalityHigh, 100)
+	assert.False(t, ok)
+	delta, ok := m.delta("total", []string{"tag"}, CardinalityNotSet, 8)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), delta)
+}
+
+func TestRecordingClientMonotonicCount(t *testing.T) {
+	c, err := NewRecordingClientEx()
+	require.NoError(t, err)
+
+	for _, total := range []int64{10, 15, 15, 3} {
+		require.NoError(t, c.MonotonicCount("gc.count", total, []string{"tag"}))
+		require.NoError(t, c.WithPrefix("scope.").MonotonicCount("gc.count", total*2, nil))
+	}
+
+	var values []float64
+	for _, m := range c.FindMetrics("gc.count", "tag") {
+		values = append(values, m.Values...)
+	}
+	assert.Equal(t, []float64{5, 0, 3}, values)
+	assert.Equal(t, 16.0, c.CountSum("scope.gc.count"))
+}
+
+func TestRecordingClientMonotonicCountAggregation(t *testing.T) {
+	c, err := NewRecordingClientEx(WithClientSideAggregation())
+	require.NoError(t, err)
+
+	for _, total := range []int64{10, 15, 20, 2} {
+		require.NoError(t, c.MonotonicCount("gc.count", total, nil, CardinalityLow))
+	}
+	require.NoError(t, c.Flush())
+
+	metrics := c.FindMetrics("gc.count")
+	require.Len(t, metrics, 1)
+	assert.Equal(t, []float64{12}, metrics[0].Values)
+	assert.Equal(t, "low", metrics[0].Cardinality)
+}
+
+func TestClientMonotonicCount(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8776",
+		nil,
+		WithoutTelemetry(),
+	)
+
+	require.NoError(t, client.MonotonicCount("total", 100, []string{"tag"}))
+	require.NoError(t, client.MonotonicCount("total", 142, []string{"tag"}))
+	require.NoError(t, client.Flush())
+
+	ts.assert(t, client, []string{"total:42|c|#tag" + ts.getContainerID()})
+}
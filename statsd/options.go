@@ -1,7 +1,9 @@
 package statsd
 
 import (
+	"compress/flate"
 	"fmt"
+	"io"
 	"math"
 	"strings"
 	"time"
@@ -29,6 +31,8 @@ var (
 	defaultChannelModeErrorsWhenFull    = false
 	defaultErrorHandler                 = func(error) {}
 	defaultAggregatorShardCount         = 1
+	defaultFailoverThreshold            = 3
+	defaultFailoverProbeInterval        = 30 * time.Second
 )
 
 // Options contains the configuration options for a client.
@@ -41,7 +45,19 @@ type Options struct {
 	bufferFlushInterval          time.Duration
 	workersCount                 int
 	senderQueueSize              int
+	senderBlockingTimeout        time.Duration
+	spoolDir                     string
+	spoolMaxBytes                int64
+	spoolMaxAge                  time.Duration
+	retryPolicy                  *RetryPolicy
+	fanOutAddrs                  []string
+	fanOutWriters                []io.WriteCloser
+	compression                  *compressionConfig
+	agentFeatures                agentFeatures
+	failoverThreshold            int
+	failoverProbeInterval        time.Duration
 	writeTimeout                 time.Duration
+	dnsRefreshInterval           time.Duration
 	connectTimeout               time.Duration
 	telemetry                    bool
 	receiveMode                  receivingMode
@@ -50,6 +66,17 @@ type Options struct {
 	aggregation                  bool
 	extendedAggregation          bool
 	maxBufferedSamplesPerContext int
+	histogramSummary             *histogramSummary
+	distributionSketch           *sketchMapping
+	maxContexts                  int
+	maxContextsPerName           int
+	timestampAggregation         bool
+	timestampMaxAge              time.Duration
+	setEstimationThreshold       int
+	flushObserver                func([]FlushedMetric)
+	alignedAggregationFlush      bool
+	aggregationWindowTimestamp   bool
+	gaugeAggregation             GaugeAggregation
 	aggregatorShardCount         int
 	telemetryAddr                string
 	originDetection              bool
@@ -82,6 +109,9 @@ func resolveOptions(options []Option) (*Options, error) {
 		channelModeErrorsWhenFull:    defaultChannelModeErrorsWhenFull,
 		errorHandler:                 defaultErrorHandler,
 		aggregatorShardCount:         defaultAggregatorShardCount,
+		agentFeatures:                allAgentFeatures,
+		failoverThreshold:            defaultFailoverThreshold,
+		failoverProbeInterval:        defaultFailoverProbeInterval,
 	}
 
 	for _, option := range options {
@@ -91,6 +121,16 @@ func resolveOptions(options []Option) (*Options, error) {
 		}
 	}
 
+	if !o.agentFeatures.has(agentFeatureValuePacking) && (o.histogramSummary != nil || o.distributionSketch != nil) {
+		return nil, fmt.Errorf("WithHistogramSummary and WithDistributionSketch require Agent 7.25.0 or 6.25.0")
+	}
+	if o.compression != nil && o.maxBytesPerPayload > MaxUDPPayloadSize {
+		return nil, fmt.Errorf("WithCompression requires payloads of at most %d bytes, got %d", MaxUDPPayloadSize, o.maxBytesPerPayload)
+	}
+	if o.aggregationWindowTimestamp && (o.aggregationFlushInterval <= 0 || o.aggregationFlushInterval%time.Second != 0) {
+		return nil, fmt.Errorf("WithAggregationWindowTimestamp requires an aggregation interval of a whole number of seconds, got %s", o.aggregationFlushInterval)
+	}
+
 	return o, nil
 }
 
@@ -204,6 +244,109 @@ func WithSenderQueueSize(senderQueueSize int) Option {
 	}
 }
 
+// WithSenderBlockingMode makes the client wait for room in the sender queue, for at most timeout, instead of dropping
+// payloads when the queue is full.
+//
+// By default, payloads are dropped as soon as the sender queue is full (see TotalPayloadsDroppedQueueFull in Telemetry).
+// With this option the workers, and so the calls to the client methods in mutex mode, block until the sender has
+// written enough payloads on the wire or until timeout expires, in which case the payload is dropped. This is made for
+// batch jobs that can afford to slow down but not to lose metrics. The time spent blocked is reported in
+// TotalTimeBlockedQueueFull.
+//
+// The Ctx methods (GaugeCtx, CountCtx, ...) stop waiting as soon as their context is done and return ctx.Err() without
+// sending their metric. The payload they were waiting on is kept, to be sent by the next flush.
+func WithSenderBlockingMode(timeout time.Duration) Option {
+	return func(o *Options) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be a positive duration")
+		}
+		o.senderBlockingTimeout = timeout
+		return nil
+	}
+}
+
+// WithDiskSpool stores the payloads that couldn't be written to the agent in dir and replays them, in order, once the
+// agent is reachable again. This avoids gaps in the metrics when the agent restarts.
+//
+// The spool is bounded by maxBytes: the oldest payloads are evicted to make room for new ones. Payloads older than
+// maxAge are evicted too, a maxAge of 0 disables the eviction by age. Payloads left in dir by a previous run are
+// replayed as well, dir should therefore not be shared between clients.
+//
+// Spooled payloads are replayed before the next payload is written, when the agent is still unreachable the new
+// payload is spooled too. They are also replayed at every buffer flush interval (see WithBufferFlushInterval) so that
+// the spool is emptied once the agent is back even when no new payload is written. This is mostly useful with UDS and
+// named pipes, as writes to UDP sockets rarely fail.
+func WithDiskSpool(dir string, maxBytes int64, maxAge time.Duration) Option {
+	return func(o *Options) error {
+		if dir == "" {
+			return fmt.Errorf("spool directory must not be empty")
+		}
+		if maxBytes <= 0 {
+			return fmt.Errorf("maxBytes must be a positive integer")
+		}
+		if maxAge < 0 {
+			return fmt.Errorf("maxAge must not be negative")
+		}
+		o.spoolDir = dir
+		o.spoolMaxBytes = maxBytes
+		o.spoolMaxAge = maxAge
+		return nil
+	}
+}
+
+// WithRetryPolicy makes the client write again the payloads it failed to write to the agent, with an exponential
+// backoff between attempts, instead of dropping them right away.
+//
+// Failed payloads are kept aside by the sender, which keeps writing new payloads in the meantime: retried payloads may
+// therefore reach the agent after newer ones. At most as many payloads as the sender queue size (see
+// WithSenderQueueSize) wait for a retry, others are dropped, or spooled when WithDiskSpool is used. Retries are counted
+// in TotalPayloadsRetried and TotalPayloadsRecovered.
+//
+// This is mostly useful with UDS and named pipes, as writes to UDP sockets rarely fail.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *Options) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		o.retryPolicy = &policy
+		return nil
+	}
+}
+
+// WithFanOut makes the client write every payload to the given addresses too, on top of the address given to NewEx.
+// Addresses use the same format as NewEx. This is useful to send the same metrics to several agents, for example while
+// migrating between two agent deployments.
+//
+// Each destination is handled independently: when a destination fails, the error is reported to the error handler
+// (see WithErrorHandler) and counted in its telemetry (see Telemetry.Destinations), but the payload is only considered
+// dropped, retried (see WithRetryPolicy) or spooled (see WithDiskSpool) when every destination failed. The payload
+// size defaults to the one of UDP as soon as one of the destinations isn't a UDS or TCP address.
+//
+// WithFanOut can be used several times to add more destinations.
+func WithFanOut(addrs ...string) Option {
+	return func(o *Options) error {
+		for _, addr := range addrs {
+			if addr == "" {
+				return fmt.Errorf("fan-out address must not be empty")
+			}
+		}
+		o.fanOutAddrs = append(o.fanOutAddrs, addrs...)
+		return nil
+	}
+}
+
+// WithFanOutWriter makes the client write every payload to w too, see WithFanOut. The writer is closed when the client
+// is closed.
+func WithFanOutWriter(w io.WriteCloser) Option {
+	return func(o *Options) error {
+		if w == nil {
+			return fmt.Errorf("fan-out writer must not be nil")
+		}
+		o.fanOutWriters = append(o.fanOutWriters, w)
+		return nil
+	}
+}
+
 // WithWriteTimeout sets the timeout for network communication with the Agent, after this interval a payload is
 // dropped. This is only used for UDS and named pipes connection.
 func WithWriteTimeout(writeTimeout time.Duration) Option {
@@ -213,6 +356,19 @@ func WithWriteTimeout(writeTimeout time.Duration) Option {
 	}
 }
 
+// WithDNSRefreshInterval makes the client resolve the hostname of UDP addresses again at the given interval and send
+// the metrics to the new IP when it changed, for example when the agent is reached through a Kubernetes service whose
+// IP can change. By default the address is only resolved when the client is created.
+func WithDNSRefreshInterval(interval time.Duration) Option {
+	return func(o *Options) error {
+		if interval < 0 {
+			return fmt.Errorf("interval must not be negative")
+		}
+		o.dnsRefreshInterval = interval
+		return nil
+	}
+}
+
 // WithConnectTimeout sets the timeout for network connection with the Agent, after this interval the connection
 // attempt is aborted. This is only used for UDS connection. This will also reset the connection if nothing can be
 // written to it for this duration.
@@ -223,6 +379,52 @@ func WithConnectTimeout(connectTimeout time.Duration) Option {
 	}
 }
 
+// WithCompression makes the client compress the payloads written to stream transports ("unixstream://" and "tcp://"
+// addresses) with the given algorithm and level. level is one of the compress/flate levels, from
+// flate.HuffmanOnly to flate.BestCompression, flate.DefaultCompression being a good default.
+//
+// Compressed payloads start with a header telling them from raw ones: the agent can't read them, they must be sent to
+// a relay decompressing them with ReadPayload or DecodePayload, which reject payloads larger than MaxUDPPayloadSize
+// once decompressed: creating the client fails when WithMaxBytesPerPayload is set above it. Raw and compressed sizes
+// are reported in TotalBytesUncompressed and TotalBytesCompressed, TotalBytesSent keeps counting raw bytes.
+func WithCompression(algorithm Compression, level int) Option {
+	return func(o *Options) error {
+		if algorithm != CompressionFlate && algorithm != CompressionGzip {
+			return fmt.Errorf("unknown compression %d", algorithm)
+		}
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level %d", level)
+		}
+		o.compression = &compressionConfig{
+			algorithm: algorithm,
+			level:     level,
+			telemetry: &compressionTelemetry{},
+		}
+		return nil
+	}
+}
+
+// WithTransportFailover configures how the client switches between the addresses of an address list, for example
+// "unix:///var/run/datadog/dsd.socket,udp://localhost:8125" (see NewEx).
+//
+// The client writes to the first address of the list and switches to the next one after the given number of
+// consecutive write failures. Every probeInterval, the client writes a payload to the first address again to switch
+// back to it if it works. The transport in use is returned by GetTransport and is used in the telemetry tags. By
+// default the client switches after 3 failures and probes every 30 seconds.
+func WithTransportFailover(failures int, probeInterval time.Duration) Option {
+	return func(o *Options) error {
+		if failures < 1 {
+			return fmt.Errorf("failures must be a positive integer")
+		}
+		if probeInterval <= 0 {
+			return fmt.Errorf("probeInterval must be a positive duration")
+		}
+		o.failoverThreshold = failures
+		o.failoverProbeInterval = probeInterval
+		return nil
+	}
+}
+
 // WithChannelMode make the client use channels to receive metrics
 //
 // This determines how the client receive metrics from the app (for example when calling the `Gauge()` method).
@@ -310,6 +512,51 @@ func WithAggregationInterval(interval time.Duration) Option {
 	}
 }
 
+// WithAlignedAggregationFlush aligns the aggregation flushes on the multiples of the aggregation interval since the
+// Unix epoch (for example every 2 seconds on even seconds) instead of starting the interval when the client is
+// created. The clients of a fleet then aggregate the same windows, which gives consistent per-interval values.
+// - This will enable client side aggregation for all metrics.
+func WithAlignedAggregationFlush() Option {
+	return func(o *Options) error {
+		o.aggregation = true
+		o.alignedAggregationFlush = true
+		return nil
+	}
+}
+
+// WithAggregationWindowTimestamp sends the start of the aggregation window as the timestamp of the aggregated counts
+// and gauges, so that the agent doesn't spread a window over two of its buckets. Timestamps only have a precision of one
+// second, creating the client fails when the aggregation interval isn't a whole number of seconds.
+// - This will enable client side aggregation for all metrics and align the flushes, see WithAlignedAggregationFlush.
+// - Sets, histograms, distributions and timings don't support timestamps and are sent without them.
+// - Metrics with a timestamp are not aggregated by the agent.
+// - When Flush is called during a window, the rest of the window is sent with the time of that call as timestamp
+// instead of the start of the window so that the agent doesn't receive two points with the same timestamp.
+func WithAggregationWindowTimestamp() Option {
+	return func(o *Options) error {
+		o.aggregation = true
+		o.alignedAggregationFlush = true
+		o.aggregationWindowTimestamp = true
+		return nil
+	}
+}
+
+// WithGaugeAggregation sets how the values of a gauge are aggregated during an aggregation interval, for example
+// GaugeAggregationMax to report the peak of a queue depth rather than its last value. The default is
+// GaugeAggregationLast. It can be overridden for a call by passing a GaugeAggregation parameter to Gauge or NewGauge.
+// - This will enable client side aggregation for all metrics.
+// - GaugeWithTimestamp always keeps the last value.
+func WithGaugeAggregation(aggregation GaugeAggregation) Option {
+	return func(o *Options) error {
+		if !aggregation.isValid() {
+			return fmt.Errorf("invalid gauge aggregation %d", aggregation)
+		}
+		o.aggregation = true
+		o.gaugeAggregation = aggregation
+		return nil
+	}
+}
+
 // WithClientSideAggregation enables client side aggregation for Gauges, Counts and Sets.
 func WithClientSideAggregation() Option {
 	return func(o *Options) error {
@@ -330,7 +577,7 @@ func WithoutClientSideAggregation() Option {
 // WithExtendedClientSideAggregation enables client side aggregation for all types. This feature is only compatible with
 // Agent's version >=6.25.0 && <7.0.0 or Agent's versions >=7.25.0.
 // When enabled, the use of `rate` with distribution is discouraged and `WithMaxSamplesPerContext()` should be used.
-// If `rate` is used with different values of `rate` the resulting rate is not guaranteed to be correct.
+// When a context is sampled with different values of `rate`, its samples are sent in one message per rate.
 func WithExtendedClientSideAggregation() Option {
 	return func(o *Options) error {
 		o.aggregation = true
@@ -353,6 +600,135 @@ func WithMaxSamplesPerContext(maxSamplesPerDistribution int) Option {
 	}
 }
 
+// WithHistogramSummary summarizes histograms and timings on the client instead of sending their samples to the
+// agent. At every aggregation flush each context is sent as gauges named after the metric with the ".min", ".max",
+// ".avg" and percentiles suffixes (".median", ".95percentile", ...) and a count with the ".count" suffix.
+// Percentiles are given in (0, 1] and default to 0.5, 0.95 and 0.99.
+// - The names are the ones used by the agent for the histograms it summarizes and, like for those, the ".count" is a
+// rate per second once received by the agent, so dashboards keep working when switching to client side summaries.
+// - This will enable extended client side aggregation.
+// - The min, max, avg and count are exact, the percentiles are computed from the samples kept with
+// `WithMaxSamplesPerContext()` when it is used.
+// - The summaries of different clients can't be merged, use distributions for metrics reported by several hosts.
+// - Like extended client side aggregation it requires Agent 7.25.0 or 6.25.0, creating the client fails when an older
+// version is set with `WithAgentVersion()`.
+func WithHistogramSummary(percentiles ...float64) Option {
+	return func(o *Options) error {
+		summary, err := newHistogramSummary(percentiles)
+		if err != nil {
+			return err
+		}
+		o.aggregation = true
+		o.extendedAggregation = true
+		o.histogramSummary = summary
+		return nil
+	}
+}
+
+// WithDistributionSketch accumulates the samples of distributions in a DDSketch instead of keeping them. The sketch
+// counts every sample in logarithmic buckets so that the quantiles computed by Datadog, including p99.9, are within
+// relativeAccuracy (for example 0.01 for 1%) of the exact ones no matter the throughput. At every aggregation flush
+// the representative value of each bucket is sent, weighted by its number of samples through the sample rate.
+// - This will enable extended client side aggregation.
+// - `WithMaxSamplesPerContext()` doesn't apply to distributions when this is enabled.
+// - Like extended client side aggregation it requires Agent 7.25.0 or 6.25.0, creating the client fails when an older
+// version is set with `WithAgentVersion()`.
+func WithDistributionSketch(relativeAccuracy float64) Option {
+	return func(o *Options) error {
+		mapping, err := newSketchMapping(relativeAccuracy)
+		if err != nil {
+			return err
+		}
+		o.aggregation = true
+		o.extendedAggregation = true
+		o.distributionSketch = mapping
+		return nil
+	}
+}
+
+// WithMaxContexts limits the number of contexts aggregated by the client during an aggregation interval, for example
+// to protect the memory of the application when a tag has an unbounded number of values. Once the limit is reached the
+// samples of new contexts are aggregated in a single context per metric name, tagged "overflow:true" instead of their
+// own tags. The error handler is called with an ErrorContextsOverflow the first time it happens for a metric name
+// during an interval.
+// - This will enable client side aggregation for all metrics.
+func WithMaxContexts(maxContexts int) Option {
+	return func(o *Options) error {
+		if maxContexts < 1 {
+			return fmt.Errorf("maxContexts must be positive")
+		}
+		o.aggregation = true
+		o.maxContexts = maxContexts
+		return nil
+	}
+}
+
+// WithMaxContextsPerName limits the number of contexts aggregated by the client for each metric name during an
+// aggregation interval. Once the limit is reached for a metric, the samples of its new contexts are aggregated in the
+// overflow context described in WithMaxContexts.
+// - This will enable client side aggregation for all metrics.
+func WithMaxContextsPerName(maxContexts int) Option {
+	return func(o *Options) error {
+		if maxContexts < 1 {
+			return fmt.Errorf("maxContexts must be positive")
+		}
+		o.aggregation = true
+		o.maxContextsPerName = maxContexts
+		return nil
+	}
+}
+
+// WithTimestampAggregation aggregates the metrics sent with GaugeWithTimestamp and CountWithTimestamp by context and
+// second: at every aggregation flush one point is sent for each second, with the sum of the counts and the last value
+// of the gauges. This is useful for backfilling jobs sending many points in the past.
+// Points older than maxAge or more than 10 minutes in the future are rejected with TimestampOutOfRange since Datadog
+// would drop them. Datadog accepts points up to one hour in the past unless historical metrics ingestion is enabled,
+// a maxAge of 0 disables the check.
+// - This will enable client side aggregation for all metrics.
+func WithTimestampAggregation(maxAge time.Duration) Option {
+	return func(o *Options) error {
+		if maxAge < 0 {
+			return fmt.Errorf("maxAge must not be negative")
+		}
+		o.aggregation = true
+		o.timestampAggregation = true
+		o.timestampMaxAge = maxAge
+		return nil
+	}
+}
+
+// WithSetCardinalityEstimation bounds the memory used by sets with many unique values, such as sets of user IDs. Once
+// a set context has more than threshold unique values during an aggregation interval they are counted in a
+// HyperLogLog sketch of 16 KiB instead of being kept, and the context is sent as a gauge of the estimated number of
+// unique values (with a standard error of about 0.8%) instead of the values themselves.
+// - This will enable client side aggregation for all metrics.
+// - The estimated gauges of different clients can't be merged like the values of sets: use it for sets reported by a
+// single client per context.
+func WithSetCardinalityEstimation(threshold int) Option {
+	return func(o *Options) error {
+		if threshold < 1 {
+			return fmt.Errorf("threshold must be positive")
+		}
+		o.aggregation = true
+		o.setEstimationThreshold = threshold
+		return nil
+	}
+}
+
+// WithFlushObserver calls observer with the metrics sent by every aggregation flush, for example to mirror the
+// aggregated data into logs or a debug endpoint. The observer is called synchronously from the flush once the metrics
+// have been handed to the sender: it should return quickly and must not flush or close the client.
+// - This will enable client side aggregation for all metrics.
+// - Only aggregated metrics are observed: with basic aggregation histograms, distributions and timings are sent
+// directly and never observed.
+func WithFlushObserver(observer func([]FlushedMetric)) Option {
+	return func(o *Options) error {
+		o.aggregation = true
+		o.flushObserver = observer
+		return nil
+	}
+}
+
 // WithoutTelemetry disables the client telemetry.
 //
 // More on this here: https://docs.datadoghq.com/developers/dogstatsd/high_throughput/#client-side-telemetry
@@ -417,6 +793,29 @@ func WithContainerID(id string) Option {
 	}
 }
 
+// WithAgentVersion sets the version of the Datadog Agent receiving the metrics, for example "7.38.0". The client then
+// omits the fields this version can't parse instead of sending messages the agent would reject:
+//   - timestamps (see GaugeWithTimestamp and CountWithTimestamp) require Agent 7.40.0 or 6.40.0, they are omitted
+//     for older versions and the agent uses the time it received the metric.
+//   - container IDs (see WithContainerID and WithOriginDetection) require Agent 7.35.0 or 6.35.0.
+//   - the external environment used by origin detection requires Agent 7.57.0.
+//   - tag cardinality (see WithCardinality) requires Agent 7.64.0.
+//   - extended client side aggregation (see WithExtendedClientSideAggregation) requires Agent 7.25.0 or 6.25.0, the
+//     client falls back to the default client side aggregation for older versions. WithHistogramSummary and
+//     WithDistributionSketch can't fall back, creating the client fails instead.
+//
+// By default the client assumes the agent supports every field.
+func WithAgentVersion(version string) Option {
+	return func(o *Options) error {
+		v, err := parseAgentVersion(version)
+		if err != nil {
+			return err
+		}
+		o.agentFeatures = v.features()
+		return nil
+	}
+}
+
 // WithCardinality sets the tag cardinality of the metric.
 func WithCardinality(card Cardinality) Option {
 	return func(o *Options) error {
@@ -19,6 +19,7 @@ func TestDefaultOptions(t *testing.T) {
 	assert.Equal(t, options.bufferFlushInterval, defaultBufferFlushInterval)
 	assert.Equal(t, options.workersCount, defaultWorkerCount)
 	assert.Equal(t, options.senderQueueSize, defaultSenderQueueSize)
+	assert.Zero(t, options.senderBlockingTimeout)
 	assert.Equal(t, options.writeTimeout, defaultWriteTimeout)
 	assert.Equal(t, options.telemetry, defaultTelemetry)
 	assert.Equal(t, options.receiveMode, defaultReceivingMode)
@@ -40,6 +41,7 @@ func TestOptions(t *testing.T) {
 	testBufferFlushInterval := 48 * time.Second
 	testBufferShardCount := 28
 	testSenderQueueSize := 64
+	testSenderBlockingTimeout := 5 * time.Second
 	testWriteTimeout := 1 * time.Minute
 	testChannelBufferSize := 500
 	testAggregationWindow := 10 * time.Second
@@ -56,6 +58,7 @@ func TestOptions(t *testing.T) {
 		WithBufferFlushInterval(testBufferFlushInterval),
 		WithWorkersCount(testBufferShardCount),
 		WithSenderQueueSize(testSenderQueueSize),
+		WithSenderBlockingMode(testSenderBlockingTimeout),
 		WithWriteTimeout(testWriteTimeout),
 		WithoutTelemetry(),
 		WithChannelMode(),
@@ -76,6 +79,7 @@ func TestOptions(t *testing.T) {
 	assert.Equal(t, options.bufferFlushInterval, testBufferFlushInterval)
 	assert.Equal(t, options.workersCount, testBufferShardCount)
 	assert.Equal(t, options.senderQueueSize, testSenderQueueSize)
+	assert.Equal(t, options.senderBlockingTimeout, testSenderBlockingTimeout)
 	assert.Equal(t, options.writeTimeout, testWriteTimeout)
 	assert.Equal(t, options.telemetry, false)
 	assert.Equal(t, options.receiveMode, channelMode)
@@ -129,3 +133,29 @@ func TestOptionsInvalidTagCardinality(t *testing.T) {
 
 	assert.EqualError(t, err, "invalid cardinality 5")
 }
+
+func TestOptionsInvalidSenderBlockingMode(t *testing.T) {
+	_, err := resolveOptions([]Option{
+		WithSenderBlockingMode(0),
+	})
+
+	assert.EqualError(t, err, "timeout must be a positive duration")
+}
+
+func TestOptionsDiskSpool(t *testing.T) {
+	options, err := resolveOptions([]Option{
+		WithDiskSpool("/tmp/spool", 1024, time.Hour),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/spool", options.spoolDir)
+	assert.Equal(t, int64(1024), options.spoolMaxBytes)
+	assert.Equal(t, time.Hour, options.spoolMaxAge)
+
+	_, err = resolveOptions([]Option{WithDiskSpool("", 1024, 0)})
+	assert.EqualError(t, err, "spool directory must not be empty")
+	_, err = resolveOptions([]Option{WithDiskSpool("/tmp/spool", 0, 0)})
+	assert.EqualError(t, err, "maxBytes must be a positive integer")
+	_, err = resolveOptions([]Option{WithDiskSpool("/tmp/spool", 1024, -time.Second)})
+	assert.EqualError(t, err, "maxAge must not be negative")
+}
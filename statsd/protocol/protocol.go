@@ -0,0 +1,362 @@
+/*
+Package protocol decodes the DogStatsD wire format produced by the statsd package.
+
+A DogStatsD payload is made of lines separated by '\n'. Each line is either a metric, an event (starting with "_e{")
+or a service check (starting with "_sc|"). Parse decodes any of them:
+
+	for _, line := range strings.Split(string(payload), "\n") {
+		if line == "" {
+			continue
+		}
+		msg, err := protocol.Parse(line)
+		if err != nil {
+			continue
+		}
+		switch m := msg.(type) {
+		case protocol.Metric:
+			fmt.Println(m.Name, m.Values)
+		case protocol.Event:
+			fmt.Println(m.Title)
+		case protocol.ServiceCheck:
+			fmt.Println(m.Name, m.Status)
+		}
+	}
+
+Every field the statsd package can write is decoded: sample rates, tags, container IDs ('|c:'), external environment
+('|e:'), tag cardinality ('|card:'), timestamps ('|T'), aggregated samples packed as multiple values ("name:1:2:3|d")
+and escaped event and service check texts.
+*/
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricType is the type of a metric as written on the wire.
+type MetricType string
+
+const (
+	// Gauge is the "g" metric type.
+	Gauge MetricType = "g"
+	// Count is the "c" metric type.
+	Count MetricType = "c"
+	// Histogram is the "h" metric type.
+	Histogram MetricType = "h"
+	// Distribution is the "d" metric type.
+	Distribution MetricType = "d"
+	// Set is the "s" metric type.
+	Set MetricType = "s"
+	// Timing is the "ms" metric type.
+	Timing MetricType = "ms"
+)
+
+// Metric is a decoded DogStatsD metric line.
+type Metric struct {
+	// Name of the metric, including the client namespace if any.
+	Name string
+	// Type of the metric.
+	Type MetricType
+	// Values holds the numeric values of the line. Aggregated histograms, distributions and timings can hold more
+	// than one value. Values is empty for sets.
+	Values []float64
+	// StringValue is the raw value of a set.
+	StringValue string
+	// Rate is the sample rate of the line, 1 when none was sent.
+	Rate float64
+	// Tags of the metric, including the client global tags.
+	Tags []string
+	// ContainerID is the value of the '|c:' field.
+	ContainerID string
+	// ExternalEnv is the value of the '|e:' field.
+	ExternalEnv string
+	// Cardinality is the value of the '|card:' field.
+	Cardinality string
+	// Timestamp is the value of the '|T' field, zero when none was sent.
+	Timestamp time.Time
+	// Raw is the line as it was received.
+	Raw string
+}
+
+// HasTags returns true if the metric has all the given tags.
+func (m Metric) HasTags(tags ...string) bool {
+	return hasTags(m.Tags, tags)
+}
+
+// Event is a decoded DogStatsD event.
+type Event struct {
+	Title string
+	// Text of the event, with newlines unescaped.
+	Text string
+	// Timestamp is the value of the '|d:' field, zero when none was sent.
+	Timestamp      time.Time
+	Hostname       string
+	AggregationKey string
+	Priority       string
+	SourceTypeName string
+	AlertType      string
+	Tags           []string
+	ContainerID    string
+	ExternalEnv    string
+	Cardinality    string
+	// Raw is the line as it was received.
+	Raw string
+}
+
+// HasTags returns true if the event has all the given tags.
+func (e Event) HasTags(tags ...string) bool {
+	return hasTags(e.Tags, tags)
+}
+
+// ServiceCheck is a decoded DogStatsD service check.
+type ServiceCheck struct {
+	Name string
+	// Status is the numeric status: 0 for OK, 1 for warning, 2 for critical and 3 for unknown.
+	Status int
+	// Timestamp is the value of the '|d:' field, zero when none was sent.
+	Timestamp time.Time
+	Hostname  string
+	// Message of the service check, with newlines and "m:" unescaped.
+	Message     string
+	Tags        []string
+	ContainerID string
+	ExternalEnv string
+	Cardinality string
+	// Raw is the line as it was received.
+	Raw string
+}
+
+func hasTags(tags []string, expected []string) bool {
+	for _, e := range expected {
+		found := false
+		for _, t := range tags {
+			if t == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HasTags returns true if the service check has all the given tags.
+func (sc ServiceCheck) HasTags(tags ...string) bool {
+	return hasTags(sc.Tags, tags)
+}
+
+// IsEvent returns true if the line is an event.
+func IsEvent(line string) bool {
+	return strings.HasPrefix(line, "_e{")
+}
+
+// IsServiceCheck returns true if the line is a service check.
+func IsServiceCheck(line string) bool {
+	return strings.HasPrefix(line, "_sc|")
+}
+
+// Parse decodes a single line, without its trailing '\n'. It returns a Metric, an Event or a ServiceCheck depending on
+// the line.
+func Parse(line string) (interface{}, error) {
+	switch {
+	case IsEvent(line):
+		return ParseEvent(line)
+	case IsServiceCheck(line):
+		return ParseServiceCheck(line)
+	default:
+		return ParseMetric(line)
+	}
+}
+
+func parseTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func parseUnixTimestamp(s string) (time.Time, error) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// ParseMetric decodes a metric line, without its trailing '\n'.
+func ParseMetric(line string) (Metric, error) {
+	m := Metric{Rate: 1, Raw: line}
+
+	sep := strings.IndexByte(line, ':')
+	if sep <= 0 {
+		return m, fmt.Errorf("invalid metric %q: missing name", line)
+	}
+	m.Name = line[:sep]
+
+	fields := strings.Split(line[sep+1:], "|")
+	if len(fields) < 2 {
+		return m, fmt.Errorf("invalid metric %q: missing type", line)
+	}
+	m.Type = MetricType(fields[1])
+	switch m.Type {
+	case Gauge, Count, Histogram, Distribution, Timing:
+		for _, v := range strings.Split(fields[0], ":") {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return m, fmt.Errorf("invalid metric %q: invalid value %q", line, v)
+			}
+			m.Values = append(m.Values, f)
+		}
+	case Set:
+		m.StringValue = fields[0]
+	default:
+		return m, fmt.Errorf("invalid metric %q: unknown type %q", line, fields[1])
+	}
+
+	for _, field := range fields[2:] {
+		var err error
+		switch {
+		case strings.HasPrefix(field, "@"):
+			m.Rate, err = strconv.ParseFloat(field[1:], 64)
+		case strings.HasPrefix(field, "#"):
+			m.Tags = parseTags(field[1:])
+		case strings.HasPrefix(field, "card:"):
+			m.Cardinality = field[len("card:"):]
+		case strings.HasPrefix(field, "c:"):
+			m.ContainerID = field[len("c:"):]
+		case strings.HasPrefix(field, "e:"):
+			m.ExternalEnv = field[len("e:"):]
+		case strings.HasPrefix(field, "T"):
+			m.Timestamp, err = parseUnixTimestamp(field[1:])
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return m, fmt.Errorf("invalid metric %q: invalid field %q: %v", line, field, err)
+		}
+	}
+	return m, nil
+}
+
+// ParseEvent decodes an event line, without its trailing '\n'.
+func ParseEvent(line string) (Event, error) {
+	e := Event{Raw: line}
+
+	end := strings.Index(line, "}:")
+	if !IsEvent(line) || end < 0 {
+		return e, fmt.Errorf("invalid event %q: invalid header", line)
+	}
+	lengths := strings.Split(line[len("_e{"):end], ",")
+	if len(lengths) != 2 {
+		return e, fmt.Errorf("invalid event %q: invalid header", line)
+	}
+	titleLen, err := strconv.Atoi(lengths[0])
+	if err != nil {
+		return e, fmt.Errorf("invalid event %q: invalid title length", line)
+	}
+	textLen, err := strconv.Atoi(lengths[1])
+	if err != nil {
+		return e, fmt.Errorf("invalid event %q: invalid text length", line)
+	}
+
+	body := line[end+len("}:"):]
+	if len(body) < titleLen+1+textLen || body[titleLen] != '|' {
+		return e, fmt.Errorf("invalid event %q: title or text does not match the header", line)
+	}
+	e.Title = body[:titleLen]
+	e.Text = strings.Replace(body[titleLen+1:titleLen+1+textLen], "\\n", "\n", -1)
+
+	rest := body[titleLen+1+textLen:]
+	if rest == "" {
+		return e, nil
+	}
+	if rest[0] != '|' {
+		return e, fmt.Errorf("invalid event %q: text does not match the header", line)
+	}
+
+	for _, field := range strings.Split(rest[1:], "|") {
+		var err error
+		switch {
+		case strings.HasPrefix(field, "d:"):
+			e.Timestamp, err = parseUnixTimestamp(field[len("d:"):])
+		case strings.HasPrefix(field, "h:"):
+			e.Hostname = field[len("h:"):]
+		case strings.HasPrefix(field, "k:"):
+			e.AggregationKey = field[len("k:"):]
+		case strings.HasPrefix(field, "p:"):
+			e.Priority = field[len("p:"):]
+		case strings.HasPrefix(field, "s:"):
+			e.SourceTypeName = field[len("s:"):]
+		case strings.HasPrefix(field, "t:"):
+			e.AlertType = field[len("t:"):]
+		case strings.HasPrefix(field, "#"):
+			e.Tags = parseTags(field[1:])
+		case strings.HasPrefix(field, "card:"):
+			e.Cardinality = field[len("card:"):]
+		case strings.HasPrefix(field, "c:"):
+			e.ContainerID = field[len("c:"):]
+		case strings.HasPrefix(field, "e:"):
+			e.ExternalEnv = field[len("e:"):]
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return e, fmt.Errorf("invalid event %q: invalid field %q: %v", line, field, err)
+		}
+	}
+	return e, nil
+}
+
+// ParseServiceCheck decodes a service check line, without its trailing '\n'.
+func ParseServiceCheck(line string) (ServiceCheck, error) {
+	sc := ServiceCheck{Raw: line}
+
+	if !IsServiceCheck(line) {
+		return sc, fmt.Errorf("invalid service check %q: invalid header", line)
+	}
+	fields := strings.Split(line[len("_sc|"):], "|")
+	if len(fields) < 2 {
+		return sc, fmt.Errorf("invalid service check %q: missing name or status", line)
+	}
+	sc.Name = fields[0]
+	status, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return sc, fmt.Errorf("invalid service check %q: invalid status %q", line, fields[1])
+	}
+	sc.Status = status
+
+	for _, field := range fields[2:] {
+		var err error
+		switch {
+		case strings.HasPrefix(field, "d:"):
+			sc.Timestamp, err = parseUnixTimestamp(field[len("d:"):])
+		case strings.HasPrefix(field, "h:"):
+			sc.Hostname = field[len("h:"):]
+		case strings.HasPrefix(field, "m:"):
+			sc.Message = unescapeServiceCheckMessage(field[len("m:"):])
+		case strings.HasPrefix(field, "#"):
+			sc.Tags = parseTags(field[1:])
+		case strings.HasPrefix(field, "card:"):
+			sc.Cardinality = field[len("card:"):]
+		case strings.HasPrefix(field, "c:"):
+			sc.ContainerID = field[len("c:"):]
+		case strings.HasPrefix(field, "e:"):
+			sc.ExternalEnv = field[len("e:"):]
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return sc, fmt.Errorf("invalid service check %q: invalid field %q: %v", line, field, err)
+		}
+	}
+	return sc, nil
+}
+
+func unescapeServiceCheckMessage(msg string) string {
+	msg = strings.Replace(msg, "\\n", "\n", -1)
+	return strings.Replace(msg, "m\\:", "m:", -1)
+}
@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetric(t *testing.T) {
+	m, err := ParseMetric("ns.name:1.5|g|@0.5|#tag1,tag2:value|c:container|e:env|T1700000000|card:low")
+	require.NoError(t, err)
+
+	assert.Equal(t, Metric{
+		Name:        "ns.name",
+		Type:        Gauge,
+		Values:      []float64{1.5},
+		Rate:        0.5,
+		Tags:        []string{"tag1", "tag2:value"},
+		ContainerID: "container",
+		ExternalEnv: "env",
+		Cardinality: "low",
+		Timestamp:   time.Unix(1700000000, 0),
+		Raw:         "ns.name:1.5|g|@0.5|#tag1,tag2:value|c:container|e:env|T1700000000|card:low",
+	}, m)
+}
+
+func TestParseMetricDefaults(t *testing.T) {
+	m, err := ParseMetric("name:21|c")
+	require.NoError(t, err)
+
+	assert.Equal(t, "name", m.Name)
+	assert.Equal(t, Count, m.Type)
+	assert.Equal(t, []float64{21}, m.Values)
+	assert.Equal(t, 1.0, m.Rate)
+	assert.Nil(t, m.Tags)
+	assert.True(t, m.Timestamp.IsZero())
+}
+
+func TestParseMetricMultipleValues(t *testing.T) {
+	m, err := ParseMetric("name:1:2.5:3|d|#tag")
+	require.NoError(t, err)
+
+	assert.Equal(t, Distribution, m.Type)
+	assert.Equal(t, []float64{1, 2.5, 3}, m.Values)
+	assert.Equal(t, []string{"tag"}, m.Tags)
+}
+
+func TestParseMetricSet(t *testing.T) {
+	m, err := ParseMetric("name:user_1|s")
+	require.NoError(t, err)
+
+	assert.Equal(t, Set, m.Type)
+	assert.Equal(t, "user_1", m.StringValue)
+	assert.Empty(t, m.Values)
+}
+
+func TestParseMetricErrors(t *testing.T) {
+	for _, line := range []string{
+		"name",
+		":1|c",
+		"name:1",
+		"name:a|c",
+		"name:1|x",
+		"name:1|c|@a",
+		"name:1|c|unknown",
+		"name:1|c|Tabc",
+	} {
+		_, err := ParseMetric(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	e, err := ParseEvent("_e{5,11}:title|text\\nline2|d:1700000000|h:host|k:key|p:low|s:source|t:error|#tag1,tag2|c:container|e:env|card:high")
+	require.NoError(t, err)
+
+	assert.Equal(t, "title", e.Title)
+	assert.Equal(t, "text\nline2", e.Text)
+	assert.Equal(t, time.Unix(1700000000, 0), e.Timestamp)
+	assert.Equal(t, "host", e.Hostname)
+	assert.Equal(t, "key", e.AggregationKey)
+	assert.Equal(t, "low", e.Priority)
+	assert.Equal(t, "source", e.SourceTypeName)
+	assert.Equal(t, "error", e.AlertType)
+	assert.Equal(t, []string{"tag1", "tag2"}, e.Tags)
+	assert.Equal(t, "container", e.ContainerID)
+	assert.Equal(t, "env", e.ExternalEnv)
+	assert.Equal(t, "high", e.Cardinality)
+}
+
+func TestParseEventWithPipeInText(t *testing.T) {
+	e, err := ParseEvent("_e{5,6}:title|te|xt!")
+	require.NoError(t, err)
+
+	assert.Equal(t, "title", e.Title)
+	assert.Equal(t, "te|xt!", e.Text)
+}
+
+func TestParseEventErrors(t *testing.T) {
+	for _, line := range []string{
+		"_e{5,5:title|text",
+		"_e{a,4}:title|text",
+		"_e{5,10}:title|text",
+		"_e{5,2}:title|text",
+		"_e{5,4}:title|text|x:unknown",
+	} {
+		_, err := ParseEvent(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParseServiceCheck(t *testing.T) {
+	sc, err := ParseServiceCheck("_sc|name|2|d:1700000000|h:host|#tag1,tag2|m:line1\\nm\\: line2|c:container|e:env|card:none")
+	require.NoError(t, err)
+
+	assert.Equal(t, "name", sc.Name)
+	assert.Equal(t, 2, sc.Status)
+	assert.Equal(t, time.Unix(1700000000, 0), sc.Timestamp)
+	assert.Equal(t, "host", sc.Hostname)
+	assert.Equal(t, []string{"tag1", "tag2"}, sc.Tags)
+	assert.Equal(t, "line1\nm: line2", sc.Message)
+	assert.Equal(t, "container", sc.ContainerID)
+	assert.Equal(t, "env", sc.ExternalEnv)
+	assert.Equal(t, "none", sc.Cardinality)
+}
+
+func TestParseServiceCheckErrors(t *testing.T) {
+	for _, line := range []string{
+		"_sc|name",
+		"_sc|name|ok",
+		"_sc|name|0|x:unknown",
+	} {
+		_, err := ParseServiceCheck(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParse(t *testing.T) {
+	msg, err := Parse("name:1|c")
+	require.NoError(t, err)
+	assert.IsType(t, Metric{}, msg)
+
+	msg, err = Parse("_e{1,1}:a|b")
+	require.NoError(t, err)
+	assert.IsType(t, Event{}, msg)
+
+	msg, err = Parse("_sc|name|0")
+	require.NoError(t, err)
+	assert.IsType(t, ServiceCheck{}, msg)
+
+	_, err = Parse("invalid")
+	assert.Error(t, err)
+}
+
+func TestHasTags(t *testing.T) {
+	m := Metric{Tags: []string{"a", "b:c"}}
+	assert.True(t, m.HasTags())
+	assert.True(t, m.HasTags("b:c", "a"))
+	assert.False(t, m.HasTags("a", "b"))
+
+	assert.True(t, Event{Tags: []string{"a"}}.HasTags("a"))
+	assert.False(t, ServiceCheck{}.HasTags("a"))
+}
@@ -0,0 +1,658 @@
+package statsd
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+// RecordingClientEx is an in-memory statsd client that records everything it receives instead of sending it. It can be
+// useful in testing situations to assert on the metrics, events and service checks sent by the code under test without
+// a network or mocks.
+//
+// The namespace, global tags (including the ones from DD_ENV, DD_SERVICE and DD_VERSION) and tag cardinality options
+// are applied the same way the regular client does. Every call is recorded: sample rates are kept on the records but
+// never used to drop data.
+//
+// Client side aggregation is disabled by default, in which case every call is recorded as its own metric. It can be
+// enabled with WithClientSideAggregation or WithExtendedClientSideAggregation: metrics are then aggregated with the
+// same semantics as the regular client and recorded when Flush or Close is called. The aggregation interval is ignored.
+type RecordingClientEx struct {
+	namespace          string
+	tags               []string
+	defaultCardinality Cardinality
+	telemetry          *statsdTelemetry
+	agg                *aggregator
+	aggExtended        *aggregator
+	monotonicCounts    monotonicCounts
+
+	mu            sync.Mutex
+	metrics       []protocol.Metric
+	events        []protocol.Event
+	serviceChecks []protocol.ServiceCheck
+	closed        bool
+}
+
+// Verify that RecordingClientEx implements the ClientInterfaceEx interface.
+var _ ClientInterfaceEx = &RecordingClientEx{}
+
+// NewRecordingClientEx returns a new RecordingClientEx. Options unrelated to the namespace, tags, cardinality or
+// aggregation are ignored.
+func NewRecordingClientEx(options ...Option) (*RecordingClientEx, error) {
+	o, err := resolveOptions(append([]Option{WithoutClientSideAggregation()}, options...))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RecordingClientEx{
+		namespace:          o.namespace,
+		tags:               appendEnvTags(o.tags),
+		defaultCardinality: resolveDefaultCardinality(o),
+		telemetry:          &statsdTelemetry{},
+	}
+	if o.aggregation || o.extendedAggregation || o.maxBufferedSamplesPerContext > 0 {
+		// The aggregator is never started: aggregated metrics are only recorded when flushing.
+		c.agg = newAggregatorWithOptions(nil, o)
+		if o.extendedAggregation {
+			c.aggExtended = c.agg
+		}
+	}
+	return c, nil
+}
+
+func (c *RecordingClientEx) record(m metric) {
+	m.namespace = c.namespace
+	m.globalTags = c.tags
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch m.metricType {
+	case event:
+		c.events = append(c.events, recordedEvent(m))
+	case serviceCheck:
+		c.serviceChecks = append(c.serviceChecks, recordedServiceCheck(m))
+	default:
+		c.metrics = append(c.metrics, recordedMetric(m))
+	}
+}
+
+func recordedTags(m metric) []string {
+	tags := append([]string{}, m.globalTags...)
+	if m.stags != "" {
+		return append(tags, strings.Split(m.stags, tagSeparatorSymbol)...)
+	}
+	tags = append(tags, m.tags...)
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+func recordedRate(rate float64) float64 {
+	if rate < 1 {
+		return rate
+	}
+	return 1
+}
+
+func recordedMetric(m metric) protocol.Metric {
+	f := toFlushedMetric(m)
+	return protocol.Metric{
+		Name:        f.Name,
+		Type:        f.Type,
+		Values:      f.Values,
+		StringValue: f.StringValue,
+		Rate:        f.Rate,
+		Tags:        f.Tags,
+		Cardinality: f.Cardinality,
+		Timestamp:   f.Timestamp,
+	}
+}
+
+func recordedEvent(m metric) protocol.Event {
+	e := m.evalue
+	return protocol.Event{
+		Title:          e.Title,
+		Text:           e.Text,
+		Timestamp:      e.Timestamp,
+		Hostname:       e.Hostname,
+		AggregationKey: e.AggregationKey,
+		Priority:       string(e.Priority),
+		SourceTypeName: e.SourceTypeName,
+		AlertType:      string(e.AlertType),
+		Tags:           recordedTags(metric{globalTags: m.globalTags, tags: e.Tags}),
+		Cardinality:    m.cardinality.String(),
+	}
+}
+
+func recordedServiceCheck(m metric) protocol.ServiceCheck {
+	sc := m.scvalue
+	return protocol.ServiceCheck{
+		Name:        sc.Name,
+		Status:      int(sc.Status),
+		Timestamp:   sc.Timestamp,
+		Hostname:    sc.Hostname,
+		Message:     sc.Message,
+		Tags:        recordedTags(metric{globalTags: m.globalTags, tags: sc.Tags}),
+		Cardinality: m.cardinality.String(),
+	}
+}
+
+// Gauge records the value of a metric at a particular time.
+func (c *RecordingClientEx) Gauge(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsGauge, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil {
+		return c.agg.gaugeWithAggregation(name, value, tags, cardinality, parameterGaugeAggregation(parameters, c.agg.gaugeAggregation))
+	}
+	c.record(metric{metricType: gauge, name: name, fvalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// GaugeWithTimestamp records the value of a metric at a given time. It is only aggregated with WithTimestampAggregation.
+func (c *RecordingClientEx) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	if timestamp.IsZero() || timestamp.Unix() <= noTimestamp {
+		return InvalidTimestamp
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsGauge, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil && c.agg.timestamped != nil {
+		return c.agg.gaugeWithTimestamp(name, value, tags, timestamp.Unix(), cardinality)
+	}
+	c.record(metric{metricType: gauge, name: name, fvalue: value, tags: tags, rate: rate, timestamp: timestamp.Unix(), cardinality: cardinality})
+	return nil
+}
+
+// Count records how many times something happened per second.
+func (c *RecordingClientEx) Count(name string, value int64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsCount, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil {
+		return c.agg.count(name, value, tags, cardinality)
+	}
+	c.record(metric{metricType: count, name: name, ivalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// CountWithTimestamp records how many times something happened at the given second. It is only aggregated with
+// WithTimestampAggregation.
+func (c *RecordingClientEx) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	if timestamp.IsZero() || timestamp.Unix() <= noTimestamp {
+		return InvalidTimestamp
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsCount, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil && c.agg.timestamped != nil {
+		return c.agg.countWithTimestamp(name, value, tags, timestamp.Unix(), cardinality)
+	}
+	c.record(metric{metricType: count, name: name, ivalue: value, tags: tags, rate: rate, timestamp: timestamp.Unix(), cardinality: cardinality})
+	return nil
+}
+
+// MonotonicCount records the increase of a cumulative total since the previous call for the same context as a Count.
+func (c *RecordingClientEx) MonotonicCount(name string, total int64, tags []string, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	delta, ok := c.monotonicCounts.delta(name, tags, parameterCardinality(parameters, c.defaultCardinality), total)
+	if !ok {
+		return nil
+	}
+	return c.Count(name, delta, tags, 1, parameters...)
+}
+
+// Histogram records the statistical distribution of a set of values on each host.
+func (c *RecordingClientEx) Histogram(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsHistogram, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.aggExtended != nil {
+		return c.aggExtended.histogram(name, value, tags, rate, cardinality)
+	}
+	c.record(metric{metricType: histogram, name: name, fvalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// Distribution records the statistical distribution of a set of values across your infrastructure.
+func (c *RecordingClientEx) Distribution(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsDistribution, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.aggExtended != nil {
+		return c.aggExtended.distribution(name, value, tags, rate, cardinality)
+	}
+	c.record(metric{metricType: distribution, name: name, fvalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// DistributionSamples records several samples of a distribution at once. They are never aggregated.
+func (c *RecordingClientEx) DistributionSamples(name string, values []float64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsDistribution, uint64(len(values)))
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	c.record(metric{metricType: distributionAggregated, name: name, fvalues: values, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// Decr is just Count of -1
+func (c *RecordingClientEx) Decr(name string, tags []string, rate float64, parameters ...Parameter) error {
+	return c.Count(name, -1, tags, rate, parameters...)
+}
+
+// Incr is just Count of 1
+func (c *RecordingClientEx) Incr(name string, tags []string, rate float64, parameters ...Parameter) error {
+	return c.Count(name, 1, tags, rate, parameters...)
+}
+
+// Set records the number of unique elements in a group.
+func (c *RecordingClientEx) Set(name string, value string, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsSet, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil {
+		return c.agg.set(name, value, tags, cardinality)
+	}
+	c.record(metric{metricType: set, name: name, svalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// Timing records timing information, it is an alias for TimeInMilliseconds
+func (c *RecordingClientEx) Timing(name string, value time.Duration, tags []string, rate float64, parameters ...Parameter) error {
+	return c.TimeInMilliseconds(name, value.Seconds()*1000, tags, rate, parameters...)
+}
+
+// TimeInMilliseconds records timing information in milliseconds.
+func (c *RecordingClientEx) TimeInMilliseconds(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalMetricsTiming, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.aggExtended != nil {
+		return c.aggExtended.timing(name, value, tags, rate, cardinality)
+	}
+	c.record(metric{metricType: timing, name: name, fvalue: value, tags: tags, rate: rate, cardinality: cardinality})
+	return nil
+}
+
+// Event records the provided Event.
+func (c *RecordingClientEx) Event(e *Event, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalEvents, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	c.record(metric{metricType: event, evalue: e, rate: 1, cardinality: cardinality})
+	return nil
+}
+
+// SimpleEvent records an event with the provided title and text.
+func (c *RecordingClientEx) SimpleEvent(title, text string, parameters ...Parameter) error {
+	e := NewEvent(title, text)
+	return c.Event(e, parameters...)
+}
+
+// ServiceCheck records the provided ServiceCheck.
+func (c *RecordingClientEx) ServiceCheck(sc *ServiceCheck, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	atomic.AddUint64(&c.telemetry.totalServiceChecks, 1)
+	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	c.record(metric{metricType: serviceCheck, scvalue: sc, rate: 1, cardinality: cardinality})
+	return nil
+}
+
+// SimpleServiceCheck records a serviceCheck with the provided name and status.
+func (c *RecordingClientEx) SimpleServiceCheck(name string, status ServiceCheckStatus, parameters ...Parameter) error {
+	sc := NewServiceCheck(name, status)
+	return c.ServiceCheck(sc, parameters...)
+}
+
+// Flush records the metrics aggregated since the last flush. It does nothing when aggregation is disabled.
+func (c *RecordingClientEx) Flush() error {
+	if c == nil {
+		return ErrNoClient
+	}
+	if c.agg != nil {
+		metrics := c.agg.flushMetrics()
+		now := time.Now()
+		c.agg.stampWindow(metrics, windowStart(now, c.agg.flushInterval), now)
+		for _, m := range metrics {
+			c.record(m)
+		}
+		c.agg.observe(metrics, c.namespace, c.tags)
+	}
+	return nil
+}
+
+// Close flushes the client. Records are still available after the client is closed.
+func (c *RecordingClientEx) Close() error {
+	if c == nil {
+		return ErrNoClient
+	}
+	c.Flush()
+
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return nil
+}
+
+// IsClosed returns if the client has been closed.
+func (c *RecordingClientEx) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// GetTelemetry return the number of metrics, events and service checks received by the client since it started.
+func (c *RecordingClientEx) GetTelemetry() Telemetry {
+	tlm := Telemetry{
+		TotalMetricsGauge:        atomic.LoadUint64(&c.telemetry.totalMetricsGauge),
+		TotalMetricsCount:        atomic.LoadUint64(&c.telemetry.totalMetricsCount),
+		TotalMetricsSet:          atomic.LoadUint64(&c.telemetry.totalMetricsSet),
+		TotalMetricsHistogram:    atomic.LoadUint64(&c.telemetry.totalMetricsHistogram),
+		TotalMetricsDistribution: atomic.LoadUint64(&c.telemetry.totalMetricsDistribution),
+		TotalMetricsTiming:       atomic.LoadUint64(&c.telemetry.totalMetricsTiming),
+		TotalEvents:              atomic.LoadUint64(&c.telemetry.totalEvents),
+		TotalServiceChecks:       atomic.LoadUint64(&c.telemetry.totalServiceChecks),
+	}
+	tlm.TotalMetrics = tlm.TotalMetricsGauge +
+		tlm.TotalMetricsCount +
+		tlm.TotalMetricsSet +
+		tlm.TotalMetricsHistogram +
+		tlm.TotalMetricsDistribution +
+		tlm.TotalMetricsTiming
+	return tlm
+}
+
+func (*RecordingClientEx) private() {
+}
+
+// Metrics returns all the metrics recorded, in order.
+func (c *RecordingClientEx) Metrics() []protocol.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]protocol.Metric{}, c.metrics...)
+}
+
+// Events returns all the events recorded, in order.
+func (c *RecordingClientEx) Events() []protocol.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]protocol.Event{}, c.events...)
+}
+
+// ServiceChecks returns all the service checks recorded, in order.
+func (c *RecordingClientEx) ServiceChecks() []protocol.ServiceCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]protocol.ServiceCheck{}, c.serviceChecks...)
+}
+
+// FindMetrics returns the metrics recorded with the given name, including the namespace, and having at least all the
+// given tags.
+func (c *RecordingClientEx) FindMetrics(name string, tags ...string) []protocol.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := []protocol.Metric{}
+	for _, m := range c.metrics {
+		if m.Name == name && m.HasTags(tags...) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func (c *RecordingClientEx) findMetricsOfType(name string, tags []string, types ...protocol.MetricType) []protocol.Metric {
+	res := []protocol.Metric{}
+	for _, m := range c.FindMetrics(name, tags...) {
+		for _, t := range types {
+			if m.Type == t {
+				res = append(res, m)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// CountSum returns the sum of all the counts recorded with the given name and at least all the given tags. Each count
+// value is divided by its sample rate like the Agent does, and like statsdtest.Server.AssertCount. Counts recorded with
+// a rate of 0 or less are ignored since the client never sends them.
+func (c *RecordingClientEx) CountSum(name string, tags ...string) float64 {
+	sum := 0.0
+	for _, m := range c.findMetricsOfType(name, tags, protocol.Count) {
+		if m.Rate <= 0 {
+			continue
+		}
+		for _, v := range m.Values {
+			sum += v / m.Rate
+		}
+	}
+	return sum
+}
+
+// LastGauge returns the value of the last gauge recorded with the given name and at least all the given tags. The
+// boolean is false when no such gauge was recorded.
+func (c *RecordingClientEx) LastGauge(name string, tags ...string) (float64, bool) {
+	gauges := c.findMetricsOfType(name, tags, protocol.Gauge)
+	if len(gauges) == 0 {
+		return 0, false
+	}
+	last := gauges[len(gauges)-1]
+	return last.Values[len(last.Values)-1], true
+}
+
+// Samples returns all the values of the histograms, distributions and timings recorded with the given name and at
+// least all the given tags, in order.
+func (c *RecordingClientEx) Samples(name string, tags ...string) []float64 {
+	samples := []float64{}
+	for _, m := range c.findMetricsOfType(name, tags, protocol.Histogram, protocol.Distribution, protocol.Timing) {
+		samples = append(samples, m.Values...)
+	}
+	return samples
+}
+
+// SetValues returns the unique values of the sets recorded with the given name and at least all the given tags, in the
+// order they were first recorded.
+func (c *RecordingClientEx) SetValues(name string, tags ...string) []string {
+	seen := map[string]struct{}{}
+	values := []string{}
+	for _, m := range c.findMetricsOfType(name, tags, protocol.Set) {
+		if _, ok := seen[m.StringValue]; !ok {
+			seen[m.StringValue] = struct{}{}
+			values = append(values, m.StringValue)
+		}
+	}
+	return values
+}
+
+// Reset discards everything recorded so far. Metrics pending aggregation are not discarded.
+func (c *RecordingClientEx) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = nil
+	c.events = nil
+	c.serviceChecks = nil
+}
+
+// RecordingClient is the ClientInterface and ClientDirectInterface counterpart of RecordingClientEx. All the query
+// helpers of RecordingClientEx are available on it.
+type RecordingClient struct {
+	*RecordingClientEx
+}
+
+// Verify that RecordingClient implements the ClientInterface and ClientDirectInterface interfaces.
+var _ ClientInterface = &RecordingClient{}
+var _ ClientDirectInterface = &RecordingClient{}
+
+// NewRecordingClient returns a new RecordingClient. See NewRecordingClientEx.
+func NewRecordingClient(options ...Option) (*RecordingClient, error) {
+	clientEx, err := NewRecordingClientEx(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingClient{clientEx}, nil
+}
+
+// Gauge records the value of a metric at a particular time.
+func (c *RecordingClient) Gauge(name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Gauge(name, value, tags, rate)
+}
+
+// GaugeWithTimestamp records the value of a metric at a given time. It is only aggregated with WithTimestampAggregation.
+func (c *RecordingClient) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.GaugeWithTimestamp(name, value, tags, rate, timestamp)
+}
+
+// Count records how many times something happened per second.
+func (c *RecordingClient) Count(name string, value int64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Count(name, value, tags, rate)
+}
+
+// CountWithTimestamp records how many times something happened at the given second. It is only aggregated with
+// WithTimestampAggregation.
+func (c *RecordingClient) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.CountWithTimestamp(name, value, tags, rate, timestamp)
+}
+
+// Histogram records the statistical distribution of a set of values on each host.
+func (c *RecordingClient) Histogram(name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Histogram(name, value, tags, rate)
+}
+
+// Distribution records the statistical distribution of a set of values across your infrastructure.
+func (c *RecordingClient) Distribution(name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Distribution(name, value, tags, rate)
+}
+
+// DistributionSamples records several samples of a distribution at once. They are never aggregated.
+func (c *RecordingClient) DistributionSamples(name string, values []float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.DistributionSamples(name, values, tags, rate)
+}
+
+// Decr is just Count of -1
+func (c *RecordingClient) Decr(name string, tags []string, rate float64) error {
+	return c.Count(name, -1, tags, rate)
+}
+
+// Incr is just Count of 1
+func (c *RecordingClient) Incr(name string, tags []string, rate float64) error {
+	return c.Count(name, 1, tags, rate)
+}
+
+// Set records the number of unique elements in a group.
+func (c *RecordingClient) Set(name string, value string, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Set(name, value, tags, rate)
+}
+
+// Timing records timing information, it is an alias for TimeInMilliseconds
+func (c *RecordingClient) Timing(name string, value time.Duration, tags []string, rate float64) error {
+	return c.TimeInMilliseconds(name, value.Seconds()*1000, tags, rate)
+}
+
+// TimeInMilliseconds records timing information in milliseconds.
+func (c *RecordingClient) TimeInMilliseconds(name string, value float64, tags []string, rate float64) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.TimeInMilliseconds(name, value, tags, rate)
+}
+
+// Event records the provided Event.
+func (c *RecordingClient) Event(e *Event) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Event(e)
+}
+
+// SimpleEvent records an event with the provided title and text.
+func (c *RecordingClient) SimpleEvent(title, text string) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.SimpleEvent(title, text)
+}
+
+// ServiceCheck records the provided ServiceCheck.
+func (c *RecordingClient) ServiceCheck(sc *ServiceCheck) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.ServiceCheck(sc)
+}
+
+// SimpleServiceCheck records a serviceCheck with the provided name and status.
+func (c *RecordingClient) SimpleServiceCheck(name string, status ServiceCheckStatus) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.SimpleServiceCheck(name, status)
+}
+
+// Flush records the metrics aggregated since the last flush. It does nothing when aggregation is disabled.
+func (c *RecordingClient) Flush() error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Flush()
+}
+
+// Close flushes the client. Records are still available after the client is closed.
+func (c *RecordingClient) Close() error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.RecordingClientEx.Close()
+}
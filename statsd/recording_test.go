@@ -0,0 +1,218 @@
+package statsd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+func TestRecordingClient(t *testing.T) {
+	c, err := NewRecordingClient(WithNamespace("ns."), WithTags([]string{"global"}))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Count("count", 2, []string{"a"}, 1))
+	require.NoError(t, c.Count("count", 3, []string{"a"}, 0.5))
+	require.NoError(t, c.Incr("count", []string{"b"}, 1))
+	require.NoError(t, c.Decr("count", nil, 1))
+	require.NoError(t, c.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, c.Gauge("gauge", 2, []string{"a"}, 1))
+	require.NoError(t, c.Histogram("histogram", 1, nil, 1))
+	require.NoError(t, c.Distribution("distribution", 2, nil, 1))
+	require.NoError(t, c.DistributionSamples("distribution", []float64{3, 4}, nil, 1))
+	require.NoError(t, c.Timing("timing", time.Second, nil, 1))
+	require.NoError(t, c.TimeInMilliseconds("timing", 5, nil, 1))
+	require.NoError(t, c.Set("set", "b", nil, 1))
+	require.NoError(t, c.Set("set", "a", nil, 1))
+	require.NoError(t, c.Set("set", "b", nil, 1))
+	require.NoError(t, c.SimpleEvent("title", "text"))
+	require.NoError(t, c.SimpleServiceCheck("check", Warn))
+
+	assert.Len(t, c.Metrics(), 14)
+	// the count sampled at 0.5 stands for 6
+	assert.Equal(t, 8.0, c.CountSum("ns.count"))
+	assert.Equal(t, 8.0, c.CountSum("ns.count", "a", "global"))
+	assert.Equal(t, 0.0, c.CountSum("count"))
+
+	value, ok := c.LastGauge("ns.gauge")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, value)
+	_, ok = c.LastGauge("ns.gauge", "b")
+	assert.False(t, ok)
+
+	assert.Equal(t, []float64{1}, c.Samples("ns.histogram"))
+	assert.Equal(t, []float64{2, 3, 4}, c.Samples("ns.distribution"))
+	assert.Equal(t, []float64{1000, 5}, c.Samples("ns.timing"))
+	assert.Equal(t, []string{"b", "a"}, c.SetValues("ns.set"))
+
+	metrics := c.FindMetrics("ns.count", "a")
+	require.Len(t, metrics, 2)
+	assert.Equal(t, protocol.Metric{
+		Name:   "ns.count",
+		Type:   protocol.Count,
+		Values: []float64{3},
+		Rate:   0.5,
+		Tags:   []string{"global", "a"},
+	}, metrics[1])
+
+	events := c.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "title", events[0].Title)
+	assert.Equal(t, "text", events[0].Text)
+	assert.Equal(t, []string{"global"}, events[0].Tags)
+
+	serviceChecks := c.ServiceChecks()
+	require.Len(t, serviceChecks, 1)
+	assert.Equal(t, "check", serviceChecks[0].Name)
+	assert.Equal(t, int(Warn), serviceChecks[0].Status)
+
+	tlm := c.GetTelemetry()
+	assert.Equal(t, uint64(15), tlm.TotalMetrics)
+	assert.Equal(t, uint64(4), tlm.TotalMetricsCount)
+	assert.Equal(t, uint64(3), tlm.TotalMetricsDistribution)
+	assert.Equal(t, uint64(1), tlm.TotalEvents)
+	assert.Equal(t, uint64(1), tlm.TotalServiceChecks)
+
+	c.Reset()
+	assert.Empty(t, c.Metrics())
+	assert.Empty(t, c.Events())
+	assert.Empty(t, c.ServiceChecks())
+
+	assert.False(t, c.IsClosed())
+	require.NoError(t, c.Close())
+	assert.True(t, c.IsClosed())
+}
+
+func TestRecordingClientCountSumRate(t *testing.T) {
+	c, err := NewRecordingClient()
+	require.NoError(t, err)
+
+	require.NoError(t, c.Count("count", 1, nil, 0.5))
+	require.NoError(t, c.Count("count", 3, nil, 1))
+	require.NoError(t, c.Count("count", 5, nil, 0))
+
+	// the sampled count stands for 2, as for the agent and statsdtest.Server.AssertCount
+	assert.Equal(t, 5.0, c.CountSum("count"))
+}
+
+func TestRecordingClientTimestamp(t *testing.T) {
+	c, err := NewRecordingClient()
+	require.NoError(t, err)
+
+	ts := time.Unix(1658934092, 0)
+	require.NoError(t, c.GaugeWithTimestamp("gauge", 1, nil, 1, ts))
+	require.NoError(t, c.CountWithTimestamp("count", 1, nil, 1, ts))
+	assert.Equal(t, InvalidTimestamp, c.GaugeWithTimestamp("gauge", 1, nil, 1, time.Time{}))
+	assert.Equal(t, InvalidTimestamp, c.CountWithTimestamp("count", 1, nil, 1, time.Time{}))
+
+	metrics := c.Metrics()
+	require.Len(t, metrics, 2)
+	assert.Equal(t, ts, metrics[0].Timestamp)
+	assert.Equal(t, ts, metrics[1].Timestamp)
+}
+
+func TestRecordingClientCardinality(t *testing.T) {
+	c, err := NewRecordingClientEx(WithCardinality(CardinalityLow))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, c.Gauge("gauge", 1, nil, 1, CardinalityHigh))
+	require.NoError(t, c.SimpleEvent("title", "text", CardinalityNone))
+
+	metrics := c.Metrics()
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "low", metrics[0].Cardinality)
+	assert.Equal(t, "high", metrics[1].Cardinality)
+	assert.Equal(t, "none", c.Events()[0].Cardinality)
+}
+
+func TestRecordingClientEnvTags(t *testing.T) {
+	defer os.Unsetenv("DD_ENV")
+	os.Setenv("DD_ENV", "dev")
+
+	c, err := NewRecordingClient(WithTags([]string{"global"}))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Incr("count", nil, 1))
+	assert.Equal(t, []string{"global", "env:dev"}, c.Metrics()[0].Tags)
+}
+
+func TestRecordingClientAggregation(t *testing.T) {
+	c, err := NewRecordingClient(WithClientSideAggregation(), WithTags([]string{"global"}))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Count("count", 2, []string{"a"}, 1))
+	require.NoError(t, c.Count("count", 3, []string{"a"}, 1))
+	require.NoError(t, c.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, c.Gauge("gauge", 2, nil, 1))
+	require.NoError(t, c.Set("set", "a", nil, 1))
+	require.NoError(t, c.Set("set", "a", nil, 1))
+	// histograms are only aggregated with the extended aggregation
+	require.NoError(t, c.Histogram("histogram", 1, nil, 1))
+
+	require.Len(t, c.Metrics(), 1)
+	require.NoError(t, c.Flush())
+
+	assert.Len(t, c.Metrics(), 4)
+	assert.Len(t, c.FindMetrics("count"), 1)
+	assert.Equal(t, 5.0, c.CountSum("count", "a", "global"))
+	value, ok := c.LastGauge("gauge")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, value)
+	assert.Len(t, c.FindMetrics("set"), 1)
+	assert.Equal(t, []string{"a"}, c.SetValues("set"))
+
+	// aggregated contexts are reset after each flush
+	require.NoError(t, c.Flush())
+	assert.Len(t, c.Metrics(), 4)
+}
+
+func TestRecordingClientExtendedAggregation(t *testing.T) {
+	c, err := NewRecordingClient(WithExtendedClientSideAggregation())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Histogram("histogram", 1, []string{"a"}, 1))
+	require.NoError(t, c.Histogram("histogram", 2, []string{"a"}, 1))
+	require.NoError(t, c.Distribution("distribution", 3, nil, 1))
+	require.NoError(t, c.Timing("timing", time.Millisecond, nil, 1))
+	assert.Empty(t, c.Metrics())
+
+	require.NoError(t, c.Close())
+	metrics := c.FindMetrics("histogram", "a")
+	require.Len(t, metrics, 1)
+	assert.Equal(t, []float64{1, 2}, metrics[0].Values)
+	assert.Equal(t, []string{"a"}, metrics[0].Tags)
+	assert.Equal(t, []float64{3}, c.Samples("distribution"))
+	assert.Equal(t, []float64{1}, c.Samples("timing"))
+}
+
+func TestRecordingClientNil(t *testing.T) {
+	var c *RecordingClient
+	assert.Equal(t, ErrNoClient, c.Gauge("gauge", 1, nil, 1))
+	assert.Equal(t, ErrNoClient, c.Flush())
+	assert.Equal(t, ErrNoClient, c.Close())
+
+	var cEx *RecordingClientEx
+	assert.Equal(t, ErrNoClient, cEx.Count("count", 1, nil, 1))
+	assert.Equal(t, ErrNoClient, cEx.Flush())
+}
+
+func TestRecordingClientTimestampAggregation(t *testing.T) {
+	c, err := NewRecordingClient(WithTimestampAggregation(0))
+	require.NoError(t, err)
+
+	timestamp := time.Unix(1658934092, 0)
+	require.NoError(t, c.CountWithTimestamp("count", 2, nil, 1, timestamp))
+	require.NoError(t, c.CountWithTimestamp("count", 3, nil, 1, timestamp))
+	assert.Empty(t, c.Metrics())
+
+	require.NoError(t, c.Flush())
+	metrics := c.FindMetrics("count")
+	require.Len(t, metrics, 1)
+	assert.Equal(t, []float64{5}, metrics[0].Values)
+	assert.Equal(t, timestamp, metrics[0].Timestamp)
+}
@@ -0,0 +1,153 @@
+package statsd
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy configures how the client retries the payloads it failed to write to the agent. See WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a payload is written, including the first attempt.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry of a payload. The delay doubles after each failed retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two retries of a payload. 0 means no cap.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, that is randomly removed from each delay so clients don't
+	// retry in lockstep.
+	Jitter float64
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("MaxAttempts must be a positive integer")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("backoff must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("Jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// backoff returns the delay before the next attempt of a payload that failed attempts times. random must be in [0, 1).
+func (p RetryPolicy) backoff(attempts int, random float64) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay - time.Duration(float64(delay)*p.Jitter*random)
+}
+
+// pendingRetry is a payload waiting to be written again.
+type pendingRetry struct {
+	buffer   *statsdBuffer
+	attempts int
+	next     time.Time
+}
+
+// The methods below are only called from the sender goroutine.
+
+// scheduleRetry queues buffer to be written again, it returns false if the payload can't be retried anymore.
+func (s *sender) scheduleRetry(buffer *statsdBuffer, attempts int, now time.Time) bool {
+	if s.retryPolicy == nil || attempts >= s.retryPolicy.MaxAttempts || len(s.retries) >= cap(s.queue) {
+		return false
+	}
+	next := now.Add(s.retryPolicy.backoff(attempts, s.random.Float64()))
+	s.retries = append(s.retries, pendingRetry{buffer: buffer, attempts: attempts, next: next})
+	s.resetRetryTimer(now)
+	return true
+}
+
+// retryPending writes again the payloads whose backoff expired. When a retry fails the other expired payloads are
+// postponed to the next attempt of the failed one instead of hammering an unreachable agent.
+func (s *sender) retryPending(now time.Time) {
+	pending := s.retries
+	s.retries = nil
+	var postponeTo time.Time
+	for _, r := range pending {
+		if r.next.After(now) {
+			s.retries = append(s.retries, r)
+			continue
+		}
+		if !postponeTo.IsZero() {
+			r.next = postponeTo
+			s.retries = append(s.retries, r)
+			continue
+		}
+
+		atomic.AddUint64(&s.telemetry.totalPayloadsRetried, 1)
+		if s.tryWrite(r.buffer.bytes()) == nil {
+			atomic.AddUint64(&s.telemetry.totalPayloadsRecovered, 1)
+			s.pool.returnBuffer(r.buffer)
+			continue
+		}
+		r.attempts++
+		if r.attempts >= s.retryPolicy.MaxAttempts {
+			s.giveUp(r.buffer)
+			continue
+		}
+		r.next = now.Add(s.retryPolicy.backoff(r.attempts, s.random.Float64()))
+		postponeTo = r.next
+		s.retries = append(s.retries, r)
+	}
+	s.resetRetryTimer(now)
+}
+
+// flushRetries makes a last attempt to write every pending payload, regardless of their backoff.
+func (s *sender) flushRetries() {
+	pending := s.retries
+	s.retries = nil
+	for _, r := range pending {
+		atomic.AddUint64(&s.telemetry.totalPayloadsRetried, 1)
+		if s.tryWrite(r.buffer.bytes()) == nil {
+			atomic.AddUint64(&s.telemetry.totalPayloadsRecovered, 1)
+			s.pool.returnBuffer(r.buffer)
+		} else {
+			s.giveUp(r.buffer)
+		}
+	}
+	if s.retryTimer != nil {
+		s.retryTimer.Stop()
+	}
+	s.retryC = nil
+}
+
+// resetRetryTimer arms the retry timer for the earliest pending payload.
+func (s *sender) resetRetryTimer(now time.Time) {
+	if len(s.retries) == 0 {
+		s.retryC = nil
+		return
+	}
+	next := s.retries[0].next
+	for _, r := range s.retries[1:] {
+		if r.next.Before(next) {
+			next = r.next
+		}
+	}
+	if s.retryTimer == nil {
+		s.retryTimer = time.NewTimer(next.Sub(now))
+	} else {
+		if !s.retryTimer.Stop() {
+			select {
+			case <-s.retryTimer.C:
+			default:
+			}
+		}
+		s.retryTimer.Reset(next.Sub(now))
+	}
+	s.retryC = s.retryTimer.C
+}
+
+func newRetryRandom() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
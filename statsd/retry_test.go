@@ -0,0 +1,188 @@
+package statsd
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1, 0.5))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2, 0.5))
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3, 0.5))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4, 0.5))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(100, 0.5))
+
+	p.Jitter = 0.5
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1, 0))
+	assert.Equal(t, 5*time.Millisecond, p.backoff(1, 1))
+	assert.Equal(t, 37500*time.Microsecond, p.backoff(4, 0.5))
+
+	p.MaxBackoff = 0
+	assert.Equal(t, 80*time.Millisecond, p.backoff(4, 0))
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	assert.NoError(t, RetryPolicy{MaxAttempts: 1}.validate())
+	assert.EqualError(t, RetryPolicy{}.validate(), "MaxAttempts must be a positive integer")
+	assert.EqualError(t, RetryPolicy{MaxAttempts: 3, InitialBackoff: -1}.validate(), "backoff must not be negative")
+	assert.EqualError(t, RetryPolicy{MaxAttempts: 3, Jitter: 2}.validate(), "Jitter must be between 0 and 1")
+
+	_, err := resolveOptions([]Option{WithRetryPolicy(RetryPolicy{})})
+	assert.EqualError(t, err, "MaxAttempts must be a positive integer")
+
+	options, err := resolveOptions([]Option{WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second})})
+	require.NoError(t, err)
+	assert.Equal(t, &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, options.retryPolicy)
+}
+
+// newTestRetrySender returns a stopped sender so the test can drive write and retryPending itself.
+func newTestRetrySender(writer Transport, policy RetryPolicy, queueSize int) (*sender, *bufferPool) {
+	pool := newBufferPool(10, 1024, 1)
+	sender := newSender(writer, queueSize, pool, nil)
+	sender.retryPolicy = &policy
+	sender.random = newRetryRandom()
+	sender.stop <- struct{}{}
+	<-sender.stop
+	return sender, pool
+}
+
+func writeTestPayload(s *sender, pool *bufferPool, payload string) {
+	buffer := pool.borrowBuffer()
+	buffer.buffer = append(buffer.buffer, payload...)
+	s.write(buffer)
+}
+
+func TestSenderRetryRecovered(t *testing.T) {
+	writer := &toggleWriter{fail: true}
+	sender, pool := newTestRetrySender(writer, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, 10)
+
+	writeTestPayload(sender, pool, "a")
+	writeTestPayload(sender, pool, "b")
+	require.Len(t, sender.retries, 2)
+	assert.NotNil(t, sender.retryC)
+
+	// backoff not expired yet
+	sender.retryPending(time.Now())
+	assert.Len(t, sender.retries, 2)
+	assert.Equal(t, uint64(0), sender.telemetry.totalPayloadsRetried)
+
+	writer.fail = false
+	sender.retryPending(time.Now().Add(time.Minute))
+	assert.Empty(t, sender.retries)
+	assert.Nil(t, sender.retryC)
+	assert.Equal(t, []string{"a", "b"}, writer.written)
+
+	tlm := Telemetry{}
+	sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(2), tlm.TotalPayloadsRetried)
+	assert.Equal(t, uint64(2), tlm.TotalPayloadsRecovered)
+	assert.Equal(t, uint64(2), tlm.TotalPayloadsSent)
+	assert.Equal(t, uint64(0), tlm.TotalPayloadsDroppedWriter)
+	assert.Equal(t, 10, len(pool.pool))
+}
+
+func TestSenderRetryExhausted(t *testing.T) {
+	writer := &toggleWriter{fail: true}
+	sender, pool := newTestRetrySender(writer, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, 10)
+
+	writeTestPayload(sender, pool, "a")
+	writeTestPayload(sender, pool, "b")
+
+	// The first retry fails and postpones the second one without attempting it.
+	sender.retryPending(time.Now().Add(time.Minute))
+	require.Len(t, sender.retries, 2)
+	assert.Equal(t, 2, sender.retries[0].attempts)
+	assert.Equal(t, 1, sender.retries[1].attempts)
+	assert.Equal(t, sender.retries[0].next, sender.retries[1].next)
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsRetried)
+
+	sender.retryPending(time.Now().Add(time.Hour))
+	require.Len(t, sender.retries, 1)
+	assert.Equal(t, "b", string(sender.retries[0].buffer.bytes()))
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsDroppedWriter)
+
+	// closing makes a last attempt
+	writer.fail = false
+	sender.flushRetries()
+	assert.Empty(t, sender.retries)
+	assert.Equal(t, []string{"b"}, writer.written)
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsRecovered)
+	assert.Equal(t, 10, len(pool.pool))
+}
+
+func TestSenderRetryBounded(t *testing.T) {
+	writer := &toggleWriter{fail: true}
+	sender, pool := newTestRetrySender(writer, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, 2)
+
+	writeTestPayload(sender, pool, "a")
+	writeTestPayload(sender, pool, "b")
+	writeTestPayload(sender, pool, "c")
+
+	assert.Len(t, sender.retries, 2)
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsDroppedWriter)
+}
+
+func TestSenderRetryWithoutRetries(t *testing.T) {
+	writer := &toggleWriter{fail: true}
+	sender, pool := newTestRetrySender(writer, RetryPolicy{MaxAttempts: 1}, 10)
+
+	writeTestPayload(sender, pool, "a")
+	assert.Empty(t, sender.retries)
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsDroppedWriter)
+}
+
+// flakyWriter fails its first writes, as many as failures.
+type flakyWriter struct {
+	sync.Mutex
+	failures int
+	written  []string
+}
+
+func (w *flakyWriter) Write(data []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return 0, fmt.Errorf("agent unreachable")
+	}
+	w.written = append(w.written, string(data))
+	return len(data), nil
+}
+
+func (w *flakyWriter) Close() error {
+	return nil
+}
+
+func (w *flakyWriter) GetTransportName() string {
+	return "flaky"
+}
+
+func TestSenderRetryLoop(t *testing.T) {
+	writer := &flakyWriter{failures: 2}
+	pool := newBufferPool(10, 1024, 1)
+	sender := newSender(writer, 10, pool, nil)
+	sender.retryPolicy = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+	sender.random = newRetryRandom()
+
+	buffer := pool.borrowBuffer()
+	buffer.buffer = append(buffer.buffer, "a"...)
+	sender.send(buffer)
+
+	require.Eventually(t, func() bool {
+		writer.Lock()
+		defer writer.Unlock()
+		return len(writer.written) == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, sender.close())
+
+	tlm := Telemetry{}
+	sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(2), tlm.TotalPayloadsRetried)
+	assert.Equal(t, uint64(1), tlm.TotalPayloadsRecovered)
+}
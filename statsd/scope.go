@@ -0,0 +1,418 @@
+package statsd
+
+import (
+	"time"
+)
+
+// ScopedClientEx is a lightweight view on a client adding a prefix to the name of the metrics and tags to every
+// metric, event and service check. It shares the workers, aggregator, sender and telemetry of its parent client: a
+// scope doesn't start any goroutine nor open any connection, so it can be created for each component of an
+// application.
+//
+// The prefix is added after the namespace of the parent client, and the tags of the scope are added before the tags
+// given to each call. Closing a scope is a no-op: the parent client owns the connection.
+type ScopedClientEx struct {
+	parent ClientInterfaceEx
+	prefix string
+	tags   []string
+}
+
+// Verify that ScopedClientEx implements the ClientInterfaceEx interface.
+var _ ClientInterfaceEx = &ScopedClientEx{}
+
+// WithPrefix returns a scope on c adding prefix to the name of every metric, after the namespace of c. As with
+// WithNamespace, a trailing "." must be included in prefix if needed.
+func (c *ClientEx) WithPrefix(prefix string) *ScopedClientEx {
+	if c == nil {
+		return &ScopedClientEx{}
+	}
+	return &ScopedClientEx{parent: c, prefix: prefix}
+}
+
+// WithTags returns a scope on c adding tags to every metric, event and service check.
+func (c *ClientEx) WithTags(tags ...string) *ScopedClientEx {
+	if c == nil {
+		return &ScopedClientEx{}
+	}
+	return &ScopedClientEx{parent: c, tags: copySlice(tags)}
+}
+
+// WithPrefix returns a scope on c adding prefix to the name of every recorded metric.
+func (c *RecordingClientEx) WithPrefix(prefix string) *ScopedClientEx {
+	if c == nil {
+		return &ScopedClientEx{}
+	}
+	return &ScopedClientEx{parent: c, prefix: prefix}
+}
+
+// WithTags returns a scope on c adding tags to every recorded metric, event and service check.
+func (c *RecordingClientEx) WithTags(tags ...string) *ScopedClientEx {
+	if c == nil {
+		return &ScopedClientEx{}
+	}
+	return &ScopedClientEx{parent: c, tags: copySlice(tags)}
+}
+
+// WithPrefix returns a new scope adding prefix after the prefix of s. s is left untouched.
+func (s *ScopedClientEx) WithPrefix(prefix string) *ScopedClientEx {
+	if s == nil {
+		return &ScopedClientEx{}
+	}
+	return &ScopedClientEx{parent: s.parent, prefix: s.prefix + prefix, tags: s.tags}
+}
+
+// WithTags returns a new scope adding tags after the tags of s. s is left untouched.
+func (s *ScopedClientEx) WithTags(tags ...string) *ScopedClientEx {
+	if s == nil {
+		return &ScopedClientEx{}
+	}
+	newTags := make([]string, 0, len(s.tags)+len(tags))
+	newTags = append(newTags, s.tags...)
+	newTags = append(newTags, tags...)
+	return &ScopedClientEx{parent: s.parent, prefix: s.prefix, tags: newTags}
+}
+
+func (s *ScopedClientEx) client() (ClientInterfaceEx, error) {
+	if s == nil || s.parent == nil {
+		return nil, ErrNoClient
+	}
+	return s.parent, nil
+}
+
+// Gauge measures the value of a metric at a particular time.
+func (s *ScopedClientEx) Gauge(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Gauge(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// GaugeWithTimestamp measures the value of a metric at a given time.
+func (s *ScopedClientEx) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.GaugeWithTimestamp(s.prefix+name, value, prependTags(s.tags, tags), rate, timestamp, parameters...)
+}
+
+// Count tracks how many times something happened per second.
+func (s *ScopedClientEx) Count(name string, value int64, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Count(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// CountWithTimestamp tracks how many times something happened at the given second.
+func (s *ScopedClientEx) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.CountWithTimestamp(s.prefix+name, value, prependTags(s.tags, tags), rate, timestamp, parameters...)
+}
+
+// MonotonicCount tracks a cumulative total by sending its increase since the previous call as a Count. The totals are
+// kept by the parent client.
+func (s *ScopedClientEx) MonotonicCount(name string, total int64, tags []string, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.MonotonicCount(s.prefix+name, total, prependTags(s.tags, tags), parameters...)
+}
+
+// Histogram tracks the statistical distribution of a set of values on each host.
+func (s *ScopedClientEx) Histogram(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Histogram(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// Distribution tracks the statistical distribution of a set of values across your infrastructure.
+func (s *ScopedClientEx) Distribution(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Distribution(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// Decr is just Count of -1
+func (s *ScopedClientEx) Decr(name string, tags []string, rate float64, parameters ...Parameter) error {
+	return s.Count(name, -1, tags, rate, parameters...)
+}
+
+// Incr is just Count of 1
+func (s *ScopedClientEx) Incr(name string, tags []string, rate float64, parameters ...Parameter) error {
+	return s.Count(name, 1, tags, rate, parameters...)
+}
+
+// Set counts the number of unique elements in a group.
+func (s *ScopedClientEx) Set(name string, value string, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Set(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// Timing sends timing information, it is an alias for TimeInMilliseconds
+func (s *ScopedClientEx) Timing(name string, value time.Duration, tags []string, rate float64, parameters ...Parameter) error {
+	return s.TimeInMilliseconds(name, value.Seconds()*1000, tags, rate, parameters...)
+}
+
+// TimeInMilliseconds sends timing information in milliseconds.
+func (s *ScopedClientEx) TimeInMilliseconds(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.TimeInMilliseconds(s.prefix+name, value, prependTags(s.tags, tags), rate, parameters...)
+}
+
+// Event sends the provided Event with the tags of the scope added to its tags. The prefix of the scope isn't applied
+// to events.
+func (s *ScopedClientEx) Event(e *Event, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	if len(s.tags) != 0 {
+		scoped := *e
+		scoped.Tags = prependTags(s.tags, e.Tags)
+		e = &scoped
+	}
+	return c.Event(e, parameters...)
+}
+
+// SimpleEvent sends an event with the provided title and text.
+func (s *ScopedClientEx) SimpleEvent(title, text string, parameters ...Parameter) error {
+	return s.Event(NewEvent(title, text), parameters...)
+}
+
+// ServiceCheck sends the provided ServiceCheck with the tags of the scope added to its tags. The prefix of the scope
+// isn't applied to service checks.
+func (s *ScopedClientEx) ServiceCheck(sc *ServiceCheck, parameters ...Parameter) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	if len(s.tags) != 0 {
+		scoped := *sc
+		scoped.Tags = prependTags(s.tags, sc.Tags)
+		sc = &scoped
+	}
+	return c.ServiceCheck(sc, parameters...)
+}
+
+// SimpleServiceCheck sends an serviceCheck with the provided name and status.
+func (s *ScopedClientEx) SimpleServiceCheck(name string, status ServiceCheckStatus, parameters ...Parameter) error {
+	return s.ServiceCheck(NewServiceCheck(name, status), parameters...)
+}
+
+// Close is a no-op: the connection is owned by the parent client and must be closed through it.
+func (s *ScopedClientEx) Close() error {
+	_, err := s.client()
+	return err
+}
+
+// Flush forces a flush of all the queued dogstatsd payloads of the parent client.
+func (s *ScopedClientEx) Flush() error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return c.Flush()
+}
+
+// IsClosed returns if the parent client has been closed.
+func (s *ScopedClientEx) IsClosed() bool {
+	c, err := s.client()
+	if err != nil {
+		return true
+	}
+	return c.IsClosed()
+}
+
+// GetTelemetry return the telemetry metrics of the parent client since it started.
+func (s *ScopedClientEx) GetTelemetry() Telemetry {
+	c, err := s.client()
+	if err != nil {
+		return Telemetry{}
+	}
+	return c.GetTelemetry()
+}
+
+func (*ScopedClientEx) private() {
+}
+
+// ScopedClient is a lightweight view on a Client adding a prefix to the name of the metrics and tags to every metric,
+// event and service check. See ScopedClientEx.
+type ScopedClient struct {
+	scope *ScopedClientEx
+}
+
+// Verify that ScopedClient implements the ClientInterface.
+var _ ClientInterface = &ScopedClient{}
+
+// WithPrefix returns a scope on c adding prefix to the name of every metric, after the namespace of c. As with
+// WithNamespace, a trailing "." must be included in prefix if needed.
+func (c *Client) WithPrefix(prefix string) *ScopedClient {
+	if c == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: c.clientEx.WithPrefix(prefix)}
+}
+
+// WithTags returns a scope on c adding tags to every metric, event and service check.
+func (c *Client) WithTags(tags ...string) *ScopedClient {
+	if c == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: c.clientEx.WithTags(tags...)}
+}
+
+// WithPrefix returns a scope on c adding prefix to the name of every recorded metric.
+func (c *RecordingClient) WithPrefix(prefix string) *ScopedClient {
+	if c == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: c.RecordingClientEx.WithPrefix(prefix)}
+}
+
+// WithTags returns a scope on c adding tags to every recorded metric, event and service check.
+func (c *RecordingClient) WithTags(tags ...string) *ScopedClient {
+	if c == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: c.RecordingClientEx.WithTags(tags...)}
+}
+
+// WithPrefix returns a new scope adding prefix after the prefix of s. s is left untouched.
+func (s *ScopedClient) WithPrefix(prefix string) *ScopedClient {
+	if s == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: s.scope.WithPrefix(prefix)}
+}
+
+// WithTags returns a new scope adding tags after the tags of s. s is left untouched.
+func (s *ScopedClient) WithTags(tags ...string) *ScopedClient {
+	if s == nil {
+		return &ScopedClient{}
+	}
+	return &ScopedClient{scope: s.scope.WithTags(tags...)}
+}
+
+func (s *ScopedClient) scopeEx() *ScopedClientEx {
+	if s == nil {
+		return nil
+	}
+	return s.scope
+}
+
+// Gauge measures the value of a metric at a particular time.
+func (s *ScopedClient) Gauge(name string, value float64, tags []string, rate float64) error {
+	return s.scopeEx().Gauge(name, value, tags, rate)
+}
+
+// GaugeWithTimestamp measures the value of a metric at a given time.
+func (s *ScopedClient) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time) error {
+	return s.scopeEx().GaugeWithTimestamp(name, value, tags, rate, timestamp)
+}
+
+// Count tracks how many times something happened per second.
+func (s *ScopedClient) Count(name string, value int64, tags []string, rate float64) error {
+	return s.scopeEx().Count(name, value, tags, rate)
+}
+
+// CountWithTimestamp tracks how many times something happened at the given second.
+func (s *ScopedClient) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time) error {
+	return s.scopeEx().CountWithTimestamp(name, value, tags, rate, timestamp)
+}
+
+// MonotonicCount tracks a cumulative total by sending its increase since the previous call as a Count.
+func (s *ScopedClient) MonotonicCount(name string, total int64, tags []string) error {
+	return s.scopeEx().MonotonicCount(name, total, tags)
+}
+
+// Histogram tracks the statistical distribution of a set of values on each host.
+func (s *ScopedClient) Histogram(name string, value float64, tags []string, rate float64) error {
+	return s.scopeEx().Histogram(name, value, tags, rate)
+}
+
+// Distribution tracks the statistical distribution of a set of values across your infrastructure.
+func (s *ScopedClient) Distribution(name string, value float64, tags []string, rate float64) error {
+	return s.scopeEx().Distribution(name, value, tags, rate)
+}
+
+// Decr is just Count of -1
+func (s *ScopedClient) Decr(name string, tags []string, rate float64) error {
+	return s.scopeEx().Decr(name, tags, rate)
+}
+
+// Incr is just Count of 1
+func (s *ScopedClient) Incr(name string, tags []string, rate float64) error {
+	return s.scopeEx().Incr(name, tags, rate)
+}
+
+// Set counts the number of unique elements in a group.
+func (s *ScopedClient) Set(name string, value string, tags []string, rate float64) error {
+	return s.scopeEx().Set(name, value, tags, rate)
+}
+
+// Timing sends timing information, it is an alias for TimeInMilliseconds
+func (s *ScopedClient) Timing(name string, value time.Duration, tags []string, rate float64) error {
+	return s.scopeEx().Timing(name, value, tags, rate)
+}
+
+// TimeInMilliseconds sends timing information in milliseconds.
+func (s *ScopedClient) TimeInMilliseconds(name string, value float64, tags []string, rate float64) error {
+	return s.scopeEx().TimeInMilliseconds(name, value, tags, rate)
+}
+
+// Event sends the provided Event with the tags of the scope added to its tags.
+func (s *ScopedClient) Event(e *Event) error {
+	return s.scopeEx().Event(e)
+}
+
+// SimpleEvent sends an event with the provided title and text.
+func (s *ScopedClient) SimpleEvent(title, text string) error {
+	return s.scopeEx().SimpleEvent(title, text)
+}
+
+// ServiceCheck sends the provided ServiceCheck with the tags of the scope added to its tags.
+func (s *ScopedClient) ServiceCheck(sc *ServiceCheck) error {
+	return s.scopeEx().ServiceCheck(sc)
+}
+
+// SimpleServiceCheck sends an serviceCheck with the provided name and status.
+func (s *ScopedClient) SimpleServiceCheck(name string, status ServiceCheckStatus) error {
+	return s.scopeEx().SimpleServiceCheck(name, status)
+}
+
+// Close is a no-op: the connection is owned by the parent client and must be closed through it.
+func (s *ScopedClient) Close() error {
+	return s.scopeEx().Close()
+}
+
+// Flush forces a flush of all the queued dogstatsd payloads of the parent client.
+func (s *ScopedClient) Flush() error {
+	return s.scopeEx().Flush()
+}
+
+// IsClosed returns if the parent client has been closed.
+func (s *ScopedClient) IsClosed() bool {
+	return s.scopeEx().IsClosed()
+}
+
+// GetTelemetry return the telemetry metrics of the parent client since it started.
+func (s *ScopedClient) GetTelemetry() Telemetry {
+	return s.scopeEx().GetTelemetry()
+}
@@ -0,0 +1,141 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedClientPipeline(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		[]string{"global"},
+		WithNamespace("ns."),
+		WithTags([]string{"global"}),
+		WithoutTelemetry(),
+		WithoutClientSideAggregation(),
+	)
+
+	scope := client.WithPrefix("db.").WithTags("component:db")
+	tags := []string{"custom"}
+	require.NoError(t, scope.Gauge("Gauge", 1, tags, 1))
+	require.NoError(t, scope.Count("Count", 2, tags, 1))
+	require.NoError(t, scope.Histogram("Histogram", 3, tags, 1))
+	require.NoError(t, scope.Distribution("Distribution", 4, tags, 1))
+	require.NoError(t, scope.Decr("Decr", tags, 1))
+	require.NoError(t, scope.Incr("Incr", tags, 1))
+	require.NoError(t, scope.Set("Set", "value", tags, 1))
+	require.NoError(t, scope.Timing("Timing", 5*time.Second, tags, 1))
+	require.NoError(t, scope.TimeInMilliseconds("TimeInMilliseconds", 6, nil, 1))
+	require.NoError(t, scope.SimpleEvent("hello", "world"))
+	require.NoError(t, scope.SimpleServiceCheck("hello", Warn))
+
+	finalTags := ts.getFinalTags("component:db", "custom")
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"ns.db.Gauge:1|g" + finalTags + containerID,
+		"ns.db.Count:2|c" + finalTags + containerID,
+		"ns.db.Histogram:3|h" + finalTags + containerID,
+		"ns.db.Distribution:4|d" + finalTags + containerID,
+		"ns.db.Decr:-1|c" + finalTags + containerID,
+		"ns.db.Incr:1|c" + finalTags + containerID,
+		"ns.db.Set:value|s" + finalTags + containerID,
+		"ns.db.Timing:5000.000000|ms" + finalTags + containerID,
+		"ns.db.TimeInMilliseconds:6.000000|ms" + ts.getFinalTags("component:db") + containerID,
+		"_e{5,5}:hello|world" + ts.getFinalTags("component:db") + containerID,
+		"_sc|hello|1" + ts.getFinalTags("component:db") + containerID,
+	})
+}
+
+func TestScopedClientAggregation(t *testing.T) {
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithoutTelemetry(),
+		WithClientSideAggregation(),
+	)
+
+	scope := client.WithTags("a")
+	require.NoError(t, scope.Incr("count", nil, 1))
+	require.NoError(t, scope.Count("count", 2, nil, 1))
+	// Scoped and unscoped calls with the same final context are aggregated together.
+	require.NoError(t, client.Count("count", 4, []string{"a"}, 1))
+
+	ts.assert(t, client, []string{
+		"count:7|c|#a" + ts.getContainerID(),
+	})
+}
+
+func TestScopedClientNesting(t *testing.T) {
+	c, err := NewRecordingClient()
+	require.NoError(t, err)
+
+	parent := c.WithPrefix("lib.").WithTags("lib")
+	child := parent.WithPrefix("sub.").WithTags("sub")
+	sibling := parent.WithTags("sibling")
+
+	require.NoError(t, parent.Incr("count", nil, 1))
+	require.NoError(t, child.Incr("count", []string{"call"}, 1))
+	require.NoError(t, sibling.Incr("count", nil, 1))
+
+	metrics := c.Metrics()
+	require.Len(t, metrics, 3)
+	assert.Equal(t, "lib.count", metrics[0].Name)
+	assert.Equal(t, []string{"lib"}, metrics[0].Tags)
+	assert.Equal(t, "lib.sub.count", metrics[1].Name)
+	assert.Equal(t, []string{"lib", "sub", "call"}, metrics[1].Tags)
+	assert.Equal(t, "lib.count", metrics[2].Name)
+	assert.Equal(t, []string{"lib", "sibling"}, metrics[2].Tags)
+}
+
+func TestScopedClientEventTagsNotModified(t *testing.T) {
+	c, err := NewRecordingClientEx()
+	require.NoError(t, err)
+
+	e := NewEvent("title", "text")
+	e.Tags = []string{"event"}
+	require.NoError(t, c.WithTags("scope").Event(e))
+	sc := NewServiceCheck("check", Ok)
+	sc.Tags = []string{"check"}
+	require.NoError(t, c.WithTags("scope").ServiceCheck(sc))
+
+	assert.Equal(t, []string{"event"}, e.Tags)
+	assert.Equal(t, []string{"check"}, sc.Tags)
+	assert.Equal(t, []string{"scope", "event"}, c.Events()[0].Tags)
+	assert.Equal(t, []string{"scope", "check"}, c.ServiceChecks()[0].Tags)
+}
+
+func TestScopedClientSharesParent(t *testing.T) {
+	client, err := New("localhost:8765", WithoutTelemetry())
+	require.NoError(t, err)
+
+	scope := client.WithPrefix("db.")
+	require.NoError(t, scope.Incr("count", nil, 1))
+	assert.Equal(t, uint64(1), client.clientEx.telemetry.totalMetricsCount)
+
+	// Closing a scope doesn't close the parent.
+	require.NoError(t, scope.Close())
+	assert.False(t, scope.IsClosed())
+	assert.False(t, client.IsClosed())
+
+	require.NoError(t, client.Close())
+	assert.True(t, scope.IsClosed())
+}
+
+func TestScopedClientNil(t *testing.T) {
+	var c *Client
+	assert.Equal(t, ErrNoClient, c.WithPrefix("db.").Gauge("gauge", 1, nil, 1))
+	assert.Equal(t, ErrNoClient, c.WithTags("tag").Flush())
+
+	var cEx *ClientEx
+	assert.Equal(t, ErrNoClient, cEx.WithTags("tag").Count("count", 1, nil, 1))
+	assert.True(t, cEx.WithPrefix("db.").IsClosed())
+
+	var scope *ScopedClientEx
+	assert.Equal(t, ErrNoClient, scope.SimpleEvent("title", "text"))
+	assert.Equal(t, ErrNoClient, scope.WithPrefix("db.").Close())
+}
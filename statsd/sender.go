@@ -2,7 +2,9 @@ package statsd
 
 import (
 	"io"
+	"math/rand"
 	"sync/atomic"
+	"time"
 )
 
 // senderTelemetry contains telemetry about the health of the sender
@@ -13,6 +15,10 @@ type senderTelemetry struct {
 	totalBytesSent                uint64
 	totalBytesDroppedQueueFull    uint64
 	totalBytesDroppedWriter       uint64
+	totalPayloadsBlockedQueueFull uint64
+	totalTimeBlockedQueueFull     uint64 // in nanoseconds
+	totalPayloadsRetried          uint64
+	totalPayloadsRecovered        uint64
 }
 
 type Transport interface {
@@ -30,6 +36,20 @@ type sender struct {
 	stop         chan struct{}
 	flushSignal  chan struct{}
 	errorHandler ErrorHandler
+	// blockingTimeout is how long send waits for room in the queue before dropping a buffer. 0 drops it right away.
+	blockingTimeout time.Duration
+	// spool stores the payloads that couldn't be written, nil if disabled. It is only used by the sender goroutine.
+	spool *diskSpool
+	// replaySignal asks the sender goroutine to replay the spool, see replaySpool.
+	replaySignal chan struct{}
+	// compression is the configuration of the compressed transports, nil if disabled. It's used for telemetry.
+	compression *compressionConfig
+	// retryPolicy is nil if failed payloads aren't retried. The fields below are only used by the sender goroutine.
+	retryPolicy *RetryPolicy
+	retries     []pendingRetry
+	retryTimer  *time.Timer
+	retryC      <-chan time.Time
+	random      *rand.Rand
 }
 
 type ErrorSenderChannelFull struct {
@@ -50,6 +70,7 @@ func newSender(transport Transport, queueSize int, pool *bufferPool, errorHandle
 		telemetry:    &senderTelemetry{},
 		stop:         make(chan struct{}),
 		flushSignal:  make(chan struct{}),
+		replaySignal: make(chan struct{}, 1),
 		errorHandler: errorHandler,
 	}
 
@@ -58,9 +79,24 @@ func newSender(transport Transport, queueSize int, pool *bufferPool, errorHandle
 }
 
 func (s *sender) send(buffer *statsdBuffer) {
+	s.sendUntil(buffer, nil)
+}
+
+// sendUntil is send with the wait of the blocking mode also ending when done is closed. The buffer is then neither
+// queued nor dropped: it is left to the caller and sendUntil returns false.
+func (s *sender) sendUntil(buffer *statsdBuffer, done <-chan struct{}) bool {
 	select {
 	case s.queue <- buffer:
 	default:
+		if s.blockingTimeout > 0 {
+			queued, cancelled := s.waitForQueue(buffer, done)
+			if queued {
+				return true
+			}
+			if cancelled {
+				return false
+			}
+		}
 		if s.errorHandler != nil {
 			err := &ErrorSenderChannelFull{
 				LostElements: buffer.elementCount,
@@ -73,23 +109,90 @@ func (s *sender) send(buffer *statsdBuffer) {
 		atomic.AddUint64(&s.telemetry.totalBytesDroppedQueueFull, uint64(len(buffer.bytes())))
 		s.pool.returnBuffer(buffer)
 	}
+	return true
+}
+
+// waitForQueue blocks until the buffer can be pushed to the queue, until the blocking timeout expires or until done is
+// closed. queued is true if the buffer was queued and cancelled if done was closed first.
+func (s *sender) waitForQueue(buffer *statsdBuffer, done <-chan struct{}) (queued bool, cancelled bool) {
+	start := time.Now()
+	timer := time.NewTimer(s.blockingTimeout)
+	defer func() {
+		timer.Stop()
+		atomic.AddUint64(&s.telemetry.totalPayloadsBlockedQueueFull, 1)
+		atomic.AddUint64(&s.telemetry.totalTimeBlockedQueueFull, uint64(time.Since(start)))
+	}()
+
+	select {
+	case s.queue <- buffer:
+		return true, false
+	case <-timer.C:
+		return false, false
+	case <-done:
+		return false, true
+	}
 }
 
 func (s *sender) write(buffer *statsdBuffer) {
-	_, err := s.transport.Write(buffer.bytes())
-	if err != nil {
+	if s.tryWrite(buffer.bytes()) == nil {
+		s.pool.returnBuffer(buffer)
+		return
+	}
+	if !s.scheduleRetry(buffer, 1, time.Now()) {
+		s.giveUp(buffer)
+	}
+}
+
+// tryWrite writes the spooled payloads, if any, then data.
+func (s *sender) tryWrite(data []byte) error {
+	var err error
+	if s.spool != nil && !s.spool.empty() {
+		// Spooled payloads are replayed first to keep payloads in order.
+		err = s.spool.replay(s.writePayload)
+	}
+	if err == nil {
+		err = s.writePayload(data)
+	}
+	if err != nil && s.errorHandler != nil {
+		s.errorHandler(err)
+	}
+	return err
+}
+
+// replaySpool makes the sender goroutine replay the spooled payloads without waiting for the next payload to write,
+// so that the spool is emptied once the agent is back even if the application stopped sending metrics. It doesn't
+// block.
+func (s *sender) replaySpool() {
+	if s.spool == nil {
+		return
+	}
+	select {
+	case s.replaySignal <- struct{}{}:
+	default:
+		// a replay is already pending
+	}
+}
+
+// giveUp spools or drops a payload that couldn't be written.
+func (s *sender) giveUp(buffer *statsdBuffer) {
+	data := buffer.bytes()
+	if s.spool == nil || s.spool.push(data) != nil {
 		atomic.AddUint64(&s.telemetry.totalPayloadsDroppedWriter, 1)
-		atomic.AddUint64(&s.telemetry.totalBytesDroppedWriter, uint64(len(buffer.bytes())))
-		if s.errorHandler != nil {
-			s.errorHandler(err)
-		}
-	} else {
-		atomic.AddUint64(&s.telemetry.totalPayloadsSent, 1)
-		atomic.AddUint64(&s.telemetry.totalBytesSent, uint64(len(buffer.bytes())))
+		atomic.AddUint64(&s.telemetry.totalBytesDroppedWriter, uint64(len(data)))
 	}
 	s.pool.returnBuffer(buffer)
 }
 
+func (s *sender) writePayload(data []byte) error {
+	_, err := s.transport.Write(data)
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.telemetry.totalPayloadsSent, 1)
+	atomic.AddUint64(&s.telemetry.totalBytesSent, uint64(len(data)))
+	return nil
+}
+
 func (s *sender) flushTelemetryMetrics(t *Telemetry) {
 	t.TotalPayloadsSent = atomic.LoadUint64(&s.telemetry.totalPayloadsSent)
 	t.TotalPayloadsDroppedQueueFull = atomic.LoadUint64(&s.telemetry.totalPayloadsDroppedQueueFull)
@@ -98,6 +201,22 @@ func (s *sender) flushTelemetryMetrics(t *Telemetry) {
 	t.TotalBytesSent = atomic.LoadUint64(&s.telemetry.totalBytesSent)
 	t.TotalBytesDroppedQueueFull = atomic.LoadUint64(&s.telemetry.totalBytesDroppedQueueFull)
 	t.TotalBytesDroppedWriter = atomic.LoadUint64(&s.telemetry.totalBytesDroppedWriter)
+
+	t.TotalPayloadsBlockedQueueFull = atomic.LoadUint64(&s.telemetry.totalPayloadsBlockedQueueFull)
+	t.TotalTimeBlockedQueueFull = time.Duration(atomic.LoadUint64(&s.telemetry.totalTimeBlockedQueueFull))
+
+	t.TotalPayloadsRetried = atomic.LoadUint64(&s.telemetry.totalPayloadsRetried)
+	t.TotalPayloadsRecovered = atomic.LoadUint64(&s.telemetry.totalPayloadsRecovered)
+
+	if s.spool != nil {
+		s.spool.flushTelemetryMetrics(t)
+	}
+	if s.compression != nil {
+		s.compression.flushTelemetryMetrics(t)
+	}
+	if fanOut, ok := s.transport.(*fanOutWriter); ok {
+		fanOut.flushTelemetryMetrics(t)
+	}
 }
 
 func (s *sender) sendLoop() {
@@ -106,6 +225,13 @@ func (s *sender) sendLoop() {
 		select {
 		case buffer := <-s.queue:
 			s.write(buffer)
+		case now := <-s.retryC:
+			s.retryPending(now)
+		case <-s.replaySignal:
+			if !s.spool.empty() {
+				// The write errors were reported when the payloads were spooled.
+				_ = s.spool.replay(s.writePayload)
+			}
 		case <-s.stop:
 			return
 		case <-s.flushSignal:
@@ -137,6 +263,7 @@ func (s *sender) close() error {
 	s.stop <- struct{}{}
 	<-s.stop
 	s.flushInputQueue()
+	s.flushRetries()
 	return s.transport.Close()
 }
 
@@ -3,6 +3,7 @@ package statsd
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -101,3 +102,57 @@ func TestSenderWriteError(t *testing.T) {
 	assert.Equal(t, uint64(0), sender.telemetry.totalBytesDroppedQueueFull)
 	assert.Equal(t, uint64(1), sender.telemetry.totalBytesDroppedWriter)
 }
+
+func TestSenderBlockingModeQueued(t *testing.T) {
+	writer := new(mockedWriter)
+	writer.On("Close").Return(nil)
+
+	// a sender with a queue of 1 message
+	pool := newBufferPool(10, 1024, 1)
+	sender := newSender(writer, 1, pool, LoggingErrorHandler)
+	sender.blockingTimeout = time.Minute
+
+	// close the sender to prevent it from consuming the queue
+	sender.close()
+
+	buffer := pool.borrowBuffer()
+	buffer.writeSeparator() // add some dummy data
+	sender.send(buffer)
+
+	// make room in the queue while the sender is blocked
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-sender.queue
+	}()
+	sender.send(pool.borrowBuffer())
+
+	assert.Equal(t, 1, len(sender.queue))
+	assert.Equal(t, uint64(1), sender.telemetry.totalPayloadsBlockedQueueFull)
+	assert.NotZero(t, sender.telemetry.totalTimeBlockedQueueFull)
+	assert.Equal(t, uint64(0), sender.telemetry.totalPayloadsDroppedQueueFull)
+}
+
+func TestSenderBlockingModeTimeout(t *testing.T) {
+	writer := new(mockedWriter)
+	writer.On("Close").Return(nil)
+
+	// a sender with a queue of 1 message
+	pool := newBufferPool(10, 1024, 1)
+	sender := newSender(writer, 1, pool, LoggingErrorHandler)
+	sender.blockingTimeout = 10 * time.Millisecond
+
+	// close the sender to prevent it from consuming the queue
+	sender.close()
+
+	sender.send(pool.borrowBuffer())
+	buffer := pool.borrowBuffer()
+	buffer.writeSeparator() // add some dummy data
+	sender.send(buffer)
+
+	tlm := Telemetry{}
+	sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(1), tlm.TotalPayloadsBlockedQueueFull)
+	assert.True(t, tlm.TotalTimeBlockedQueueFull >= 10*time.Millisecond)
+	assert.Equal(t, uint64(1), tlm.TotalPayloadsDroppedQueueFull)
+	assert.Equal(t, uint64(1), tlm.TotalBytesDroppedQueueFull)
+}
@@ -0,0 +1,105 @@
+package statsd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// sketchMapping maps values to the buckets of a DDSketch: bucket i holds the values in (gamma^(i-1), gamma^i], so
+// that any value of a bucket is within relativeAccuracy of the value representing it.
+type sketchMapping struct {
+	gamma      float64
+	multiplier float64
+}
+
+func newSketchMapping(relativeAccuracy float64) (*sketchMapping, error) {
+	if !(relativeAccuracy > 0 && relativeAccuracy < 1) {
+		return nil, fmt.Errorf("relative accuracy must be in (0, 1), got %v", relativeAccuracy)
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &sketchMapping{gamma: gamma, multiplier: 1 / math.Log(gamma)}, nil
+}
+
+func (m *sketchMapping) index(v float64) int {
+	return int(math.Ceil(math.Log(v) * m.multiplier))
+}
+
+// value returns the value representing bucket i, at equal relative distance of both bounds of the bucket.
+func (m *sketchMapping) value(i int) float64 {
+	return 2 * math.Pow(m.gamma, float64(i)) / (1 + m.gamma)
+}
+
+// ddSketch counts the weighted samples of a distribution in logarithmic buckets. Unlike the reservoir of bufferedMetric it
+// keeps every sample so the quantiles, including the extreme ones, have a relative error bounded by the mapping no
+// matter the throughput. The number of buckets only grows with the range of the values: about 1,600 buckets cover
+// 1ns to 1 day with a relative accuracy of 1%.
+type ddSketch struct {
+	mapping  *sketchMapping
+	positive map[int]float64
+	negative map[int]float64
+	zero     float64
+}
+
+func newDDSketch(mapping *sketchMapping) *ddSketch {
+	return &ddSketch{
+		mapping:  mapping,
+		positive: map[int]float64{},
+		negative: map[int]float64{},
+	}
+}
+
+// add counts v in the sketch as weight samples. NaN and infinite values can't be represented and are dropped.
+func (s *ddSketch) add(v float64, weight float64) {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+	case v > 0:
+		s.positive[s.mapping.index(v)] += weight
+	case v < 0:
+		s.negative[s.mapping.index(-v)] += weight
+	default:
+		s.zero += weight
+	}
+}
+
+// sketchBucket is a representative value of the sketch and the number of samples it stands for.
+type sketchBucket struct {
+	value  float64
+	weight float64
+}
+
+// buckets returns the non-empty buckets of the sketch sorted by value.
+func (s *ddSketch) buckets() []sketchBucket {
+	buckets := make([]sketchBucket, 0, len(s.positive)+len(s.negative)+1)
+	for i, weight := range s.negative {
+		buckets = append(buckets, sketchBucket{value: -s.mapping.value(i), weight: weight})
+	}
+	if s.zero > 0 {
+		buckets = append(buckets, sketchBucket{value: 0, weight: s.zero})
+	}
+	for i, weight := range s.positive {
+		buckets = append(buckets, sketchBucket{value: s.mapping.value(i), weight: weight})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].value < buckets[j].value })
+	return buckets
+}
+
+// flushSketchUnsafe appends the sketch of the metric to metrics. The representative values of buckets holding the
+// same number of samples are packed in one message, sent with a sample rate of 1/weight so that the agent weights
+// each of them by its number of samples.
+func (s *bufferedMetric) flushSketchUnsafe(metrics []metric) []metric {
+	byWeight := map[float64][]float64{}
+	weights := []float64{}
+	for _, b := range s.sketch.buckets() {
+		if _, ok := byWeight[b.weight]; !ok {
+			weights = append(weights, b.weight)
+		}
+		byWeight[b.weight] = append(byWeight[b.weight], b.value)
+	}
+	sort.Float64s(weights)
+
+	for _, weight := range weights {
+		metrics = append(metrics, s.newFlushedMetric(byWeight[weight], 1/weight))
+	}
+	return metrics
+}
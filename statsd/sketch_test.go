@@ -0,0 +1,136 @@
+package statsd
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchMappingRelativeAccuracy(t *testing.T) {
+	for _, accuracy := range []float64{0.001, 0.01, 0.05} {
+		mapping, err := newSketchMapping(accuracy)
+		require.NoError(t, err)
+		for v := 1e-9; v < 1e12; v *= 1.137 {
+			represented := mapping.value(mapping.index(v))
+			assert.InDelta(t, 0, math.Abs(represented-v)/v, accuracy+1e-12, "value %v", v)
+		}
+	}
+
+	for _, accuracy := range []float64{0, 1, -0.1, math.NaN()} {
+		_, err := newSketchMapping(accuracy)
+		assert.Error(t, err)
+	}
+}
+
+func TestDDSketchBuckets(t *testing.T) {
+	mapping, err := newSketchMapping(0.01)
+	require.NoError(t, err)
+	sketch := newDDSketch(mapping)
+	for _, v := range []float64{3, -2, 0, 3.001, math.NaN(), math.Inf(1), 0, 100} {
+		sketch.add(v, 1)
+	}
+
+	assert.Equal(t, []sketchBucket{
+		{value: -mapping.value(mapping.index(2)), weight: 1},
+		{value: 0, weight: 2},
+		{value: mapping.value(mapping.index(3)), weight: 2},
+		{value: mapping.value(mapping.index(100)), weight: 1},
+	}, sketch.buckets())
+}
+
+func TestDDSketchQuantiles(t *testing.T) {
+	mapping, err := newSketchMapping(0.01)
+	require.NoError(t, err)
+	sketch := newDDSketch(mapping)
+	random := rand.New(rand.NewSource(42))
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = random.ExpFloat64() * 100
+		sketch.add(values[i], 1)
+	}
+	sort.Float64s(values)
+
+	// quantiles computed from the weighted representative values are within the relative accuracy
+	buckets := sketch.buckets()
+	for _, q := range []float64{0.5, 0.99, 0.999} {
+		rank := uint64(q * float64(len(values)-1))
+		var seen float64
+		for _, b := range buckets {
+			seen += b.weight
+			if seen > float64(rank) {
+				exact := values[rank]
+				assert.InDelta(t, 0, math.Abs(b.value-exact)/exact, 0.01+1e-12, "quantile %v", q)
+				break
+			}
+		}
+	}
+}
+
+func TestBufferedMetricContextsSketch(t *testing.T) {
+	mapping, err := newSketchMapping(0.01)
+	require.NoError(t, err)
+	contexts := newBufferedContexts(newDistributionMetric, 2)
+	contexts.sketch = mapping
+
+	for _, v := range []float64{1, 1, 5, 5, 5, 10, 20} {
+		require.NoError(t, contexts.sample("latency", v, []string{"tag"}, 1, CardinalityNotSet))
+	}
+
+	metrics := contexts.flush(nil)
+	assert.Equal(t, []metric{
+		{metricType: distributionAggregated, name: "latency", stags: "tag", rate: 1, fvalues: []float64{mapping.value(mapping.index(10)), mapping.value(mapping.index(20))}},
+		{metricType: distributionAggregated, name: "latency", stags: "tag", rate: 0.5, fvalues: []float64{mapping.value(mapping.index(1))}},
+		{metricType: distributionAggregated, name: "latency", stags: "tag", rate: 1.0 / 3, fvalues: []float64{mapping.value(mapping.index(5))}},
+	}, metrics)
+}
+
+func TestBufferedMetricContextsSketchRate(t *testing.T) {
+	mapping, err := newSketchMapping(0.01)
+	require.NoError(t, err)
+	contexts := newBufferedContexts(newDistributionMetric, 0)
+	contexts.sketch = mapping
+	m := contexts.newContext("latency", 1, "", 0.5, CardinalityNotSet)
+	m.maybeKeepSample(1, 0.25, contexts.random, &contexts.randomLock)
+	m.maybeKeepSample(5, 1, contexts.random, &contexts.randomLock)
+
+	// every sample is weighted by its own rate
+	assert.Equal(t, []metric{
+		{metricType: distributionAggregated, name: "latency", rate: 1, fvalues: []float64{mapping.value(mapping.index(5))}},
+		{metricType: distributionAggregated, name: "latency", rate: 1.0 / 6, fvalues: []float64{mapping.value(mapping.index(1))}},
+	}, m.flushSketchUnsafe(nil))
+}
+
+func TestWithDistributionSketch(t *testing.T) {
+	_, err := resolveOptions([]Option{WithDistributionSketch(1)})
+	assert.EqualError(t, err, "relative accuracy must be in (0, 1), got 1")
+
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8765",
+		nil,
+		WithDistributionSketch(0.01),
+		WithoutTelemetry(),
+	)
+
+	require.NoError(t, client.Distribution("distribution", 0, nil, 1))
+	require.NoError(t, client.Distribution("distribution", 1, nil, 1))
+	require.NoError(t, client.Distribution("distribution", 1, nil, 1))
+	require.NoError(t, client.Histogram("histogram", 1, nil, 1))
+	require.NoError(t, client.Flush())
+
+	mapping, err := newSketchMapping(0.01)
+	require.NoError(t, err)
+	value := strconv.FormatFloat(mapping.value(0), 'f', -1, 64)
+	containerID := ts.getContainerID()
+	ts.assert(t, client, []string{
+		"distribution:0|d" + containerID,
+		"distribution:" + value + "|d|@0.5" + containerID,
+		// histograms are not sketched
+		"histogram:1|h" + containerID,
+	})
+}
@@ -0,0 +1,169 @@
+package statsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const spoolFileSuffix = ".spool"
+
+// spoolTelemetry contains telemetry about the disk spool
+type spoolTelemetry struct {
+	totalBytesSpooled  uint64
+	totalBytesReplayed uint64
+	totalBytesEvicted  uint64
+}
+
+type spoolEntry struct {
+	seq     uint64
+	size    int
+	created time.Time
+}
+
+// diskSpool stores on disk the payloads that couldn't be written to the transport so they can be replayed, in order,
+// once the agent is reachable again. Each payload is stored in its own file named after a sequence number, which
+// allows to replay payloads left by a previous run of the application.
+//
+// diskSpool is not thread safe: it is only used by the sender goroutine.
+type diskSpool struct {
+	dir        string
+	maxBytes   int64
+	maxAge     time.Duration
+	entries    []spoolEntry
+	totalBytes int64
+	nextSeq    uint64
+	telemetry  *spoolTelemetry
+}
+
+func newDiskSpool(dir string, maxBytes int64, maxAge time.Duration) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create spool directory: %v", err)
+	}
+	s := &diskSpool{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		maxAge:    maxAge,
+		telemetry: &spoolTelemetry{},
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("could not load spool directory: %v", err)
+	}
+	return s, nil
+}
+
+// load indexes the payloads spooled by a previous run.
+func (s *diskSpool) load() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), spoolFileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), spoolFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{seq: seq, size: int(f.Size()), created: f.ModTime()})
+		s.totalBytes += f.Size()
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].seq < s.entries[j].seq })
+	s.evict(time.Now(), 0)
+	return nil
+}
+
+func (s *diskSpool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolFileSuffix))
+}
+
+func (s *diskSpool) empty() bool {
+	return len(s.entries) == 0
+}
+
+// evict removes the payloads older than maxAge, then the oldest payloads until incoming bytes fit in maxBytes.
+func (s *diskSpool) evict(now time.Time, incoming int) {
+	for len(s.entries) > 0 {
+		head := s.entries[0]
+		tooOld := s.maxAge > 0 && now.Sub(head.created) > s.maxAge
+		tooBig := s.totalBytes+int64(incoming) > s.maxBytes
+		if !tooOld && !tooBig {
+			return
+		}
+		s.remove()
+		atomic.AddUint64(&s.telemetry.totalBytesEvicted, uint64(head.size))
+	}
+}
+
+// remove deletes the oldest payload.
+func (s *diskSpool) remove() {
+	head := s.entries[0]
+	_ = os.Remove(s.path(head.seq))
+	s.entries = s.entries[1:]
+	s.totalBytes -= int64(head.size)
+}
+
+// push stores a payload at the end of the spool, evicting older payloads if needed.
+func (s *diskSpool) push(data []byte) error {
+	if int64(len(data)) > s.maxBytes {
+		atomic.AddUint64(&s.telemetry.totalBytesEvicted, uint64(len(data)))
+		return fmt.Errorf("payload of %d bytes is bigger than the spool", len(data))
+	}
+	now := time.Now()
+	s.evict(now, len(data))
+
+	seq := s.nextSeq
+	// Write to a temporary file first so a crash can't leave a partial payload to be replayed.
+	tmp := s.path(seq) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path(seq)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	s.nextSeq++
+	s.entries = append(s.entries, spoolEntry{seq: seq, size: len(data), created: now})
+	s.totalBytes += int64(len(data))
+	atomic.AddUint64(&s.telemetry.totalBytesSpooled, uint64(len(data)))
+	return nil
+}
+
+// replay writes the spooled payloads, oldest first, until the spool is empty or a write fails. It returns the first
+// write error.
+func (s *diskSpool) replay(write func([]byte) error) error {
+	s.evict(time.Now(), 0)
+	for len(s.entries) > 0 {
+		head := s.entries[0]
+		data, err := ioutil.ReadFile(s.path(head.seq))
+		if err != nil {
+			// The payload is lost, move on to the next one.
+			s.remove()
+			atomic.AddUint64(&s.telemetry.totalBytesEvicted, uint64(head.size))
+			continue
+		}
+		if err := write(data); err != nil {
+			return err
+		}
+		s.remove()
+		atomic.AddUint64(&s.telemetry.totalBytesReplayed, uint64(len(data)))
+	}
+	return nil
+}
+
+func (s *diskSpool) flushTelemetryMetrics(t *Telemetry) {
+	t.TotalBytesSpooled = atomic.LoadUint64(&s.telemetry.totalBytesSpooled)
+	t.TotalBytesReplayed = atomic.LoadUint64(&s.telemetry.totalBytesReplayed)
+	t.TotalBytesEvicted = atomic.LoadUint64(&s.telemetry.totalBytesEvicted)
+}
@@ -0,0 +1,209 @@
+package statsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSpoolDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dogstatsd-spool")
+	require.NoError(t, err)
+	return dir
+}
+
+func replayAll(t *testing.T, s *diskSpool) []string {
+	payloads := []string{}
+	require.NoError(t, s.replay(func(data []byte) error {
+		payloads = append(payloads, string(data))
+		return nil
+	}))
+	return payloads
+}
+
+func TestDiskSpoolReplayInOrder(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newDiskSpool(dir, 1024, 0)
+	require.NoError(t, err)
+	assert.True(t, s.empty())
+
+	require.NoError(t, s.push([]byte("a:1|c\n")))
+	require.NoError(t, s.push([]byte("b:2|c\n")))
+	assert.False(t, s.empty())
+
+	// a failing write stops the replay and keeps the payload
+	writeErr := fmt.Errorf("agent unreachable")
+	assert.Equal(t, writeErr, s.replay(func([]byte) error { return writeErr }))
+	assert.Len(t, s.entries, 2)
+
+	assert.Equal(t, []string{"a:1|c\n", "b:2|c\n"}, replayAll(t, s))
+	assert.True(t, s.empty())
+	assert.Equal(t, int64(0), s.totalBytes)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	tlm := Telemetry{}
+	s.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(12), tlm.TotalBytesSpooled)
+	assert.Equal(t, uint64(12), tlm.TotalBytesReplayed)
+	assert.Equal(t, uint64(0), tlm.TotalBytesEvicted)
+}
+
+func TestDiskSpoolEvictionBySize(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newDiskSpool(dir, 10, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, s.push([]byte("aaaa")))
+	require.NoError(t, s.push([]byte("bbbb")))
+	require.NoError(t, s.push([]byte("cccc")))
+	assert.Error(t, s.push([]byte("too big to be spooled")))
+
+	assert.Equal(t, []string{"bbbb", "cccc"}, replayAll(t, s))
+	assert.Equal(t, uint64(4+21), s.telemetry.totalBytesEvicted)
+}
+
+func TestDiskSpoolEvictionByAge(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newDiskSpool(dir, 1024, time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, s.push([]byte("old")))
+	require.NoError(t, s.push([]byte("new")))
+	s.entries[0].created = time.Now().Add(-2 * time.Minute)
+
+	assert.Equal(t, []string{"new"}, replayAll(t, s))
+	assert.Equal(t, uint64(3), s.telemetry.totalBytesEvicted)
+}
+
+func TestDiskSpoolLoadPreviousRun(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newDiskSpool(dir, 1024, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.push([]byte("first")))
+	require.NoError(t, s.push([]byte("second")))
+
+	// unrelated and partially written files are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0600))
+	require.NoError(t, ioutil.WriteFile(s.path(42)+".tmp", []byte("partial"), 0600))
+
+	s, err = newDiskSpool(dir, 1024, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), s.nextSeq)
+	require.NoError(t, s.push([]byte("third")))
+	assert.Equal(t, []string{"first", "second", "third"}, replayAll(t, s))
+}
+
+func TestDiskSpoolInvalidDirectory(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(file, []byte{}, 0600))
+
+	_, err := newDiskSpool(filepath.Join(file, "spool"), 1024, 0)
+	assert.Error(t, err)
+}
+
+type toggleWriter struct {
+	fail    bool
+	written []string
+}
+
+func (w *toggleWriter) Write(data []byte) (int, error) {
+	if w.fail {
+		return 0, fmt.Errorf("agent unreachable")
+	}
+	w.written = append(w.written, string(data))
+	return len(data), nil
+}
+
+func (w *toggleWriter) Close() error {
+	return nil
+}
+
+func (w *toggleWriter) GetTransportName() string {
+	return "toggle"
+}
+
+func TestSenderWithDiskSpool(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	spool, err := newDiskSpool(dir, 1024, 0)
+	require.NoError(t, err)
+
+	writer := &toggleWriter{fail: true}
+	pool := newBufferPool(10, 1024, 1)
+	sender := newSender(writer, 10, pool, nil)
+	sender.spool = spool
+	// close the sender to call write from the test goroutine
+	sender.close()
+
+	write := func(payload string) {
+		buffer := pool.borrowBuffer()
+		buffer.buffer = append(buffer.buffer, payload...)
+		sender.write(buffer)
+	}
+
+	write("a")
+	write("b")
+	assert.Empty(t, writer.written)
+
+	writer.fail = false
+	write("c")
+	assert.Equal(t, []string{"a", "b", "c"}, writer.written)
+
+	tlm := Telemetry{}
+	sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(0), tlm.TotalPayloadsDroppedWriter)
+	assert.Equal(t, uint64(3), tlm.TotalPayloadsSent)
+	assert.Equal(t, uint64(2), tlm.TotalBytesSpooled)
+	assert.Equal(t, uint64(2), tlm.TotalBytesReplayed)
+	assert.Equal(t, 10, len(pool.pool))
+}
+
+func TestClientReplaysDiskSpoolWithoutNewPayloads(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := &flakyWriter{failures: 1}
+	client, err := NewWithWriterEx(writer,
+		WithDiskSpool(dir, 1024, 0),
+		WithBufferFlushInterval(10*time.Millisecond),
+		WithoutClientSideAggregation(),
+		WithoutTelemetry(),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// the only payload fails and is spooled, it's replayed by a later buffer flush
+	require.NoError(t, client.Gauge("gauge", 1, nil, 1))
+	require.Eventually(t, func() bool {
+		writer.Lock()
+		defer writer.Unlock()
+		return len(writer.written) == 1
+	}, time.Second, time.Millisecond)
+
+	tlm := Telemetry{}
+	client.sender.flushTelemetryMetrics(&tlm)
+	assert.Equal(t, uint64(0), tlm.TotalPayloadsDroppedWriter)
+	assert.NotZero(t, tlm.TotalBytesSpooled)
+	assert.Equal(t, tlm.TotalBytesSpooled, tlm.TotalBytesReplayed)
+}
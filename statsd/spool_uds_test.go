@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package statsd
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskSpoolAgentRestartUDS(t *testing.T) {
+	dir := newTestSpoolDir(t)
+	defer os.RemoveAll(dir)
+	socketPath := fmt.Sprintf("/tmp/dsd_%d.socket", rand.Int())
+	defer os.Remove(socketPath)
+
+	client, err := New("unix://"+socketPath,
+		WithDiskSpool(dir, 1<<20, 0),
+		WithoutTelemetry(),
+		WithoutOriginDetection(),
+		WithoutClientSideAggregation(),
+		WithMaxMessagesPerPayload(1),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// the agent isn't listening yet
+	require.NoError(t, client.Incr("first", nil, 1))
+	require.NoError(t, client.Incr("second", nil, 1))
+	require.NoError(t, client.Flush())
+	assert.Equal(t, uint64(0), atomic.LoadUint64(&client.clientEx.sender.telemetry.totalPayloadsDroppedWriter))
+	assert.NotZero(t, atomic.LoadUint64(&client.clientEx.sender.spool.telemetry.totalBytesSpooled))
+
+	addr, err := net.ResolveUnixAddr("unixgram", socketPath)
+	require.NoError(t, err)
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, client.Incr("third", nil, 1))
+	require.NoError(t, client.Flush())
+
+	received := []string{}
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(received) < 3 {
+		n, err := conn.Read(buffer)
+		require.NoError(t, err)
+		received = append(received, strings.TrimSpace(string(buffer[:n])))
+	}
+	assert.Equal(t, []string{"first:1|c", "second:1|c", "third:1|c"}, received)
+}
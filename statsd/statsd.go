@@ -27,7 +27,7 @@ type ClientInterface interface {
 	// GaugeWithTimestamp measures the value of a metric at a given time.
 	// BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 	// The value will bypass any aggregation on the client side and agent side, this is
-	// useful when sending points in the past.
+	// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 	//
 	// Minimum Datadog Agent version: 7.40.0
 	GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time) error
@@ -38,7 +38,7 @@ type ClientInterface interface {
 	// CountWithTimestamp tracks how many times something happened at the given second.
 	// BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 	// The value will bypass any aggregation on the client side and agent side, this is
-	// useful when sending points in the past.
+	// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 	//
 	// Minimum Datadog Agent version: 7.40.0
 	CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time) error
@@ -105,6 +105,10 @@ var _ ClientInterface = &Client{}
 
 // New returns a pointer to a new Client given an addr in the format "hostname:port" for UDP,
 // "unix:///path/to/socket" for UDS or "\\.\pipe\path\to\pipe" for Windows Named Pipes.
+//
+// addr can also be a comma separated list of addresses, for example
+// "unix:///var/run/datadog/dsd.socket,udp://localhost:8125": the client uses the first one and fails over to the next
+// ones when it can't write to it (see WithTransportFailover).
 func New(addr string, options ...Option) (*Client, error) {
 	clientEx, err := NewEx(addr, options...)
 	if err != nil {
@@ -182,9 +186,9 @@ func (c *Client) Gauge(name string, value float64, tags []string, rate float64)
 // GaugeWithTimestamp measures the value of a metric at a given time.
 // BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 // The value will bypass any aggregation on the client side and agent side, this is
-// useful when sending points in the past.
+// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 //
-// Minimum Datadog Agent version: 7.40.0
+// Minimum Datadog Agent version: 7.40.0, the timestamp is omitted for older versions set with WithAgentVersion.
 func (c *Client) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time) error {
 	if c == nil {
 		return ErrNoClient
@@ -203,9 +207,9 @@ func (c *Client) Count(name string, value int64, tags []string, rate float64) er
 // CountWithTimestamp tracks how many times something happened at the given second.
 // BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 // The value will bypass any aggregation on the client side and agent side, this is
-// useful when sending points in the past.
+// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 //
-// Minimum Datadog Agent version: 7.40.0
+// Minimum Datadog Agent version: 7.40.0, the timestamp is omitted for older versions set with WithAgentVersion.
 func (c *Client) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time) error {
 	if c == nil {
 		return ErrNoClient
@@ -213,6 +217,17 @@ func (c *Client) CountWithTimestamp(name string, value int64, tags []string, rat
 	return c.clientEx.CountWithTimestamp(name, value, tags, rate, timestamp)
 }
 
+// MonotonicCount tracks a cumulative total, such as a counter of the runtime or of /proc: the client remembers the
+// last total of each context and sends the increase since the previous call as a Count. Nothing is sent for the first
+// total of a context. A total lower than the previous one is considered a reset of the counter, which is then counted
+// from zero. The totals are kept for the lifetime of the client.
+func (c *Client) MonotonicCount(name string, total int64, tags []string) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	return c.clientEx.MonotonicCount(name, total, tags)
+}
+
 // Histogram tracks the statistical distribution of a set of values on each host.
 func (c *Client) Histogram(name string, value float64, tags []string, rate float64) error {
 	if c == nil {
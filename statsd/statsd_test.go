@@ -299,10 +299,19 @@ func TestResolveAddressFromEnvironment(t *testing.T) {
 		{"UPD Host env, default port", "", "10.12.16.9", "", "", "10.12.16.9:8125"},
 		{"UPD Host passed, ignore env port", "10.12.16.9", "", "1234", "", "10.12.16.9:8125"},
 
+		{"IPv6 passed", "[::1]:1234", "", "", "", "[::1]:1234"},
+		{"IPv6 passed, default port", "::1", "", "", "", "[::1]:8125"},
+		{"IPv6 bracketed passed, default port", "[2001:db8::1]", "", "", "", "[2001:db8::1]:8125"},
+		{"IPv6 host env with port", "", "2001:db8::1", "1234", "", "[2001:db8::1]:1234"},
+		{"IPv6 host env, default port", "", "fe80::1", "", "", "[fe80::1]:8125"},
+
 		{"UDS socket passed", "unix://test/path.socket", "", "", "", "unix://test/path.socket"},
 		{"UDS socket env", "", "unix://test/path.socket", "", "", "unix://test/path.socket"},
 		{"UDS socket env with port", "", "unix://test/path.socket", "8125", "", "unix://test/path.socket"},
 
+		{"TCP passed", "tcp://localhost:1234", "", "", "", "tcp://localhost:1234"},
+		{"TCP env", "", "tcp://localhost:1234", "", "", "tcp://localhost:1234"},
+
 		{"Pipe passed", "\\\\.\\pipe\\my_pipe", "", "", "", "\\\\.\\pipe\\my_pipe"},
 		{"Pipe env", "", "\\\\.\\pipe\\my_pipe", "", "", "\\\\.\\pipe\\my_pipe"},
 		{"Pipe env with port", "", "\\\\.\\pipe\\my_pipe", "8125", "", "\\\\.\\pipe\\my_pipe"},
@@ -312,8 +321,18 @@ func TestResolveAddressFromEnvironment(t *testing.T) {
 		{"DD_DOGSTATSD_URL UDS", "", "", "", "unix://test/path.socket", "unix://test/path.socket"},
 		{"DD_DOGSTATSD_URL UDS, ignore env port", "", "", "1234", "udp://198.51.100.123:4321", "198.51.100.123:4321"},
 		{"DD_DOGSTATSD_URL UDS, ignore env host", "", "localhost", "", "udp://198.51.100.123:4321", "198.51.100.123:4321"},
+		{"DD_DOGSTATSD_URL TCP", "", "", "", "tcp://localhost:1234", "tcp://localhost:1234"},
+		{"DD_DOGSTATSD_URL TCP, default port", "", "", "", "tcp://localhost", "tcp://localhost:8125"},
 		{"DD_DOGSTATSD_URL Pipe", "", "", "", "\\\\.\\pipe\\my_pipe", "\\\\.\\pipe\\my_pipe"},
+		{"DD_DOGSTATSD_URL UDP IPv6", "", "", "", "udp://[::1]:1234", "[::1]:1234"},
+		{"DD_DOGSTATSD_URL UDP IPv6, default port", "", "", "", "udp://[::1]", "[::1]:8125"},
+		{"DD_DOGSTATSD_URL TCP IPv6, default port", "", "", "", "tcp://[2001:db8::1]", "tcp://[2001:db8::1]:8125"},
 		{"DD_DOGSTATSD_URL with no valid scheme", "", "", "", "localhost:1234", ""},
+		{"DD_DOGSTATSD_URL list", "", "", "", "unix:///test/path.socket,udp://localhost", "unix:///test/path.socket,localhost:8125"},
+		{"DD_DOGSTATSD_URL list with invalid URL", "", "", "", "unix:///test/path.socket,localhost:1234", ""},
+
+		{"List passed", "unix:///test/path.socket, localhost", "", "", "", "unix:///test/path.socket,localhost:8125"},
+		{"List passed with URL", "tcp://localhost:1234,udp://localhost:4321", "", "", "", "tcp://localhost:1234,localhost:4321"},
 
 		{"No autodetection failed", "", "", "", "", ""},
 	} {
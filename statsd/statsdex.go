@@ -13,9 +13,11 @@ package statsd
 //go:generate mockgen -source=statsd.go -destination=mocks/statsd.go
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
@@ -73,6 +75,12 @@ stream traffic instead of UDP.
 */
 const UnixAddressStreamPrefix = "unixstream://"
 
+/*
+TCPAddressPrefix holds the prefix to use to enable TCP traffic instead of UDP.
+Payloads are framed as on Unix Domain Socket streams.
+*/
+const TCPAddressPrefix = "tcp://"
+
 /*
 WindowsPipeAddressPrefix holds the prefix to use to enable Windows Named Pipes
 traffic instead of UDP.
@@ -80,7 +88,7 @@ traffic instead of UDP.
 const WindowsPipeAddressPrefix = `\\.\pipe\`
 
 var (
-	AddressPrefixes = []string{UnixAddressPrefix, UnixAddressDatagramPrefix, UnixAddressStreamPrefix, WindowsPipeAddressPrefix}
+	AddressPrefixes = []string{UnixAddressPrefix, UnixAddressDatagramPrefix, UnixAddressStreamPrefix, TCPAddressPrefix, WindowsPipeAddressPrefix}
 )
 
 const (
@@ -88,6 +96,8 @@ const (
 	agentPortEnvVarName = "DD_DOGSTATSD_PORT"
 	agentURLEnvVarName  = "DD_DOGSTATSD_URL"
 	defaultUDPPort      = "8125"
+	// addressListSeparator separates the addresses of a list, the client fails over from one to the next.
+	addressListSeparator = ","
 )
 
 const (
@@ -142,6 +152,7 @@ const (
 	writerNameUDP       string = "udp"
 	writerNameUDS       string = "uds"
 	writerNameUDSStream string = "uds-stream"
+	writerNameTCP       string = "tcp"
 	writerWindowsPipe   string = "pipe"
 	writerNameCustom    string = "custom"
 )
@@ -166,6 +177,8 @@ type metric struct {
 	timestamp       int64
 	originDetection bool
 	cardinality     Cardinality
+	// ctx is the context given to the Ctx methods, the wait for room in the sender queue ends when it is done.
+	ctx context.Context
 }
 
 type noClientErr string
@@ -199,7 +212,7 @@ type ClientInterfaceEx interface {
 	// GaugeWithTimestamp measures the value of a metric at a given time.
 	// BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 	// The value will bypass any aggregation on the client side and agent side, this is
-	// useful when sending points in the past.
+	// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 	//
 	// Minimum Datadog Agent version: 7.40.0
 	GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error
@@ -210,11 +223,16 @@ type ClientInterfaceEx interface {
 	// CountWithTimestamp tracks how many times something happened at the given second.
 	// BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 	// The value will bypass any aggregation on the client side and agent side, this is
-	// useful when sending points in the past.
+	// useful when sending points in the past. See WithTimestampAggregation to aggregate them on the client side.
 	//
 	// Minimum Datadog Agent version: 7.40.0
 	CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error
 
+	// MonotonicCount tracks a cumulative total, such as a counter of the runtime or of /proc, by sending its increase
+	// since the previous call for the same context as a Count. Nothing is sent for the first total of a context, and a
+	// total lower than the previous one is considered a reset of the counter.
+	MonotonicCount(name string, total int64, tags []string, parameters ...Parameter) error
+
 	// Histogram tracks the statistical distribution of a set of values on each host.
 	Histogram(name string, value float64, tags []string, rate float64, parameters ...Parameter) error
 
@@ -301,6 +319,7 @@ type ClientEx struct {
 	errorHandler          ErrorHandler
 	originDetection       bool
 	defaultCardinality    Cardinality
+	monotonicCounts       monotonicCounts
 }
 
 // statsdTelemetry contains telemetry metrics about the client
@@ -321,6 +340,16 @@ type statsdTelemetry struct {
 var _ ClientInterfaceEx = &ClientEx{}
 
 func resolveAddr(addr string) string {
+	if strings.Contains(addr, addressListSeparator) {
+		return resolveAddrList(addr, func(addr string) string {
+			// URLs are accepted in lists to ease copying them from DD_DOGSTATSD_URL.
+			if strings.HasPrefix(addr, "udp://") {
+				return parseAgentURL(addr)
+			}
+			return resolveAddr(addr)
+		})
+	}
+
 	envPort := ""
 
 	if addr == "" {
@@ -344,19 +373,46 @@ func resolveAddr(addr string) string {
 			return addr
 		}
 	}
-	// TODO: How does this work for IPv6?
-	if strings.Contains(addr, ":") {
+	if envPort != "" {
+		return withDefaultPort(addr, envPort)
+	}
+	return withDefaultPort(addr, defaultUDPPort)
+}
+
+// withDefaultPort adds port to addr if it has none. IPv6 hosts must be bracketed when a port is given, like
+// "[::1]:8125", unbracketed IPv6 hosts like "::1" are considered to have no port.
+func withDefaultPort(addr string, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
 		return addr
 	}
-	if envPort != "" {
-		addr = fmt.Sprintf("%s:%s", addr, envPort)
-	} else {
-		addr = fmt.Sprintf("%s:%s", addr, defaultUDPPort)
+	host := addr
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	} else if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		// not a host we know how to add a port to, let the writer report the error
+		return addr
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// resolveAddrList resolves each address of a list with resolve. It returns an empty string if one of them can't be
+// resolved.
+func resolveAddrList(addrs string, resolve func(string) string) string {
+	resolved := []string{}
+	for _, addr := range strings.Split(addrs, addressListSeparator) {
+		addr = resolve(strings.TrimSpace(addr))
+		if addr == "" {
+			return ""
+		}
+		resolved = append(resolved, addr)
 	}
-	return addr
+	return strings.Join(resolved, addressListSeparator)
 }
 
 func parseAgentURL(agentURL string) string {
+	if strings.Contains(agentURL, addressListSeparator) {
+		return resolveAddrList(agentURL, parseAgentURL)
+	}
 	if agentURL != "" {
 		if strings.HasPrefix(agentURL, WindowsPipeAddressPrefix) {
 			return agentURL
@@ -368,20 +424,21 @@ func parseAgentURL(agentURL string) string {
 		}
 
 		if parsedURL.Scheme == "udp" {
-			if strings.Contains(parsedURL.Host, ":") {
-				return parsedURL.Host
-			}
-			return fmt.Sprintf("%s:%s", parsedURL.Host, defaultUDPPort)
+			return withDefaultPort(parsedURL.Host, defaultUDPPort)
 		}
 
 		if parsedURL.Scheme == "unix" {
 			return agentURL
 		}
+
+		if parsedURL.Scheme == "tcp" {
+			return TCPAddressPrefix + withDefaultPort(parsedURL.Host, defaultUDPPort)
+		}
 	}
 	return ""
 }
 
-func createWriter(addr string, writeTimeout time.Duration, connectTimeout time.Duration) (Transport, string, error) {
+func createWriter(addr string, writeTimeout time.Duration, connectTimeout time.Duration, resolveInterval time.Duration) (Transport, string, error) {
 	if addr == "" {
 		return nil, "", errors.New("No address passed and autodetection from environment failed")
 	}
@@ -399,14 +456,102 @@ func createWriter(addr string, writeTimeout time.Duration, connectTimeout time.D
 	case strings.HasPrefix(addr, UnixAddressStreamPrefix):
 		w, err := newUDSWriter(addr[len(UnixAddressStreamPrefix):], writeTimeout, connectTimeout, "unix")
 		return w, writerNameUDS, err
+	case strings.HasPrefix(addr, TCPAddressPrefix):
+		w, err := newTCPWriter(addr[len(TCPAddressPrefix):], writeTimeout, connectTimeout)
+		return w, writerNameTCP, err
 	default:
-		w, err := newUDPWriter(addr, writeTimeout)
+		w, err := newUDPWriter(addr, writeTimeout, resolveInterval)
 		return w, writerNameUDP, err
 	}
 }
 
+// createCompressedWriter creates the transport for addr, compressing payloads when WithCompression is used and addr is
+// a stream address.
+func createCompressedWriter(addr string, o *Options) (Transport, string, error) {
+	w, writerName, err := createWriter(addr, o.writeTimeout, o.connectTimeout, o.dnsRefreshInterval)
+	if err != nil || o.compression == nil {
+		return w, writerName, err
+	}
+	if !strings.HasPrefix(addr, UnixAddressStreamPrefix) && !strings.HasPrefix(addr, TCPAddressPrefix) {
+		return w, writerName, nil
+	}
+	compressed, err := newCompressedWriter(w, o.compression)
+	if err != nil {
+		w.Close()
+		return nil, "", err
+	}
+	return compressed, writerName, nil
+}
+
+// createFailoverWriter creates the transport for addr, which can be a list of addresses separated by commas. For a
+// list, it returns a failoverWriter and the writer name used to pick the client defaults: the UDS defaults are only
+// kept when every address uses them.
+func createFailoverWriter(addr string, o *Options) (Transport, string, error) {
+	if !strings.Contains(addr, addressListSeparator) {
+		return createCompressedWriter(addr, o)
+	}
+
+	transports := []Transport{}
+	writerName := ""
+	for _, a := range strings.Split(addr, addressListSeparator) {
+		w, name, err := createCompressedWriter(a, o)
+		if err != nil {
+			for _, t := range transports {
+				t.Close()
+			}
+			return nil, "", fmt.Errorf("could not create transport for %s: %v", a, err)
+		}
+		transports = append(transports, w)
+		if writerName == "" || !isStreamWriter(name) {
+			writerName = name
+		}
+	}
+	return newFailoverWriter(transports, o.failoverThreshold, o.failoverProbeInterval), writerName, nil
+}
+
+// isStreamWriter returns true for the transports using the UDS defaults.
+func isStreamWriter(writerName string) bool {
+	// TCP is a stream transport like UDS streams: it uses the same defaults.
+	return writerName == writerNameUDS || writerName == writerNameTCP
+}
+
+// createFanOutWriter wraps w, named name, into a fanOutWriter when destinations were added with WithFanOut or
+// WithFanOutWriter. It returns the writer name used to pick the client defaults: the UDS defaults are only kept when
+// every destination uses them.
+func createFanOutWriter(w Transport, name string, writerName string, o *Options) (Transport, string, error) {
+	if len(o.fanOutAddrs) == 0 && len(o.fanOutWriters) == 0 {
+		return w, writerName, nil
+	}
+
+	fanOut := newFanOutWriter(o.errorHandler)
+	fanOut.addDestination(name, w)
+	for _, addr := range o.fanOutAddrs {
+		addr = resolveAddr(addr)
+		destination, destinationName, err := createFailoverWriter(addr, o)
+		if err != nil {
+			for _, d := range fanOut.destinations[1:] {
+				d.transport.Close()
+			}
+			return nil, "", fmt.Errorf("could not create fan-out destination %s: %v", addr, err)
+		}
+		fanOut.addDestination(addr, destination)
+		if !isStreamWriter(destinationName) {
+			writerName = destinationName
+		}
+	}
+	for _, writer := range o.fanOutWriters {
+		fanOut.addDestination(fmt.Sprintf("%s-%d", writerNameCustom, len(fanOut.destinations)), &customWriter{writer})
+		writerName = writerNameCustom
+	}
+	return fanOut, writerName, nil
+}
+
 // New returns a pointer to a new Client given an addr in the format "hostname:port" for UDP,
 // "unix:///path/to/socket" for UDS or "\\.\pipe\path\to\pipe" for Windows Named Pipes.
+//
+// addr can also be a comma separated list of addresses, for example
+// "unix:///var/run/datadog/dsd.socket,udp://localhost:8125": the client uses the first one and fails over to the next
+// ones when it can't write to it (see WithTransportFailover).
 func NewEx(addr string, options ...Option) (*ClientEx, error) {
 	o, err := resolveOptions(options)
 	if err != nil {
@@ -414,7 +559,11 @@ func NewEx(addr string, options ...Option) (*ClientEx, error) {
 	}
 
 	addr = resolveAddr(addr)
-	w, writerType, err := createWriter(addr, o.writeTimeout, o.connectTimeout)
+	w, writerType, err := createFailoverWriter(addr, o)
+	if err != nil {
+		return nil, err
+	}
+	w, writerType, err = createFanOutWriter(w, addr, writerType, o)
 	if err != nil {
 		return nil, err
 	}
@@ -442,7 +591,11 @@ func NewWithWriterEx(w io.WriteCloser, options ...Option) (*ClientEx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newWithWriter(&customWriter{w}, o, writerNameCustom)
+	transport, writerType, err := createFanOutWriter(&customWriter{w}, writerNameCustom, writerNameCustom, o)
+	if err != nil {
+		return nil, err
+	}
+	return newWithWriter(transport, o, writerType)
 }
 
 // CloneWithExtraOptions create a new ClientEx with extra options
@@ -469,25 +622,15 @@ func newWithWriter(w Transport, o *Options, writerName string) (*ClientEx, error
 	}
 
 	// Inject values of DD_* environment variables as global tags.
-	for _, mapping := range ddEnvTagsMapping {
-		if value := os.Getenv(mapping.envName); value != "" {
-			c.tags = append(c.tags, fmt.Sprintf("%s:%s", mapping.tagName, value))
-		}
-	}
+	c.tags = appendEnvTags(c.tags)
 	// Whether origin detection is enabled or not for this client, we need to initialize the global
 	// external environment variable in case another client has enabled it and needs to access it.
 	initExternalEnv()
 
-	if o.tagCardinality != nil {
-		c.defaultCardinality = *o.tagCardinality
-	} else if card, ok := envTagCardinality(); ok {
-		c.defaultCardinality = card
-	} else {
-		c.defaultCardinality = CardinalityNotSet
-	}
+	c.defaultCardinality = resolveDefaultCardinality(o)
 
 	initContainerID(o.containerID, fillInContainerID(o), isHostCgroupNamespace())
-	isUDS := writerName == writerNameUDS
+	isUDS := isStreamWriter(writerName)
 
 	if o.maxBytesPerPayload == 0 {
 		if isUDS {
@@ -511,8 +654,28 @@ func newWithWriter(w Transport, o *Options, writerName string) (*ClientEx, error
 		}
 	}
 
+	var spool *diskSpool
+	if o.spoolDir != "" {
+		var err error
+		spool, err = newDiskSpool(o.spoolDir, o.spoolMaxBytes, o.spoolMaxAge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The agent can't parse several values per message without value packing.
+	extendedAggregation := o.extendedAggregation && o.agentFeatures.has(agentFeatureValuePacking)
+
 	bufferPool := newBufferPool(o.bufferPoolSize, o.maxBytesPerPayload, o.maxMessagesPerPayload)
+	bufferPool.features = o.agentFeatures
 	c.sender = newSender(w, o.senderQueueSize, bufferPool, o.errorHandler)
+	c.sender.blockingTimeout = o.senderBlockingTimeout
+	c.sender.spool = spool
+	c.sender.compression = o.compression
+	if o.retryPolicy != nil {
+		c.sender.retryPolicy = o.retryPolicy
+		c.sender.random = newRetryRandom()
+	}
 	c.aggregatorMode = o.receiveMode
 
 	c.workersMode = o.receiveMode
@@ -520,15 +683,15 @@ func newWithWriter(w Transport, o *Options, writerName string) (*ClientEx, error
 	// ExtendedAggregation is since the user app will not directly
 	// use the worker (the aggregator sit between the app and the
 	// workers).
-	if o.extendedAggregation {
+	if extendedAggregation {
 		c.workersMode = mutexMode
 	}
 
-	if o.aggregation || o.extendedAggregation || o.maxBufferedSamplesPerContext > 0 {
-		c.agg = newAggregator(&c, int64(o.maxBufferedSamplesPerContext), o.aggregatorShardCount)
+	if o.aggregation || extendedAggregation || o.maxBufferedSamplesPerContext > 0 {
+		c.agg = newAggregatorWithOptions(&c, o)
 		c.agg.start(o.aggregationFlushInterval)
 
-		if o.extendedAggregation {
+		if extendedAggregation {
 			c.aggExtended = c.agg
 
 			if c.aggregatorMode == channelMode {
@@ -560,7 +723,7 @@ func newWithWriter(w Transport, o *Options, writerName string) (*ClientEx, error
 			c.telemetryClient = newTelemetryClient(&c, c.agg != nil)
 		} else {
 			var err error
-			c.telemetryClient, err = newTelemetryClientWithCustomAddr(&c, o.telemetryAddr, c.agg != nil, bufferPool, o.writeTimeout, o.connectTimeout)
+			c.telemetryClient, err = newTelemetryClientWithCustomAddr(&c, o.telemetryAddr, c.agg != nil, bufferPool, o.writeTimeout, o.connectTimeout, o.dnsRefreshInterval)
 			if err != nil {
 				return nil, err
 			}
@@ -571,6 +734,27 @@ func newWithWriter(w Transport, o *Options, writerName string) (*ClientEx, error
 	return &c, nil
 }
 
+// appendEnvTags appends the values of the DD_* environment variables to the global tags.
+func appendEnvTags(tags []string) []string {
+	for _, mapping := range ddEnvTagsMapping {
+		if value := os.Getenv(mapping.envName); value != "" {
+			tags = append(tags, fmt.Sprintf("%s:%s", mapping.tagName, value))
+		}
+	}
+	return tags
+}
+
+// resolveDefaultCardinality returns the tag cardinality from the options, falling back on the environment.
+func resolveDefaultCardinality(o *Options) Cardinality {
+	if o.tagCardinality != nil {
+		return *o.tagCardinality
+	}
+	if card, ok := envTagCardinality(); ok {
+		return card
+	}
+	return CardinalityNotSet
+}
+
 func (c *ClientEx) watch() {
 	ticker := time.NewTicker(c.flushTime)
 
@@ -580,6 +764,7 @@ func (c *ClientEx) watch() {
 			for _, w := range c.workers {
 				w.flush()
 			}
+			c.sender.replaySpool()
 		case <-c.stop:
 			ticker.Stop()
 			return
@@ -641,6 +826,29 @@ func (c *ClientEx) GetTransport() string {
 	return c.sender.getTransportName()
 }
 
+// newAggregatorWithOptions returns an aggregator configured with the aggregation options.
+func newAggregatorWithOptions(c *ClientEx, o *Options) *aggregator {
+	agg := newAggregator(c, int64(o.maxBufferedSamplesPerContext), o.aggregatorShardCount)
+	if o.maxContexts > 0 || o.maxContextsPerName > 0 {
+		agg.limitContexts(newContextLimiter(o.maxContexts, o.maxContextsPerName, o.errorHandler))
+	}
+	if o.extendedAggregation {
+		agg.histograms.summary = o.histogramSummary
+		agg.timings.summary = o.histogramSummary
+		agg.distributions.sketch = o.distributionSketch
+	}
+	if o.timestampAggregation {
+		agg.timestamped = newTimestampedContexts(o.timestampMaxAge)
+	}
+	agg.setEstimationThreshold = o.setEstimationThreshold
+	agg.flushObserver = o.flushObserver
+	agg.flushInterval = o.aggregationFlushInterval
+	agg.alignFlushes = o.alignedAggregationFlush
+	agg.windowTimestamps = o.aggregationWindowTimestamp
+	agg.gaugeAggregation = o.gaugeAggregation
+	return agg
+}
+
 type ErrorInputChannelFull struct {
 	Metric      metric
 	ChannelSize int
@@ -656,6 +864,8 @@ func (c *ClientEx) send(m metric) error {
 	worker := c.workers[h%uint32(len(c.workers))]
 
 	if c.workersMode == channelMode {
+		// the caller doesn't wait for the worker in channel mode
+		m.ctx = nil
 		select {
 		case worker.inputMetrics <- m:
 		default:
@@ -704,6 +914,7 @@ func (c *ClientEx) sendToAggregator(mType metricType, name string, value float64
 }
 
 // Gauge measures the value of a metric at a particular time.
+// With client side aggregation a GaugeAggregation parameter sets how the values of the interval are aggregated.
 func (c *ClientEx) Gauge(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
 	if c == nil {
 		return ErrNoClient
@@ -711,17 +922,17 @@ func (c *ClientEx) Gauge(name string, value float64, tags []string, rate float64
 	atomic.AddUint64(&c.telemetry.totalMetricsGauge, 1)
 	cardinality := parameterCardinality(parameters, c.defaultCardinality)
 	if c.agg != nil {
-		return c.agg.gauge(name, value, tags, cardinality)
+		return c.agg.gaugeWithAggregation(name, value, tags, cardinality, parameterGaugeAggregation(parameters, c.agg.gaugeAggregation))
 	}
-	return c.send(metric{metricType: gauge, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: gauge, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // GaugeWithTimestamp measures the value of a metric at a given time.
 // BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 // The value will bypass any aggregation on the client side and agent side, this is
-// useful when sending points in the past.
+// useful when sending points in the past. With WithTimestampAggregation the last value of each second is kept.
 //
-// Minimum Datadog Agent version: 7.40.0
+// Minimum Datadog Agent version: 7.40.0, the timestamp is omitted for older versions set with WithAgentVersion.
 func (c *ClientEx) GaugeWithTimestamp(name string, value float64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
 	if c == nil {
 		return ErrNoClient
@@ -733,6 +944,9 @@ func (c *ClientEx) GaugeWithTimestamp(name string, value float64, tags []string,
 
 	atomic.AddUint64(&c.telemetry.totalMetricsGauge, 1)
 	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil && c.agg.timestamped != nil {
+		return c.agg.gaugeWithTimestamp(name, value, tags, timestamp.Unix(), cardinality)
+	}
 	return c.send(metric{metricType: gauge, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, timestamp: timestamp.Unix(), originDetection: c.originDetection, cardinality: cardinality})
 }
 
@@ -746,15 +960,15 @@ func (c *ClientEx) Count(name string, value int64, tags []string, rate float64,
 	if c.agg != nil {
 		return c.agg.count(name, value, tags, cardinality)
 	}
-	return c.send(metric{metricType: count, name: name, ivalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: count, name: name, ivalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // CountWithTimestamp tracks how many times something happened at the given second.
 // BETA - Please contact our support team for more information to use this feature: https://www.datadoghq.com/support/
 // The value will bypass any aggregation on the client side and agent side, this is
-// useful when sending points in the past.
+// useful when sending points in the past. With WithTimestampAggregation the values of each second are summed.
 //
-// Minimum Datadog Agent version: 7.40.0
+// Minimum Datadog Agent version: 7.40.0, the timestamp is omitted for older versions set with WithAgentVersion.
 func (c *ClientEx) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time, parameters ...Parameter) error {
 	if c == nil {
 		return ErrNoClient
@@ -766,9 +980,27 @@ func (c *ClientEx) CountWithTimestamp(name string, value int64, tags []string, r
 
 	atomic.AddUint64(&c.telemetry.totalMetricsCount, 1)
 	cardinality := parameterCardinality(parameters, c.defaultCardinality)
+	if c.agg != nil && c.agg.timestamped != nil {
+		return c.agg.countWithTimestamp(name, value, tags, timestamp.Unix(), cardinality)
+	}
 	return c.send(metric{metricType: count, name: name, ivalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, timestamp: timestamp.Unix(), originDetection: c.originDetection, cardinality: cardinality})
 }
 
+// MonotonicCount tracks a cumulative total, such as a counter of the runtime or of /proc: the client remembers the
+// last total of each context and sends the increase since the previous call as a Count. Nothing is sent for the first
+// total of a context. A total lower than the previous one is considered a reset of the counter, which is then counted
+// from zero. The totals are kept for the lifetime of the client.
+func (c *ClientEx) MonotonicCount(name string, total int64, tags []string, parameters ...Parameter) error {
+	if c == nil {
+		return ErrNoClient
+	}
+	delta, ok := c.monotonicCounts.delta(name, tags, parameterCardinality(parameters, c.defaultCardinality), total)
+	if !ok {
+		return nil
+	}
+	return c.Count(name, delta, tags, 1, parameters...)
+}
+
 // Histogram tracks the statistical distribution of a set of values on each host.
 func (c *ClientEx) Histogram(name string, value float64, tags []string, rate float64, parameters ...Parameter) error {
 	if c == nil {
@@ -779,7 +1011,7 @@ func (c *ClientEx) Histogram(name string, value float64, tags []string, rate flo
 	if c.aggExtended != nil {
 		return c.sendToAggregator(histogram, name, value, tags, rate, c.aggExtended.histogram, cardinality)
 	}
-	return c.send(metric{metricType: histogram, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: histogram, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // Distribution tracks the statistical distribution of a set of values across your infrastructure.
@@ -792,7 +1024,7 @@ func (c *ClientEx) Distribution(name string, value float64, tags []string, rate
 	if c.aggExtended != nil {
 		return c.sendToAggregator(distribution, name, value, tags, rate, c.aggExtended.distribution, cardinality)
 	}
-	return c.send(metric{metricType: distribution, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: distribution, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // Decr is just Count of -1
@@ -815,7 +1047,7 @@ func (c *ClientEx) Set(name string, value string, tags []string, rate float64, p
 	if c.agg != nil {
 		return c.agg.set(name, value, tags, cardinality)
 	}
-	return c.send(metric{metricType: set, name: name, svalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: set, name: name, svalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // Timing sends timing information, it is an alias for TimeInMilliseconds
@@ -834,7 +1066,7 @@ func (c *ClientEx) TimeInMilliseconds(name string, value float64, tags []string,
 	if c.aggExtended != nil {
 		return c.sendToAggregator(timing, name, value, tags, rate, c.aggExtended.timing, cardinality)
 	}
-	return c.send(metric{metricType: timing, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality})
+	return c.send(metric{metricType: timing, name: name, fvalue: value, tags: tags, rate: rate, globalTags: c.tags, namespace: c.namespace, originDetection: c.originDetection, cardinality: cardinality, ctx: parameterContext(parameters)})
 }
 
 // Event sends the provided Event.
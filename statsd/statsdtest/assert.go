@@ -0,0 +1,210 @@
+package statsdtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TestingT is the subset of testing.TB used by the assertion helpers.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type tHelper interface {
+	Helper()
+}
+
+func helper(t TestingT) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+}
+
+// AssertLines asserts that exactly the expected lines were received, in any order.
+func (s *Server) AssertLines(t TestingT, expected ...string) bool {
+	helper(t)
+
+	received := s.Lines()
+	expected = append([]string{}, expected...)
+	sort.Strings(received)
+	sort.Strings(expected)
+
+	if strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected lines received:\nexpected:\n\t%s\nreceived:\n\t%s",
+			strings.Join(expected, "\n\t"), strings.Join(received, "\n\t"))
+		return false
+	}
+	return true
+}
+
+// AssertCount asserts that the sum of the counts with the given name and tags is the expected value. Each count
+// value is divided by its sample rate like the Agent does.
+func (s *Server) AssertCount(t TestingT, name string, expected float64, tags ...string) bool {
+	helper(t)
+
+	metrics, ok := s.findMetricsOfType(t, name, Count, tags)
+	if !ok {
+		return false
+	}
+
+	sum := 0.0
+	for _, m := range metrics {
+		for _, v := range m.Values {
+			sum += v / m.Rate
+		}
+	}
+	if sum != expected {
+		t.Errorf("count %s%s: expected %v but got %v", name, formatTags(tags), expected, sum)
+		return false
+	}
+	return true
+}
+
+// AssertGauge asserts that the last gauge received with the given name and tags has the expected value.
+func (s *Server) AssertGauge(t TestingT, name string, expected float64, tags ...string) bool {
+	helper(t)
+
+	metrics, ok := s.findMetricsOfType(t, name, Gauge, tags)
+	if !ok {
+		return false
+	}
+
+	last := metrics[len(metrics)-1]
+	if value := last.Values[len(last.Values)-1]; value != expected {
+		t.Errorf("gauge %s%s: expected %v but got %v", name, formatTags(tags), expected, value)
+		return false
+	}
+	return true
+}
+
+// AssertSamples asserts that the histogram, distribution or timing with the given name and tags received exactly the
+// expected values, in order.
+func (s *Server) AssertSamples(t TestingT, name string, expected []float64, tags ...string) bool {
+	helper(t)
+
+	metrics := s.FindMetrics(name, tags...)
+	values := []float64{}
+	for _, m := range metrics {
+		if m.Type != Histogram && m.Type != Distribution && m.Type != Timing {
+			t.Errorf("metric %s%s: expected a histogram, distribution or timing but got type %q", name, formatTags(tags), m.Type)
+			return false
+		}
+		values = append(values, m.Values...)
+	}
+
+	if fmt.Sprint(values) != fmt.Sprint(expected) {
+		t.Errorf("samples %s%s: expected %v but got %v", name, formatTags(tags), expected, values)
+		return false
+	}
+	return true
+}
+
+// AssertSet asserts that the set with the given name and tags received exactly the expected unique values, in any
+// order.
+func (s *Server) AssertSet(t TestingT, name string, expected []string, tags ...string) bool {
+	helper(t)
+
+	metrics, ok := s.findMetricsOfType(t, name, Set, tags)
+	if !ok {
+		return false
+	}
+
+	unique := map[string]struct{}{}
+	for _, m := range metrics {
+		unique[m.StringValue] = struct{}{}
+	}
+	values := make([]string, 0, len(unique))
+	for v := range unique {
+		values = append(values, v)
+	}
+	expected = append([]string{}, expected...)
+	sort.Strings(values)
+	sort.Strings(expected)
+
+	if strings.Join(values, ",") != strings.Join(expected, ",") {
+		t.Errorf("set %s%s: expected %v but got %v", name, formatTags(tags), expected, values)
+		return false
+	}
+	return true
+}
+
+// AssertNoMetric asserts that no metric with the given name and tags was received.
+func (s *Server) AssertNoMetric(t TestingT, name string, tags ...string) bool {
+	helper(t)
+
+	if metrics := s.FindMetrics(name, tags...); len(metrics) != 0 {
+		t.Errorf("metric %s%s: expected nothing but received %d lines", name, formatTags(tags), len(metrics))
+		return false
+	}
+	return true
+}
+
+// AssertEvent asserts that an event with the given title and tags was received.
+func (s *Server) AssertEvent(t TestingT, title string, tags ...string) bool {
+	helper(t)
+
+	for _, e := range s.Events() {
+		if e.Title == title && e.HasTags(tags...) {
+			return true
+		}
+	}
+	t.Errorf("event %q%s: not received", title, formatTags(tags))
+	return false
+}
+
+// AssertServiceCheck asserts that the last service check received with the given name and tags has the expected
+// status.
+func (s *Server) AssertServiceCheck(t TestingT, name string, status int, tags ...string) bool {
+	helper(t)
+
+	var last *ServiceCheck
+	for _, sc := range s.ServiceChecks() {
+		if sc.Name == name && sc.HasTags(tags...) {
+			sc := sc
+			last = &sc
+		}
+	}
+	if last == nil {
+		t.Errorf("service check %s%s: not received", name, formatTags(tags))
+		return false
+	}
+	if last.Status != status {
+		t.Errorf("service check %s%s: expected status %d but got %d", name, formatTags(tags), status, last.Status)
+		return false
+	}
+	return true
+}
+
+// AssertNoErrors asserts that everything received was successfully decoded.
+func (s *Server) AssertNoErrors(t TestingT) bool {
+	helper(t)
+
+	if errors := s.Errors(); len(errors) != 0 {
+		t.Errorf("unexpected errors: %v", errors)
+		return false
+	}
+	return true
+}
+
+func (s *Server) findMetricsOfType(t TestingT, name string, mtype MetricType, tags []string) ([]Metric, bool) {
+	metrics := s.FindMetrics(name, tags...)
+	if len(metrics) == 0 {
+		t.Errorf("metric %s%s: not received", name, formatTags(tags))
+		return nil, false
+	}
+	for _, m := range metrics {
+		if m.Type != mtype {
+			t.Errorf("metric %s%s: expected type %q but got %q", name, formatTags(tags), mtype, m.Type)
+			return nil, false
+		}
+	}
+	return metrics, true
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(tags, ",")
+}
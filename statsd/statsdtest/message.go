@@ -0,0 +1,32 @@
+package statsdtest
+
+import (
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+// MetricType is the type of a metric as written on the wire.
+type MetricType = protocol.MetricType
+
+const (
+	// Gauge is the "g" metric type.
+	Gauge = protocol.Gauge
+	// Count is the "c" metric type.
+	Count = protocol.Count
+	// Histogram is the "h" metric type.
+	Histogram = protocol.Histogram
+	// Distribution is the "d" metric type.
+	Distribution = protocol.Distribution
+	// Set is the "s" metric type.
+	Set = protocol.Set
+	// Timing is the "ms" metric type.
+	Timing = protocol.Timing
+)
+
+// Metric is a decoded DogStatsD metric line.
+type Metric = protocol.Metric
+
+// Event is a decoded DogStatsD event.
+type Event = protocol.Event
+
+// ServiceCheck is a decoded DogStatsD service check.
+type ServiceCheck = protocol.ServiceCheck
@@ -0,0 +1,239 @@
+/*
+Package statsdtest provides an in-process DogStatsD server to test code using the statsd package.
+
+The server listens on a local UDP, Unix Domain Socket datagram or Unix Domain Socket stream address, decodes every
+payload it receives and exposes the decoded metrics, events and service checks along with assertion helpers:
+
+	server, err := statsdtest.NewUDPServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client, err := statsd.New(server.Addr(), statsd.WithoutTelemetry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Incr("requests", []string{"route:home"}, 1)
+	client.Close()
+
+	server.WaitForLines(1, time.Second)
+	server.AssertCount(t, "requests", 1, "route:home")
+
+Client telemetry is part of what the server receives: use statsd.WithoutTelemetry() to only receive what the code
+under test sends.
+*/
+package statsdtest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd/protocol"
+)
+
+// Server is a DogStatsD server decoding everything it receives. It is safe to use from multiple goroutines.
+type Server struct {
+	mu            sync.Mutex
+	lines         []string
+	metrics       []Metric
+	events        []Event
+	serviceChecks []ServiceCheck
+	errors        []error
+
+	addr     string
+	closer   io.Closer
+	onClose  func()
+	closed   bool
+	wg       sync.WaitGroup
+	received chan struct{}
+}
+
+func newServer(addr string, closer io.Closer) *Server {
+	return &Server{
+		addr:     addr,
+		closer:   closer,
+		received: make(chan struct{}, 1),
+	}
+}
+
+// NewUDPServer returns a new Server listening on a random UDP port on the loopback interface.
+func NewUDPServer() (*Server, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	s := newServer(conn.LocalAddr().String(), conn)
+	s.wg.Add(1)
+	go s.readDatagrams(conn)
+	return s, nil
+}
+
+// Addr returns the address to give to statsd.New to send data to this server.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Close stops the server. Data received before the call is still available.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.closer.Close()
+	s.wg.Wait()
+	if s.onClose != nil {
+		s.onClose()
+	}
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) readDatagrams(conn net.Conn) {
+	defer s.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.addError(err)
+			continue
+		}
+		s.handlePayload(buffer[:n])
+	}
+}
+
+func (s *Server) addError(err error) {
+	s.mu.Lock()
+	s.errors = append(s.errors, err)
+	s.mu.Unlock()
+}
+
+// handlePayload decodes all the lines of a payload.
+func (s *Server) handlePayload(payload []byte) {
+	s.mu.Lock()
+	for _, line := range strings.Split(string(payload), "\n") {
+		if line == "" {
+			continue
+		}
+		s.lines = append(s.lines, line)
+
+		msg, err := protocol.Parse(line)
+		if err != nil {
+			s.errors = append(s.errors, err)
+			continue
+		}
+		switch m := msg.(type) {
+		case Metric:
+			s.metrics = append(s.metrics, m)
+		case Event:
+			s.events = append(s.events, m)
+		case ServiceCheck:
+			s.serviceChecks = append(s.serviceChecks, m)
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.received <- struct{}{}:
+	default:
+	}
+}
+
+// WaitForLines waits until at least n lines have been received or until the timeout expires.
+func (s *Server) WaitForLines(n int, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		received := len(s.lines)
+		s.mu.Unlock()
+		if received >= n {
+			return nil
+		}
+
+		select {
+		case <-s.received:
+		case <-timer.C:
+			return fmt.Errorf("timeout while waiting for %d lines: only %d were received after %s", n, received, timeout)
+		}
+	}
+}
+
+// Lines returns every line received, in order.
+func (s *Server) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.lines...)
+}
+
+// Metrics returns every metric received, in order.
+func (s *Server) Metrics() []Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Metric{}, s.metrics...)
+}
+
+// Events returns every event received, in order.
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event{}, s.events...)
+}
+
+// ServiceChecks returns every service check received, in order.
+func (s *Server) ServiceChecks() []ServiceCheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ServiceCheck{}, s.serviceChecks...)
+}
+
+// Errors returns the errors encountered while receiving or decoding data.
+func (s *Server) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error{}, s.errors...)
+}
+
+// FindMetrics returns the metrics with the given name having all the given tags.
+func (s *Server) FindMetrics(name string, tags ...string) []Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := []Metric{}
+	for _, m := range s.metrics {
+		if m.Name == name && m.HasTags(tags...) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// Reset forgets everything received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = nil
+	s.metrics = nil
+	s.events = nil
+	s.serviceChecks = nil
+	s.errors = nil
+}
@@ -0,0 +1,140 @@
+package statsdtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// fakeT records the failures of the assertion helpers.
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func sendAll(t *testing.T, addr string) {
+	client, err := statsd.New(addr,
+		statsd.WithoutTelemetry(),
+		statsd.WithoutOriginDetection(),
+		statsd.WithoutClientSideAggregation(),
+		statsd.WithNamespace("test"),
+		statsd.WithTags([]string{"env:dev"}),
+	)
+	require.NoError(t, err)
+
+	client.Count("count", 2, []string{"a"}, 1)
+	client.Count("count", 3, []string{"a"}, 0.5)
+	client.Gauge("gauge", 1, nil, 1)
+	client.Gauge("gauge", 2, nil, 1)
+	client.Distribution("distribution", 1.5, nil, 1)
+	client.Timing("timing", time.Second, nil, 1)
+	client.Set("set", "id1", nil, 1)
+	client.Set("set", "id2", nil, 1)
+	client.SimpleEvent("title", "some\ntext")
+	client.SimpleServiceCheck("check", statsd.Critical)
+	require.NoError(t, client.Close())
+}
+
+func assertAll(t *testing.T, server *Server) {
+	// Count with a rate of 0.5 might be sampled out by the client.
+	require.NoError(t, server.WaitForLines(9, 5*time.Second))
+
+	server.AssertNoErrors(t)
+	if len(server.FindMetrics("test.count")) == 2 {
+		server.AssertCount(t, "test.count", 8, "a", "env:dev")
+	} else {
+		server.AssertCount(t, "test.count", 2, "a", "env:dev")
+	}
+	server.AssertGauge(t, "test.gauge", 2)
+	server.AssertSamples(t, "test.distribution", []float64{1.5})
+	server.AssertSamples(t, "test.timing", []float64{1000})
+	server.AssertSet(t, "test.set", []string{"id2", "id1"})
+	server.AssertNoMetric(t, "test.unknown")
+	server.AssertEvent(t, "title", "env:dev")
+	server.AssertServiceCheck(t, "check", int(statsd.Critical))
+
+	events := server.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "some\ntext", events[0].Text)
+}
+
+func TestUDPServer(t *testing.T) {
+	server, err := NewUDPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	sendAll(t, server.Addr())
+	assertAll(t, server)
+}
+
+func TestServerWaitTimeout(t *testing.T) {
+	server, err := NewUDPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	assert.Error(t, server.WaitForLines(1, 10*time.Millisecond))
+}
+
+func TestServerReset(t *testing.T) {
+	server, err := NewUDPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	server.handlePayload([]byte("name:1|c\n_e{1,1}:a|b\n_sc|name|0\ninvalid\n"))
+	assert.Len(t, server.Lines(), 4)
+	assert.Len(t, server.Metrics(), 1)
+	assert.Len(t, server.Events(), 1)
+	assert.Len(t, server.ServiceChecks(), 1)
+	assert.Len(t, server.Errors(), 1)
+
+	server.Reset()
+	assert.Empty(t, server.Lines())
+	assert.Empty(t, server.Metrics())
+	assert.Empty(t, server.Events())
+	assert.Empty(t, server.ServiceChecks())
+	assert.Empty(t, server.Errors())
+}
+
+func TestAssertLines(t *testing.T) {
+	server, err := NewUDPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	server.handlePayload([]byte("b:1|c\na:1|g\n"))
+
+	assert.True(t, server.AssertLines(t, "a:1|g", "b:1|c"))
+
+	ft := &fakeT{}
+	assert.False(t, server.AssertLines(ft, "a:1|g"))
+	assert.Len(t, ft.errors, 1)
+}
+
+func TestAssertionFailures(t *testing.T) {
+	server, err := NewUDPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	server.handlePayload([]byte("count:1|c\ngauge:1|g\nset:a|s\ndistribution:1|d\n_sc|check|0\n_e{1,1}:a|b\n"))
+
+	ft := &fakeT{}
+	assert.False(t, server.AssertCount(ft, "count", 2))
+	assert.False(t, server.AssertCount(ft, "gauge", 1))
+	assert.False(t, server.AssertCount(ft, "unknown", 1))
+	assert.False(t, server.AssertGauge(ft, "gauge", 2))
+	assert.False(t, server.AssertSamples(ft, "distribution", []float64{2}))
+	assert.False(t, server.AssertSamples(ft, "count", []float64{1}))
+	assert.False(t, server.AssertSet(ft, "set", []string{"b"}))
+	assert.False(t, server.AssertNoMetric(ft, "count"))
+	assert.False(t, server.AssertEvent(ft, "b"))
+	assert.False(t, server.AssertServiceCheck(ft, "check", 1))
+	assert.False(t, server.AssertServiceCheck(ft, "unknown", 0))
+	assert.Len(t, ft.errors, 11)
+}
@@ -0,0 +1,123 @@
+//go:build !windows
+// +build !windows
+
+package statsdtest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// NewUDSDatagramServer returns a new Server listening on a Unix Domain Socket datagram at the given path.
+func NewUDSDatagramServer(socketPath string) (*Server, error) {
+	addr, err := net.ResolveUnixAddr("unixgram", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newServer("unixgram://"+socketPath, conn)
+	s.onClose = func() { os.Remove(socketPath) }
+	s.wg.Add(1)
+	go s.readDatagrams(conn)
+	return s, nil
+}
+
+// NewUDSStreamServer returns a new Server listening on a Unix Domain Socket stream at the given path. Payloads are
+// expected to be prefixed by their length, as sent by the statsd package.
+func NewUDSStreamServer(socketPath string) (*Server, error) {
+	addr, err := net.ResolveUnixAddr("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newServer("unixstream://"+socketPath, listener)
+	conns := &streamConns{conns: map[net.Conn]struct{}{}}
+	s.onClose = func() {
+		conns.closeAll()
+		os.Remove(socketPath)
+	}
+	s.wg.Add(1)
+	go s.acceptStreams(listener, conns)
+	return s, nil
+}
+
+// streamConns tracks the accepted connections so they can be closed with the server.
+type streamConns struct {
+	sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func (c *streamConns) add(conn net.Conn) {
+	c.Lock()
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+	c.Unlock()
+}
+
+func (c *streamConns) remove(conn net.Conn) {
+	c.Lock()
+	delete(c.conns, conn)
+	c.Unlock()
+	c.wg.Done()
+}
+
+func (c *streamConns) closeAll() {
+	c.Lock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.Unlock()
+	c.wg.Wait()
+}
+
+func (s *Server) acceptStreams(listener net.Listener, conns *streamConns) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.addError(err)
+			continue
+		}
+		conns.add(conn)
+		go s.readStream(conn, conns)
+	}
+}
+
+func (s *Server) readStream(conn net.Conn, conns *streamConns) {
+	defer conns.remove(conn)
+	defer conn.Close()
+
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.LittleEndian, &length); err != nil {
+			if err != io.EOF && !s.isClosed() {
+				s.addError(err)
+			}
+			return
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			if !s.isClosed() {
+				s.addError(err)
+			}
+			return
+		}
+		s.handlePayload(payload)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package statsdtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/nettest"
+)
+
+func TestUDSDatagramServer(t *testing.T) {
+	socketPath, err := nettest.LocalPath()
+	require.NoError(t, err)
+
+	server, err := NewUDSDatagramServer(socketPath)
+	require.NoError(t, err)
+	defer server.Close()
+
+	sendAll(t, server.Addr())
+	assertAll(t, server)
+}
+
+func TestUDSStreamServer(t *testing.T) {
+	socketPath, err := nettest.LocalPath()
+	require.NoError(t, err)
+
+	server, err := NewUDSStreamServer(socketPath)
+	require.NoError(t, err)
+	defer server.Close()
+
+	sendAll(t, server.Addr())
+	assertAll(t, server)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package statsdtest
+
+import (
+	"fmt"
+)
+
+// NewUDSDatagramServer is not available on Windows.
+func NewUDSDatagramServer(_ string) (*Server, error) {
+	return nil, fmt.Errorf("Unix socket is not available on Windows")
+}
+
+// NewUDSStreamServer is not available on Windows.
+func NewUDSStreamServer(_ string) (*Server, error) {
+	return nil, fmt.Errorf("Unix socket is not available on Windows")
+}
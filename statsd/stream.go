@@ -0,0 +1,48 @@
+package statsd
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// writeFramed writes data to a stream connection, prefixed with its length as a 4 bytes little-endian integer as
+// expected by the agent on stream sockets.
+//
+// The write deadline will only make us drop the packet if we can't write it at all, once we've started writing we
+// need to finish: partialWrite reports if the stream is left in the middle of a packet.
+func writeFramed(conn net.Conn, data []byte, writeTimeout time.Duration, connectTimeout time.Duration) (n int, partialWrite bool, err error) {
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	bs := []byte{0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(bs, uint32(len(data)))
+	_, err = conn.Write(bs)
+
+	partialWrite = true
+
+	// W need to be able to finish to write partially written packets once we have started.
+	// But we will reset the connection if we can't write anything at all for a long time.
+	conn.SetWriteDeadline(time.Now().Add(connectTimeout))
+
+	// Continue writing only if we've written the length of the packet
+	if err == nil {
+		n, err = conn.Write(data)
+		if err == nil {
+			partialWrite = false
+		}
+	}
+	return n, partialWrite, err
+}
+
+// shouldCloseConnection returns true if the connection can't be used anymore after a write returned err.
+func shouldCloseConnection(err error, partialWrite bool) bool {
+	if err != nil && partialWrite {
+		// We can't recover from a partial write
+		return true
+	}
+	if err, isNetworkErr := err.(net.Error); err != nil && (!isNetworkErr || !err.Timeout()) {
+		// Statsd server disconnected, retry connecting at next packet
+		return true
+	}
+	return false
+}
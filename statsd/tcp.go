@@ -0,0 +1,99 @@
+package statsd
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// tcpKeepAlivePeriod is the keep-alive period of the TCP connection to the agent.
+const tcpKeepAlivePeriod = 30 * time.Second
+
+// tcpWriter is an internal class wrapping around management of TCP connection. Payloads are framed as on UDS stream
+// sockets.
+type tcpWriter struct {
+	// Address to send metrics to, needed to allow reconnection on error
+	addr string
+	// Established connection object, or nil if not connected yet
+	conn net.Conn
+	// write timeout
+	writeTimeout time.Duration
+	// connect timeout
+	connectTimeout time.Duration
+	sync.RWMutex   // used to lock conn / writer can replace it
+}
+
+// newTCPWriter returns a pointer to a new tcpWriter given an addr in the format "hostname:port".
+func newTCPWriter(addr string, writeTimeout time.Duration, connectTimeout time.Duration) (*tcpWriter, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, err
+	}
+	// Defer connection to first Write
+	writer := &tcpWriter{addr: addr, writeTimeout: writeTimeout, connectTimeout: connectTimeout}
+	return writer, nil
+}
+
+// GetTransportName returns the transport used by the writer
+func (w *tcpWriter) GetTransportName() string {
+	return writerNameTCP
+}
+
+// Write data to the TCP connection with write timeout and minimal error handling:
+// create the connection if nil, and destroy it if the statsd server has disconnected
+func (w *tcpWriter) Write(data []byte) (int, error) {
+	conn, err := w.ensureConnection()
+	if err != nil {
+		return 0, err
+	}
+
+	n, partialWrite, err := writeFramed(conn, data, w.writeTimeout, w.connectTimeout)
+	if shouldCloseConnection(err, partialWrite) {
+		w.unsetConnection(conn)
+	}
+	return n, err
+}
+
+func (w *tcpWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+func (w *tcpWriter) ensureConnection() (net.Conn, error) {
+	// Check if we've already got a socket we can use
+	w.RLock()
+	currentConn := w.conn
+	w.RUnlock()
+
+	if currentConn != nil {
+		return currentConn, nil
+	}
+
+	// Looks like we might need to connect - try again with write locking.
+	w.Lock()
+	defer w.Unlock()
+	if w.conn != nil {
+		return w.conn, nil
+	}
+
+	dialer := net.Dialer{Timeout: w.connectTimeout, KeepAlive: tcpKeepAlivePeriod}
+	newConn, err := dialer.Dial("tcp", w.addr)
+	if err != nil {
+		return nil, err
+	}
+	w.conn = newConn
+	return newConn, nil
+}
+
+// unsetConnection closes conn, the next Write will reconnect.
+func (w *tcpWriter) unsetConnection(conn net.Conn) {
+	w.Lock()
+	defer w.Unlock()
+	_ = conn.Close()
+	if w.conn == conn {
+		w.conn = nil
+	}
+}
@@ -0,0 +1,130 @@
+package statsd
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFramed(t *testing.T, conn net.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var l uint32
+	require.NoError(t, binary.Read(conn, binary.LittleEndian, &l))
+	buffer := make([]byte, l)
+	_, err := io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	return string(buffer)
+}
+
+func TestNewTCPWriter(t *testing.T) {
+	w, err := newTCPWriter("localhost:8125", 100*time.Millisecond, 1000*time.Millisecond)
+	assert.NotNil(t, w)
+	assert.NoError(t, err)
+	assert.Equal(t, writerNameTCP, w.GetTransportName())
+
+	_, err = newTCPWriter("localhost", 100*time.Millisecond, 1000*time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestTCPWrite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	w, err := newTCPWriter(listener.Addr().String(), 100*time.Millisecond, 1000*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	var conn net.Conn
+
+	// test 2 Write: the first one should setup the connection
+	for i := 0; i < 2; i++ {
+		msg := []byte("some data")
+		n, err := w.Write(msg)
+		require.NoError(t, err)
+		assert.Equal(t, len(msg), n)
+
+		// This works because the kernel accepts connections before the accept call
+		if conn == nil {
+			conn, err = listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+		}
+		assert.Equal(t, "some data", readFramed(t, conn))
+	}
+}
+
+func TestTCPWriteReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	w, err := newTCPWriter(listener.Addr().String(), 100*time.Millisecond, 1000*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first"))
+	require.NoError(t, err)
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	assert.Equal(t, "first", readFramed(t, conn))
+
+	// The agent closes the connection: writes fail until the writer notices and drops the connection.
+	conn.Close()
+	require.Eventually(t, func() bool {
+		_, err := w.Write([]byte("lost"))
+		return err != nil
+	}, time.Second, time.Millisecond)
+	w.RLock()
+	assert.Nil(t, w.conn)
+	w.RUnlock()
+
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+	conn, err = listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "second", readFramed(t, conn))
+}
+
+func TestTCPWriteNoAgent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	w, err := newTCPWriter(addr, 100*time.Millisecond, 1000*time.Millisecond)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("some data"))
+	assert.Error(t, err)
+	assert.Nil(t, w.conn)
+}
+
+func TestTCPClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	client, err := New(TCPAddressPrefix+listener.Addr().String(),
+		WithoutTelemetry(),
+		WithoutOriginDetection(),
+		WithoutClientSideAggregation(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, writerNameTCP, client.GetTransport())
+
+	require.NoError(t, client.Gauge("gauge", 1, []string{"tag"}, 1))
+	require.NoError(t, client.Flush())
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "gauge:1|g|#tag\n", readFramed(t, conn))
+	require.NoError(t, client.Close())
+}
@@ -85,6 +85,44 @@ type Telemetry struct {
 	// the wire. If your app sends metrics in batch look at WithSenderQueueSize option to increase the queue size.
 	TotalBytesDroppedQueueFull uint64
 
+	// TotalPayloadsBlockedQueueFull is the total number of payloads for which the client had to wait for room in the
+	// queue of payloads waiting to be sent on the wire when WithSenderBlockingMode is used. Payloads still not queued
+	// after the timeout are dropped and also counted in TotalPayloadsDroppedQueueFull.
+	TotalPayloadsBlockedQueueFull uint64
+	// TotalTimeBlockedQueueFull is the total time spent waiting for room in the queue of payloads waiting to be sent
+	// on the wire when WithSenderBlockingMode is used.
+	TotalTimeBlockedQueueFull time.Duration
+
+	// TotalBytesSpooled is the total number of bytes stored in the disk spool because they couldn't be written on the
+	// wire when WithDiskSpool is used.
+	TotalBytesSpooled uint64
+	// TotalBytesReplayed is the total number of bytes from the disk spool successfully written on the wire once the
+	// agent was reachable again. Those bytes are also counted in TotalBytesSent.
+	TotalBytesReplayed uint64
+	// TotalBytesEvicted is the total number of bytes removed from the disk spool without being replayed because the
+	// spool was full or the payloads were too old.
+	TotalBytesEvicted uint64
+
+	// TotalPayloadsRetried is the total number of times a payload was written again after a failure when
+	// WithRetryPolicy is used.
+	TotalPayloadsRetried uint64
+	// TotalPayloadsRecovered is the total number of payloads successfully written after at least one failure when
+	// WithRetryPolicy is used. Payloads still failing after the last attempt are counted in
+	// TotalPayloadsDroppedWriter, unless they are stored in the disk spool.
+	TotalPayloadsRecovered uint64
+
+	// TotalBytesUncompressed is the total number of bytes successfully written to compressed transports, before
+	// compression, when WithCompression is used.
+	TotalBytesUncompressed uint64
+	// TotalBytesCompressed is the total number of bytes successfully written to compressed transports, after
+	// compression, when WithCompression is used.
+	TotalBytesCompressed uint64
+
+	// Destinations contains the telemetry of each destination when WithFanOut or WithFanOutWriter is used, the first
+	// one being the address given to NewEx or the writer given to NewWithWriter. The sender telemetry above counts a
+	// payload as sent as soon as one destination wrote it.
+	Destinations []DestinationTelemetry
+
 	//
 	// Those are produced by the 'aggregator'
 	//
@@ -110,6 +148,9 @@ type Telemetry struct {
 	// AggregationNbContextTiming is the total number of contexts for timings flushed by the aggregator when either
 	// WithClientSideAggregation or WithExtendedClientSideAggregation options are enabled.
 	AggregationNbContextTiming uint64
+	// AggregationContextsOverflowed is the total number of samples aggregated in an overflow context because a limit
+	// set with WithMaxContexts or WithMaxContextsPerName was reached.
+	AggregationContextsOverflowed uint64
 }
 
 type telemetryClient struct {
@@ -139,10 +180,10 @@ func newTelemetryClient(c *ClientEx, aggregationEnabled bool) *telemetryClient {
 }
 
 func newTelemetryClientWithCustomAddr(c *ClientEx, telemetryAddr string, aggregationEnabled bool, pool *bufferPool,
-	writeTimeout time.Duration, connectTimeout time.Duration,
+	writeTimeout time.Duration, connectTimeout time.Duration, resolveInterval time.Duration,
 ) (*telemetryClient, error) {
 	telemetryAddr = resolveAddr(telemetryAddr)
-	telemetryWriter, _, err := createWriter(telemetryAddr, writeTimeout, connectTimeout)
+	telemetryWriter, _, err := createWriter(telemetryAddr, writeTimeout, connectTimeout, resolveInterval)
 	if err != nil {
 		return nil, fmt.Errorf("Could not resolve telemetry address: %v", err)
 	}
@@ -158,6 +199,21 @@ func newTelemetryClientWithCustomAddr(c *ClientEx, telemetryAddr string, aggrega
 	return t, nil
 }
 
+// DestinationTelemetry represents internal metrics about one of the destinations of a client using WithFanOut or
+// WithFanOutWriter.
+type DestinationTelemetry struct {
+	// Name is the address of the destination, or "custom-<index>" for writers.
+	Name string
+	// TotalPayloadsSent is the total number of payloads successfully written to the destination.
+	TotalPayloadsSent uint64
+	// TotalPayloadsDropped is the total number of payloads the destination failed to write.
+	TotalPayloadsDropped uint64
+	// TotalBytesSent is the total number of bytes successfully written to the destination.
+	TotalBytesSent uint64
+	// TotalBytesDropped is the total number of bytes the destination failed to write.
+	TotalBytesDropped uint64
+}
+
 func (t *telemetryClient) run(wg *sync.WaitGroup, stop chan struct{}) {
 	wg.Add(1)
 	go func() {
@@ -291,6 +347,35 @@ func (t *telemetryClient) flush() []metric {
 	telemetryCount("datadog.dogstatsd.client.bytes_dropped_queue", int64(tlm.TotalBytesDroppedQueueFull-t.lastSample.TotalBytesDroppedQueueFull), t.tags)
 	telemetryCount("datadog.dogstatsd.client.bytes_dropped_writer", int64(tlm.TotalBytesDroppedWriter-t.lastSample.TotalBytesDroppedWriter), t.tags)
 
+	if t.c.sender.blockingTimeout > 0 {
+		telemetryCount("datadog.dogstatsd.client.packets_blocked_queue", int64(tlm.TotalPayloadsBlockedQueueFull-t.lastSample.TotalPayloadsBlockedQueueFull), t.tags)
+		telemetryCount("datadog.dogstatsd.client.time_blocked_queue_ms", int64((tlm.TotalTimeBlockedQueueFull-t.lastSample.TotalTimeBlockedQueueFull)/time.Millisecond), t.tags)
+	}
+	if t.c.sender.spool != nil {
+		telemetryCount("datadog.dogstatsd.client.bytes_spooled", int64(tlm.TotalBytesSpooled-t.lastSample.TotalBytesSpooled), t.tags)
+		telemetryCount("datadog.dogstatsd.client.bytes_replayed", int64(tlm.TotalBytesReplayed-t.lastSample.TotalBytesReplayed), t.tags)
+		telemetryCount("datadog.dogstatsd.client.bytes_evicted", int64(tlm.TotalBytesEvicted-t.lastSample.TotalBytesEvicted), t.tags)
+	}
+	if t.c.sender.retryPolicy != nil {
+		telemetryCount("datadog.dogstatsd.client.packets_retried", int64(tlm.TotalPayloadsRetried-t.lastSample.TotalPayloadsRetried), t.tags)
+		telemetryCount("datadog.dogstatsd.client.packets_recovered", int64(tlm.TotalPayloadsRecovered-t.lastSample.TotalPayloadsRecovered), t.tags)
+	}
+	if t.c.sender.compression != nil {
+		telemetryCount("datadog.dogstatsd.client.bytes_uncompressed", int64(tlm.TotalBytesUncompressed-t.lastSample.TotalBytesUncompressed), t.tags)
+		telemetryCount("datadog.dogstatsd.client.bytes_compressed", int64(tlm.TotalBytesCompressed-t.lastSample.TotalBytesCompressed), t.tags)
+	}
+	for i, d := range tlm.Destinations {
+		last := DestinationTelemetry{}
+		if i < len(t.lastSample.Destinations) {
+			last = t.lastSample.Destinations[i]
+		}
+		tags := append(append([]string{}, t.tags...), "destination:"+d.Name)
+		telemetryCount("datadog.dogstatsd.client.destination.packets_sent", int64(d.TotalPayloadsSent-last.TotalPayloadsSent), tags)
+		telemetryCount("datadog.dogstatsd.client.destination.packets_dropped", int64(d.TotalPayloadsDropped-last.TotalPayloadsDropped), tags)
+		telemetryCount("datadog.dogstatsd.client.destination.bytes_sent", int64(d.TotalBytesSent-last.TotalBytesSent), tags)
+		telemetryCount("datadog.dogstatsd.client.destination.bytes_dropped", int64(d.TotalBytesDropped-last.TotalBytesDropped), tags)
+	}
+
 	if t.aggEnabled {
 		telemetryCount("datadog.dogstatsd.client.aggregated_context", int64(tlm.AggregationNbContext-t.lastSample.AggregationNbContext), t.tags)
 		telemetryCount("datadog.dogstatsd.client.aggregated_context_by_type", int64(tlm.AggregationNbContextGauge-t.lastSample.AggregationNbContextGauge), t.tagsByType[gauge])
@@ -299,6 +384,9 @@ func (t *telemetryClient) flush() []metric {
 		telemetryCount("datadog.dogstatsd.client.aggregated_context_by_type", int64(tlm.AggregationNbContextHistogram-t.lastSample.AggregationNbContextHistogram), t.tagsByType[histogram])
 		telemetryCount("datadog.dogstatsd.client.aggregated_context_by_type", int64(tlm.AggregationNbContextDistribution-t.lastSample.AggregationNbContextDistribution), t.tagsByType[distribution])
 		telemetryCount("datadog.dogstatsd.client.aggregated_context_by_type", int64(tlm.AggregationNbContextTiming-t.lastSample.AggregationNbContextTiming), t.tagsByType[timing])
+		if t.c.agg.limiter != nil {
+			telemetryCount("datadog.dogstatsd.client.aggregated_context_overflowed", int64(tlm.AggregationContextsOverflowed-t.lastSample.AggregationContextsOverflowed), t.tags)
+		}
 	}
 
 	t.lastSample = tlm
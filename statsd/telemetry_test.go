@@ -6,6 +6,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -134,3 +135,29 @@ func TestTelemetryCardinalityEnvVar(t *testing.T) {
 		assert.Equal(t, CardinalityLow, m.cardinality, "telemetry metric %q should carry low cardinality from env var", m.name)
 	}
 }
+
+func TestTelemetrySenderBlockingMode(t *testing.T) {
+	client, err := NewEx("localhost:8765")
+	require.NoError(t, err)
+	defer client.Close()
+	for _, m := range client.telemetryClient.flush() {
+		assert.NotContains(t, m.name, "blocked")
+	}
+
+	client, err = NewEx("localhost:8765", WithSenderBlockingMode(time.Second))
+	require.NoError(t, err)
+	defer client.Close()
+	atomic.AddUint64(&client.sender.telemetry.totalPayloadsBlockedQueueFull, 2)
+	atomic.AddUint64(&client.sender.telemetry.totalTimeBlockedQueueFull, uint64(30*time.Millisecond))
+
+	blocked := map[string]int64{}
+	for _, m := range client.telemetryClient.flush() {
+		if strings.Contains(m.name, "blocked") {
+			blocked[m.name] = m.ivalue
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"datadog.dogstatsd.client.packets_blocked_queue": 2,
+		"datadog.dogstatsd.client.time_blocked_queue_ms": 30,
+	}, blocked)
+}
@@ -0,0 +1,117 @@
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimestampOutOfRange is returned when a timestamped metric is outside of the range accepted by
+// WithTimestampAggregation.
+const TimestampOutOfRange = invalidTimestampErr("timestamp out of the accepted range")
+
+// timestampMaxFuture is how far in the future Datadog accepts timestamped points.
+const timestampMaxFuture = 10 * time.Minute
+
+type timestampedContext struct {
+	context   string
+	timestamp int64
+}
+
+// timestampedContexts aggregates the counts and gauges sent with a timestamp by context and second. Unlike the
+// regular contexts they are not sharded: they are meant for backfilling jobs rather than hot paths.
+type timestampedContexts struct {
+	// maxAge is the maximum age of the accepted points, 0 when there is no limit.
+	maxAge time.Duration
+
+	sync.Mutex
+	counts map[timestampedContext]*countMetric
+	gauges map[timestampedContext]*gaugeMetric
+}
+
+func newTimestampedContexts(maxAge time.Duration) *timestampedContexts {
+	return &timestampedContexts{
+		maxAge: maxAge,
+		counts: map[timestampedContext]*countMetric{},
+		gauges: map[timestampedContext]*gaugeMetric{},
+	}
+}
+
+func (tc *timestampedContexts) checkTimestamp(timestamp int64) error {
+	now := time.Now()
+	if tc.maxAge > 0 && timestamp < now.Add(-tc.maxAge).Unix() {
+		return TimestampOutOfRange
+	}
+	if timestamp > now.Add(timestampMaxFuture).Unix() {
+		return TimestampOutOfRange
+	}
+	return nil
+}
+
+func newTimestampedContext(name string, tags []string, timestamp int64, cardinality Cardinality) timestampedContext {
+	cardString := cardinality.String()
+	contextBuffer, _ := appendContext(make([]byte, 0, getContextLength(name, tags, cardString)), name, tags, cardString)
+	return timestampedContext{context: string(contextBuffer), timestamp: timestamp}
+}
+
+func (a *aggregator) countWithTimestamp(name string, value int64, tags []string, timestamp int64, cardinality Cardinality) error {
+	tc := a.timestamped
+	if err := tc.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+	key := newTimestampedContext(name, tags, timestamp, cardinality)
+
+	tc.Lock()
+	if count, found := tc.counts[key]; found {
+		count.sample(value)
+	} else {
+		tc.counts[key] = newCountMetric(name, value, tags, cardinality)
+	}
+	tc.Unlock()
+	return nil
+}
+
+func (a *aggregator) gaugeWithTimestamp(name string, value float64, tags []string, timestamp int64, cardinality Cardinality) error {
+	tc := a.timestamped
+	if err := tc.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+	key := newTimestampedContext(name, tags, timestamp, cardinality)
+
+	tc.Lock()
+	if gauge, found := tc.gauges[key]; found {
+		gauge.sample(value)
+	} else {
+		tc.gauges[key] = newGaugeMetric(name, value, tags, cardinality)
+	}
+	tc.Unlock()
+	return nil
+}
+
+// flushTimestamped appends one metric per context and second to metrics.
+func (a *aggregator) flushTimestamped(metrics []metric) []metric {
+	tc := a.timestamped
+	tc.Lock()
+	counts, gauges := tc.counts, tc.gauges
+	if len(counts) != 0 {
+		tc.counts = map[timestampedContext]*countMetric{}
+	}
+	if len(gauges) != 0 {
+		tc.gauges = map[timestampedContext]*gaugeMetric{}
+	}
+	tc.Unlock()
+
+	for key, c := range counts {
+		m := c.flushUnsafe()
+		m.timestamp = key.timestamp
+		metrics = append(metrics, m)
+	}
+	for key, g := range gauges {
+		m := g.flushUnsafe()
+		m.timestamp = key.timestamp
+		metrics = append(metrics, m)
+	}
+	atomic.AddUint64(&a.nbContextCount, uint64(len(counts)))
+	atomic.AddUint64(&a.nbContextGauge, uint64(len(gauges)))
+	return metrics
+}
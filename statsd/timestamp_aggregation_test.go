@@ -0,0 +1,84 @@
+package statsd
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregatorTimestamped(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.timestamped = newTimestampedContexts(time.Hour)
+	ts1 := time.Now().Add(-30 * time.Minute).Unix()
+	ts2 := ts1 + 1
+
+	require.NoError(t, a.countWithTimestamp("count", 1, []string{"tag"}, ts1, CardinalityNotSet))
+	require.NoError(t, a.countWithTimestamp("count", 2, []string{"tag"}, ts1, CardinalityNotSet))
+	require.NoError(t, a.countWithTimestamp("count", 4, []string{"tag"}, ts2, CardinalityNotSet))
+	require.NoError(t, a.countWithTimestamp("count", 8, []string{"other"}, ts1, CardinalityNotSet))
+	require.NoError(t, a.gaugeWithTimestamp("gauge", 1, nil, ts1, CardinalityLow))
+	require.NoError(t, a.gaugeWithTimestamp("gauge", 2, nil, ts1, CardinalityLow))
+	// timestamped metrics don't share the contexts of the regular ones
+	require.NoError(t, a.count("count", 16, []string{"tag"}, CardinalityNotSet))
+
+	metrics := a.flushMetrics()
+	sort.Slice(metrics, func(i, j int) bool {
+		return fmt.Sprint(metrics[i].name, metrics[i].tags, metrics[i].timestamp) < fmt.Sprint(metrics[j].name, metrics[j].tags, metrics[j].timestamp)
+	})
+	assert.Equal(t, []metric{
+		{metricType: count, name: "count", tags: []string{"other"}, rate: 1, ivalue: 8, timestamp: ts1},
+		{metricType: count, name: "count", tags: []string{"tag"}, rate: 1, ivalue: 16},
+		{metricType: count, name: "count", tags: []string{"tag"}, rate: 1, ivalue: 3, timestamp: ts1},
+		{metricType: count, name: "count", tags: []string{"tag"}, rate: 1, ivalue: 4, timestamp: ts2},
+		{metricType: gauge, name: "gauge", rate: 1, fvalue: 2, timestamp: ts1, cardinality: CardinalityLow},
+	}, metrics)
+	assert.Equal(t, uint64(4), a.nbContextCount)
+	assert.Equal(t, uint64(1), a.nbContextGauge)
+
+	assert.Empty(t, a.flushMetrics())
+}
+
+func TestAggregatorTimestampedRange(t *testing.T) {
+	a := newAggregator(nil, 0, 1)
+	a.timestamped = newTimestampedContexts(time.Hour)
+	now := time.Now()
+
+	assert.Equal(t, TimestampOutOfRange, a.countWithTimestamp("count", 1, nil, now.Add(-2*time.Hour).Unix(), CardinalityNotSet))
+	assert.Equal(t, TimestampOutOfRange, a.gaugeWithTimestamp("gauge", 1, nil, now.Add(time.Hour).Unix(), CardinalityNotSet))
+	assert.NoError(t, a.gaugeWithTimestamp("gauge", 1, nil, now.Add(time.Minute).Unix(), CardinalityNotSet))
+
+	// no limit in the past
+	a.timestamped = newTimestampedContexts(0)
+	assert.NoError(t, a.countWithTimestamp("count", 1, nil, now.Add(-48*time.Hour).Unix(), CardinalityNotSet))
+	assert.Equal(t, TimestampOutOfRange, a.countWithTimestamp("count", 1, nil, now.Add(time.Hour).Unix(), CardinalityNotSet))
+}
+
+func TestWithTimestampAggregation(t *testing.T) {
+	_, err := resolveOptions([]Option{WithTimestampAggregation(-time.Second)})
+	assert.EqualError(t, err, "maxAge must not be negative")
+
+	ts, client := newClientAndTestServer(t,
+		"udp",
+		"localhost:8772",
+		nil,
+		WithTimestampAggregation(0),
+		WithoutTelemetry(),
+		WithoutOriginDetection(),
+	)
+
+	timestamp := time.Unix(1658934092, 0)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.CountWithTimestamp("count", 1, []string{"tag"}, 1, timestamp))
+		require.NoError(t, client.GaugeWithTimestamp("gauge", float64(i), nil, 1, timestamp.Add(time.Second)))
+	}
+	require.NoError(t, client.Flush())
+
+	ts.assert(t, client, []string{
+		"count:3|c|#tag" + ts.getContainerID() + "|T1658934092",
+		"gauge:2|g" + ts.getContainerID() + "|T1658934093",
+	})
+}
@@ -2,17 +2,28 @@ package statsd
 
 import (
 	"net"
+	"sync"
 	"time"
 )
 
+// resolveUDPAddr is replaced in tests.
+var resolveUDPAddr = net.ResolveUDPAddr
+
 // udpWriter is an internal class wrapping around management of UDP connection
 type udpWriter struct {
-	conn net.Conn
+	// Address to send metrics to, needed to resolve it again
+	addr string
+	conn *net.UDPConn
+	// stop is closed to stop resolving the address again, nil if the address is resolved once
+	stop         chan struct{}
+	closeOnce    sync.Once
+	sync.RWMutex // used to lock conn / resolveLoop can replace it
 }
 
-// New returns a pointer to a new udpWriter given an addr in the format "hostname:port".
-func newUDPWriter(addr string, _ time.Duration) (*udpWriter, error) {
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+// New returns a pointer to a new udpWriter given an addr in the format "hostname:port". When resolveInterval is
+// positive, the address is resolved again at that interval and the writer switches to the new IP when it changed.
+func newUDPWriter(addr string, _ time.Duration, resolveInterval time.Duration) (*udpWriter, error) {
+	udpAddr, err := resolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -20,16 +31,72 @@ func newUDPWriter(addr string, _ time.Duration) (*udpWriter, error) {
 	if err != nil {
 		return nil, err
 	}
-	writer := &udpWriter{conn: conn}
+	writer := &udpWriter{addr: addr, conn: conn}
+	if resolveInterval > 0 {
+		writer.stop = make(chan struct{})
+		go writer.resolveLoop(resolveInterval)
+	}
 	return writer, nil
 }
 
+func (w *udpWriter) resolveLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.resolve()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// resolve resolves the address again and replaces the connection if the IP changed. Errors are ignored: the writer
+// keeps using the last known IP.
+func (w *udpWriter) resolve() {
+	udpAddr, err := resolveUDPAddr("udp", w.addr)
+	if err != nil {
+		return
+	}
+
+	w.RLock()
+	current := w.conn.RemoteAddr().String()
+	w.RUnlock()
+	if udpAddr.String() == current {
+		return
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	select {
+	case <-w.stop:
+		// the writer was closed in the meantime
+		conn.Close()
+		return
+	default:
+	}
+	w.conn.Close()
+	w.conn = conn
+}
+
 // Write data to the UDP connection with no error handling
 func (w *udpWriter) Write(data []byte) (int, error) {
+	w.RLock()
+	defer w.RUnlock()
 	return w.conn.Write(data)
 }
 
 func (w *udpWriter) Close() error {
+	if w.stop != nil {
+		w.closeOnce.Do(func() { close(w.stop) })
+	}
+	w.Lock()
+	defer w.Unlock()
 	return w.conn.Close()
 }
 
@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUDPListener(t *testing.T, network string, ip string) *net.UDPConn {
+	conn, err := net.ListenUDP(network, &net.UDPAddr{IP: net.ParseIP(ip)})
+	require.NoError(t, err)
+	return conn
+}
+
+func readUDP(t *testing.T, conn *net.UDPConn) string {
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	return string(buffer[:n])
+}
+
+func TestUDPWriterResolve(t *testing.T) {
+	first := newTestUDPListener(t, "udp", "127.0.0.1")
+	defer first.Close()
+	second := newTestUDPListener(t, "udp", "127.0.0.1")
+	defer second.Close()
+
+	// "agent" resolves to the first listener, then to the second one
+	target := first.LocalAddr().(*net.UDPAddr)
+	defer func() { resolveUDPAddr = net.ResolveUDPAddr }()
+	resolveUDPAddr = func(network string, addr string) (*net.UDPAddr, error) {
+		return target, nil
+	}
+
+	w, err := newUDPWriter("agent:8125", 0, 0)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", readUDP(t, first))
+
+	// the IP didn't change
+	conn := w.conn
+	w.resolve()
+	assert.Equal(t, conn, w.conn)
+
+	target = second.LocalAddr().(*net.UDPAddr)
+	w.resolve()
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", readUDP(t, second))
+}
+
+func TestUDPWriterResolveLoop(t *testing.T) {
+	listener := newTestUDPListener(t, "udp", "127.0.0.1")
+	defer listener.Close()
+
+	w, err := newUDPWriter(listener.LocalAddr().String(), 0, time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, w.stop)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = w.Write([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", readUDP(t, listener))
+	require.NoError(t, w.Close())
+}
+
+func TestUDPWriterIPv6(t *testing.T) {
+	listener, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skip("IPv6 is not available")
+	}
+	defer listener.Close()
+
+	port := listener.LocalAddr().(*net.UDPAddr).Port
+	client, err := NewEx(fmt.Sprintf("[::1]:%d", port), WithoutTelemetry(), WithoutClientSideAggregation(), WithoutOriginDetection())
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.Gauge("gauge", 1, nil, 1))
+	require.NoError(t, client.Flush())
+	assert.Equal(t, "gauge:1|g\n", readUDP(t, listener))
+}
+
+func TestWithDNSRefreshInterval(t *testing.T) {
+	_, err := resolveOptions([]Option{WithDNSRefreshInterval(-time.Second)})
+	assert.EqualError(t, err, "interval must not be negative")
+
+	client, err := NewEx("localhost:8765", WithDNSRefreshInterval(time.Minute), WithoutTelemetry())
+	require.NoError(t, err)
+	assert.NotNil(t, client.sender.transport.(*udpWriter).stop)
+	require.NoError(t, client.Close())
+}
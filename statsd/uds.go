@@ -4,7 +4,6 @@
 package statsd
 
 import (
-	"encoding/binary"
 	"net"
 	"strings"
 	"sync"
@@ -45,18 +44,6 @@ func (w *udsWriter) GetTransportName() string {
 	}
 }
 
-func (w *udsWriter) shouldCloseConnection(err error, partialWrite bool) bool {
-	if err != nil && partialWrite {
-		// We can't recover from a partial write
-		return true
-	}
-	if err, isNetworkErr := err.(net.Error); err != nil && (!isNetworkErr || !err.Timeout()) {
-		// Statsd server disconnected, retry connecting at next packet
-		return true
-	}
-	return false
-}
-
 // Write data to the UDS connection with write timeout and minimal error handling:
 // create the connection if nil, and destroy it if the statsd server has disconnected
 func (w *udsWriter) Write(data []byte) (int, error) {
@@ -66,36 +53,16 @@ func (w *udsWriter) Write(data []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	stream := conn.LocalAddr().Network() == "unix"
-
-	// When using streams the deadline will only make us drop the packet if we can't write it at all,
-	// once we've started writing we need to finish.
-	conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
 
 	// When using streams, we append the length of the packet to the data
-	if stream {
-		bs := []byte{0, 0, 0, 0}
-		binary.LittleEndian.PutUint32(bs, uint32(len(data)))
-		_, err = w.conn.Write(bs)
-
-		partialWrite = true
-
-		// W need to be able to finish to write partially written packets once we have started.
-		// But we will reset the connection if we can't write anything at all for a long time.
-		w.conn.SetWriteDeadline(time.Now().Add(w.connectTimeout))
-
-		// Continue writing only if we've written the length of the packet
-		if err == nil {
-			n, err = w.conn.Write(data)
-			if err == nil {
-				partialWrite = false
-			}
-		}
+	if conn.LocalAddr().Network() == "unix" {
+		n, partialWrite, err = writeFramed(conn, data, w.writeTimeout, w.connectTimeout)
 	} else {
-		n, err = w.conn.Write(data)
+		conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+		n, err = conn.Write(data)
 	}
 
-	if w.shouldCloseConnection(err, partialWrite) {
+	if shouldCloseConnection(err, partialWrite) {
 		w.unsetConnection()
 	}
 	return n, err
@@ -68,8 +68,16 @@ func (w *worker) processMetric(m metric) error {
 	w.Lock()
 	var err error
 	if err = w.writeMetricUnsafe(m); err == errBufferFull {
-		w.flushUnsafe()
-		err = w.writeMetricUnsafe(m)
+		if m.ctx == nil {
+			w.flushUnsafe()
+			err = w.writeMetricUnsafe(m)
+		} else if !w.flushUntilUnsafe(m.ctx.Done()) {
+			// The caller gave up while waiting for room in the sender queue. The buffer holds the metrics of other
+			// callers, it's kept to be flushed by the next write.
+			err = m.ctx.Err()
+		} else {
+			err = w.writeMetricUnsafe(m)
+		}
 	}
 	w.Unlock()
 	return err
@@ -151,8 +159,17 @@ func (w *worker) unpause() {
 // flush the current buffer. Lock must be held by caller.
 // flushed buffer written to the network asynchronously.
 func (w *worker) flushUnsafe() {
+	w.flushUntilUnsafe(nil)
+}
+
+// flushUntilUnsafe is flushUnsafe giving up on waiting for room in the sender queue when done is closed, in which
+// case the current buffer is kept and false is returned. Lock must be held by caller.
+func (w *worker) flushUntilUnsafe(done <-chan struct{}) bool {
 	if len(w.buffer.bytes()) > 0 {
-		w.sender.send(w.buffer)
+		if !w.sender.sendUntil(w.buffer, done) {
+			return false
+		}
 		w.buffer = w.pool.borrowBuffer()
 	}
+	return true
 }